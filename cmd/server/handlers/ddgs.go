@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"gogurt/internal/config"
+	"gogurt/internal/llm"
 	"gogurt/internal/pipes"
 	"net/http"
 	"time"
@@ -17,8 +18,9 @@ type DDGSRequest struct {
 
 // DDGSResponse defines the structure for the JSON response.
 type DDGSResponse struct {
-	Result string `json:"result"`
-	Error  string `json:"error,omitempty"`
+	Result string            `json:"result"`
+	Error  string            `json:"error,omitempty"`
+	Usage  *llm.UsageSummary `json:"usage,omitempty"`
 }
 
 // DDGSHandler handles HTTP requests to execute the plan-and-execute workflow.
@@ -42,6 +44,8 @@ func DDGSHandler(w http.ResponseWriter, r *http.Request) {
 	// Use a context with a timeout to prevent long-running requests.
 	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
 	defer cancel()
+	usage := llm.NewUsageTracker()
+	ctx = llm.WithUsageTracker(ctx, usage)
 
 	// Load configuration.
 	cfg := config.Load()
@@ -66,5 +70,7 @@ func DDGSHandler(w http.ResponseWriter, r *http.Request) {
 		resp.Result = result
 	}
 
+	summary := usage.Summary()
+	resp.Usage = &summary
 	json.NewEncoder(w).Encode(resp)
-}
\ No newline at end of file
+}
@@ -2,6 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"gogurt/internal/llm"
+	"gogurt/internal/llm/cache"
+	"gogurt/internal/ratelimit"
 	"net/http"
 	"runtime"
 )
@@ -12,6 +15,10 @@ func MetricsHandler(w http.ResponseWriter, r *http.Request) {
 	metrics := map[string]any{
 		"goroutines":   runtime.NumGoroutine(),
 		"memory_bytes": mem.Alloc,
+		"llm_usage":    llm.GlobalUsage().Summary(),
+		"llm_by_pipe":  llm.UsageByLabel(),
+		"llm_cache":    cache.GlobalStats(),
+		"rate_limits":  ratelimit.AllStats(),
 	}
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
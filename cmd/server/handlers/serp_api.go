@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"gogurt/internal/config"
+	"gogurt/internal/llm"
 	"gogurt/internal/pipes"
 	"net/http"
 	"time"
@@ -17,8 +18,9 @@ type SerpApiRequest struct {
 
 // SerpApiResponse defines the structure for the JSON response.
 type SerpApiResponse struct {
-	Result string `json:"result"`
-	Error  string `json:"error,omitempty"`
+	Result string            `json:"result"`
+	Error  string            `json:"error,omitempty"`
+	Usage  *llm.UsageSummary `json:"usage,omitempty"`
 }
 
 // SerpApiHandler handles HTTP requests to execute the plan-and-execute workflow.
@@ -42,6 +44,8 @@ func SerpApiHandler(w http.ResponseWriter, r *http.Request) {
 	// Use a context with a timeout to prevent long-running requests.
 	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
 	defer cancel()
+	usage := llm.NewUsageTracker()
+	ctx = llm.WithUsageTracker(ctx, usage)
 
 	// Load configuration.
 	cfg := config.Load()
@@ -71,5 +75,7 @@ func SerpApiHandler(w http.ResponseWriter, r *http.Request) {
 		resp.Error = "Request timed out"
 	}
 
+	summary := usage.Summary()
+	resp.Usage = &summary
 	json.NewEncoder(w).Encode(resp)
-}
\ No newline at end of file
+}
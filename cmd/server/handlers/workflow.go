@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"gogurt/internal/config"
+	"gogurt/internal/llm"
 	"gogurt/internal/pipes"
 	"net/http"
 	"time"
@@ -17,8 +18,9 @@ type WorkflowRequest struct {
 
 // WorkflowResponse defines the structure for the JSON response.
 type WorkflowResponse struct {
-	Result string `json:"result"`
-	Error  string `json:"error,omitempty"`
+	Result string            `json:"result"`
+	Error  string            `json:"error,omitempty"`
+	Usage  *llm.UsageSummary `json:"usage,omitempty"`
 }
 
 // WorkflowHandler handles HTTP requests to execute the plan-and-execute workflow.
@@ -42,6 +44,8 @@ func WorkflowHandler(w http.ResponseWriter, r *http.Request) {
 	// Use a context with a timeout to prevent long-running requests.
 	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
 	defer cancel()
+	usage := llm.NewUsageTracker()
+	ctx = llm.WithUsageTracker(ctx, usage)
 
 	// Load configuration. In a larger application, you might inject this
 	// as a dependency into the handler instead of loading it each time.
@@ -73,5 +77,7 @@ func WorkflowHandler(w http.ResponseWriter, r *http.Request) {
 		resp.Error = "Request timed out or was canceled."
 	}
 
+	summary := usage.Summary()
+	resp.Usage = &summary
 	json.NewEncoder(w).Encode(resp)
-}
\ No newline at end of file
+}
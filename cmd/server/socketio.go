@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"gogurt/internal/config"
+	"gogurt/internal/llm"
 	"gogurt/internal/logger"
 	"gogurt/internal/pipes"
 	"strings"
@@ -20,8 +21,9 @@ type SocketRequest struct {
 
 // SocketResponse defines the structure for outgoing responses to the client.
 type SocketResponse struct {
-	Result string `json:"result,omitempty"`
-	Error  string `json:"error,omitempty"`
+	Result string            `json:"result,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Usage  *llm.UsageSummary `json:"usage,omitempty"`
 }
 
 // NewSocketIOServer creates and configures a new Socket.IO server.
@@ -47,6 +49,8 @@ func NewSocketIOServer(wsLogger *logger.Logger) *socketio.Server {
 
 		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 		defer cancel()
+		usage := llm.NewUsageTracker()
+		ctx = llm.WithUsageTracker(ctx, usage)
 
 		cfg := config.Load()
 		var resultCh <-chan string
@@ -83,7 +87,8 @@ func NewSocketIOServer(wsLogger *logger.Logger) *socketio.Server {
 		// Wait for the pipe to finish and emit the response back to the client.
 		select {
 		case result := <-resultCh:
-			s.Emit("pipe-response", SocketResponse{Result: result})
+			summary := usage.Summary()
+			s.Emit("pipe-response", SocketResponse{Result: result, Usage: &summary})
 		case err := <-errCh:
 			s.Emit("pipe-response", SocketResponse{Error: err.Error()})
 		case <-ctx.Done():
@@ -97,4 +102,4 @@ func NewSocketIOServer(wsLogger *logger.Logger) *socketio.Server {
 	})
 
 	return server
-}
\ No newline at end of file
+}
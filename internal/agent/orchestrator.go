@@ -3,6 +3,7 @@ package agent
 import (
 	"context"
 	"fmt"
+	"gogurt/internal/llm"
 	"gogurt/internal/state"
 	"gogurt/internal/types"
 	"sync"
@@ -57,9 +58,12 @@ func (o *Orchestrator) RunParallel(ctx context.Context, input string) (<-chan *t
 }
 
 // RunPiped runs agents sequentially, using each output and updating state.
+// The token usage of the whole run is reported in the result's "usage" metadata.
 func (o *Orchestrator) RunPiped(ctx context.Context, input string) (<-chan *types.AgentCallResult, <-chan error) {
 	resultCh := make(chan *types.AgentCallResult, 1)
 	errCh := make(chan error, 1)
+	usage := llm.NewUsageTracker()
+	ctx = llm.WithUsageTracker(ctx, usage)
 
 	go func() {
 		defer close(resultCh)
@@ -85,6 +89,7 @@ func (o *Orchestrator) RunPiped(ctx context.Context, input string) (<-chan *type
 					acr.Metadata = make(map[string]any)
 				}
 				acr.Metadata["state"] = agent.State()
+				acr.Metadata["usage"] = usage.Summary()
 
 				finalResult = acr
 				o.State = agent.State()
@@ -106,4 +111,4 @@ func (o *Orchestrator) RunPiped(ctx context.Context, input string) (<-chan *type
 	}()
 
 	return resultCh, errCh
-}
\ No newline at end of file
+}
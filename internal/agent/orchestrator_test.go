@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/llm/fake"
+	"gogurt/internal/state"
+	"gogurt/internal/types"
+)
+
+// echoAgent answers with a single LLM generation.
+type echoAgent struct {
+	llm   llm.LLM
+	state state.AgentState
+}
+
+func (a *echoAgent) Invoke(ctx context.Context, input any) (<-chan any, <-chan error) {
+	resultCh := make(chan any, 1)
+	errorCh := make(chan error, 1)
+	go func() {
+		defer close(resultCh)
+		defer close(errorCh)
+		msg, err := a.llm.Generate(ctx, []types.ChatMessage{{Role: types.RoleUser, Content: input.(string)}})
+		if err != nil {
+			errorCh <- err
+			return
+		}
+		resultCh <- &types.AgentCallResult{Output: msg.Content}
+	}()
+	return resultCh, errorCh
+}
+
+func (a *echoAgent) OnMessage(ctx context.Context, msg *types.StateMessage) (<-chan *types.StateMessage, <-chan error) {
+	panic("not used")
+}
+func (a *echoAgent) State() *state.AgentState                                 { return &a.state }
+func (a *echoAgent) Describe() *types.AgentDescription                        { return &types.AgentDescription{Name: "echo"} }
+func (a *echoAgent) Init(ctx context.Context, config types.AgentConfig) error { return nil }
+
+func TestRunPipedThreadsOutputsAndUsage(t *testing.T) {
+	model := fake.New(
+		fake.Response{Content: "draft", Usage: &types.Usage{PromptTokens: 10, CompletionTokens: 5}},
+		fake.Response{Content: "final", Usage: &types.Usage{PromptTokens: 20, CompletionTokens: 7}},
+	)
+	orch := &Orchestrator{Agents: []Agent{
+		&echoAgent{llm: model, state: state.NewMemoryState()},
+		&echoAgent{llm: model, state: state.NewMemoryState()},
+	}}
+
+	resCh, errCh := orch.RunPiped(context.Background(), "goal")
+	select {
+	case res := <-resCh:
+		if res.Output != "final" {
+			t.Errorf("expected final output, got %q", res.Output)
+		}
+		usage, ok := res.Metadata["usage"].(llm.UsageSummary)
+		if !ok || usage.Calls != 2 || usage.TotalTokens != 42 {
+			t.Errorf("unexpected usage %+v", res.Metadata["usage"])
+		}
+	case err := <-errCh:
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := model.Requests()[1].Messages[0].Content; got != "draft" {
+		t.Errorf("expected second agent to receive first output, got %q", got)
+	}
+}
+
+func TestRunParallelCollectsAllResults(t *testing.T) {
+	model := fake.New().WithDefault(fake.Response{Content: "ok"})
+	orch := &Orchestrator{Agents: []Agent{
+		&echoAgent{llm: model, state: state.NewMemoryState()},
+		&echoAgent{llm: model, state: state.NewMemoryState()},
+		&echoAgent{llm: model, state: state.NewMemoryState()},
+	}}
+
+	resCh, _ := orch.RunParallel(context.Background(), "goal")
+	n := 0
+	for res := range resCh {
+		if res.Error != nil || res.Output != "ok" {
+			t.Errorf("unexpected result %+v", res)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("expected 3 results, got %d", n)
+	}
+}
@@ -5,10 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"gogurt/internal/llm"
+	"gogurt/internal/llm/structured"
 	"gogurt/internal/logger"
 	"gogurt/internal/state"
+	"gogurt/internal/tools"
 	"gogurt/internal/types"
-	"gogurt/internal/utils"
 )
 
 // PlannedStep defines the structure for a single step in the generated plan.
@@ -20,14 +21,20 @@ type PlannedStep struct {
 // PlannerAgent is responsible for breaking down a high-level goal into a sequence of tool calls.
 type PlannerAgent struct {
 	llm   llm.LLM
+	tools []*tools.Tool
+	opts  []llm.Option
 	state state.AgentState
 }
 
-// NewPlannerAgent creates a new PlannerAgent.
-func NewPlannerAgent(llm llm.LLM) Agent {
+// NewPlannerAgent creates a new PlannerAgent. When toolset is non-empty the tools are
+// offered to the model through native tool calling, and the returned calls become the plan.
+// opts are applied to every planning call (e.g. a zero temperature and fixed seed).
+func NewPlannerAgent(llm llm.LLM, toolset []*tools.Tool, opts ...llm.Option) Agent {
 	logger.Info("Creating PlannerAgent with LLM: %v", llm)
 	return &PlannerAgent{
 		llm:   llm,
+		tools: toolset,
+		opts:  opts,
 		state: state.NewMemoryState(),
 	}
 }
@@ -62,49 +69,53 @@ func (a *PlannerAgent) Invoke(ctx context.Context, input any) (<-chan any, <-cha
 			{Role: types.RoleUser, Content: prompt},
 		}
 
-		respCh, llmErrCh := a.llm.AGenerate(ctx, messages)
-
-		select {
-		case resp := <-respCh:
-			if resp == nil {
-				err := fmt.Errorf("no response from LLM")
-				logger.ErrorCtx(ctx, err.Error(), "response", resp)
-				errorCh <- err
-				return
-			}
-			logger.InfoCtx(ctx, "LLM response received: %s", resp.Content)
-
-			jsonContent := utils.ExtractJSONArray(resp.Content)
-			if jsonContent == "" {
-				err := fmt.Errorf("no JSON array found in LLM response: %s", resp.Content)
-				logger.WarnCtx(ctx, err.Error(), "response", resp)
-				errorCh <- err
+		plan, err := a.plan(ctx, messages)
+		if err != nil {
+			if ctx.Err() != nil {
+				errorCh <- ctx.Err()
 				return
 			}
-
-			var plan []PlannedStep
-			if err := json.Unmarshal([]byte(jsonContent), &plan); err != nil {
-				err = fmt.Errorf("failed to unmarshal plan from LLM response: %w. Response content: %s", err, jsonContent)
-				logger.ErrorCtx(ctx, err.Error(), "response", resp)
-				errorCh <- err
-				return
-			}
-
-			a.state.Set("plan", plan)
-			logger.InfoCtx(ctx, "Plan generated successfully: %v", plan)
-			resultCh <- plan
-
-		case err := <-llmErrCh:
 			logger.ErrorCtx(ctx, "LLM plan generation failed: %v", err)
 			errorCh <- fmt.Errorf("failed to generate plan: %w", err)
-		case <-ctx.Done():
-			errorCh <- ctx.Err()
+			return
 		}
+
+		a.state.Set("plan", plan)
+		logger.InfoCtx(ctx, "Plan generated successfully: %v", plan)
+		resultCh <- plan
 	}()
 
 	return resultCh, errorCh
 }
 
+// plan asks the LLM for a plan, using native tool calling when tools are configured.
+// Without tool calls the plan is read from the JSON in the model's reply, which is
+// validated against the plan schema and repaired by re-prompting when it does not match.
+// Models that reject tool definitions fall back to a plain generation.
+func (a *PlannerAgent) plan(ctx context.Context, messages []types.ChatMessage) ([]PlannedStep, error) {
+	planner := structured.New[[]PlannedStep](a.llm)
+	if len(a.tools) == 0 {
+		return planner.Generate(ctx, messages, a.opts...)
+	}
+	resp, err := a.llm.GenerateWithTools(ctx, messages, a.tools, a.opts...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		logger.WarnCtx(ctx, "Tool-calling plan generation failed, retrying without tools: %v", err)
+		return planner.Generate(ctx, messages, a.opts...)
+	}
+	if len(resp.ToolCalls) > 0 {
+		plan := make([]PlannedStep, len(resp.ToolCalls))
+		for i, call := range resp.ToolCalls {
+			plan[i] = PlannedStep{Tool: call.Name, Args: call.Args}
+		}
+		return plan, nil
+	}
+	logger.InfoCtx(ctx, "LLM response received: %s", resp.Content)
+	return planner.Continue(ctx, messages, resp, a.opts...)
+}
+
 // OnMessage handles agent-to-agent communication asynchronously.
 func (a *PlannerAgent) OnMessage(ctx context.Context, msg *types.StateMessage) (<-chan *types.StateMessage, <-chan error) {
 	resultCh := make(chan *types.StateMessage, 1)
@@ -151,4 +162,4 @@ func init() {
 	RegisterAgent("PlannerAgent", func() Agent {
 		return &PlannerAgent{}
 	})
-}
\ No newline at end of file
+}
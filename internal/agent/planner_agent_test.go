@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gogurt/internal/llm/fake"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+)
+
+func invokePlanner(t *testing.T, a Agent) ([]PlannedStep, error) {
+	t.Helper()
+	resCh, errCh := a.Invoke(context.Background(), "reverse 'abc'")
+	select {
+	case res := <-resCh:
+		return res.([]PlannedStep), nil
+	case err := <-errCh:
+		return nil, err
+	}
+}
+
+func TestPlannerParsesJSONFromText(t *testing.T) {
+	model := fake.Reply("Here is the plan:\n```json\n[{\"tool\": \"reverse\", \"args\": {\"text\": \"abc\"}}]\n```")
+	plan, err := invokePlanner(t, NewPlannerAgent(model, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []PlannedStep{{Tool: "reverse", Args: map[string]any{"text": "abc"}}}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("expected %v, got %v", want, plan)
+	}
+}
+
+func TestPlannerRejectsMissingJSON(t *testing.T) {
+	if _, err := invokePlanner(t, NewPlannerAgent(fake.Reply("I cannot help with that."), nil)); err == nil {
+		t.Fatal("expected error for a response without a plan")
+	}
+}
+
+func TestPlannerUsesToolCalls(t *testing.T) {
+	model := fake.New(fake.Response{ToolCalls: []types.ToolCall{{Name: "reverse", Args: map[string]any{"text": "abc"}}}})
+	plan, err := invokePlanner(t, NewPlannerAgent(model, []*tools.Tool{tools.ReverseTool}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan) != 1 || plan[0].Tool != "reverse" {
+		t.Errorf("unexpected plan %v", plan)
+	}
+	if req := model.LastRequest(); req.Method != "GenerateWithTools" || len(req.Tools) != 1 {
+		t.Errorf("expected tools to be offered, got %+v", req)
+	}
+}
+
+func TestPlannerFallsBackWithoutTools(t *testing.T) {
+	model := fake.New(
+		fake.Response{Err: errors.New("tools are not supported by this model")},
+		fake.Response{Content: `[{"tool": "reverse", "args": {"text": "abc"}}]`},
+	)
+	plan, err := invokePlanner(t, NewPlannerAgent(model, []*tools.Tool{tools.ReverseTool}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan) != 1 || model.LastRequest().Method != "Generate" {
+		t.Errorf("expected plain generation fallback, got plan %v and %+v", plan, model.Requests())
+	}
+}
+
+func TestPlannerRepairsInvalidPlan(t *testing.T) {
+	model := fake.Reply(`[{"args": {"text": "abc"}}]`, `[{"tool": "reverse", "args": {"text": "abc"}}]`)
+	plan, err := invokePlanner(t, NewPlannerAgent(model, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan) != 1 || plan[0].Tool != "reverse" || model.Calls() != 2 {
+		t.Errorf("expected repaired plan after 2 calls, got %v after %d", plan, model.Calls())
+	}
+}
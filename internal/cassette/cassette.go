@@ -0,0 +1,246 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mode selects whether a cassette replays stored traffic or records live traffic.
+type Mode int
+
+const (
+	// ModeReplay serves responses from the cassette file and never touches the network.
+	ModeReplay Mode = iota
+	// ModeRecord forwards requests to the real server and stores every exchange.
+	ModeRecord
+)
+
+// ModeFromEnv returns ModeRecord when GOGURT_CASSETTE=record, so cassettes can be
+// re-recorded with `GOGURT_CASSETTE=record go test ./...`.
+func ModeFromEnv() Mode {
+	if os.Getenv("GOGURT_CASSETTE") == "record" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Interaction is one recorded request/response pair. Request headers are never stored,
+// so API keys stay out of the files.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response keeps the body as the sequence of lines the server sent, so NDJSON and
+// server-sent event streams stay readable and replay chunk by chunk.
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Chunks  []string          `json:"chunks"`
+}
+
+type file struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// keptHeaders are the response headers worth replaying.
+var keptHeaders = []string{"Content-Type", "Retry-After"}
+
+// Cassette is an http.RoundTripper that records or replays provider traffic.
+type Cassette struct {
+	path string
+	mode Mode
+	base http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// New opens the cassette at path. In replay mode the file must exist; in record mode
+// it is written by Save. base is the transport used for recording (nil for the default).
+func New(path string, mode Mode, base http.RoundTripper) (*Cassette, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := &Cassette{path: path, mode: mode, base: base}
+	if mode == ModeRecord {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	c.interactions = f.Interactions
+	c.used = make([]bool, len(f.Interactions))
+	return c, nil
+}
+
+// Client returns an HTTP client using the cassette as its transport.
+func (c *Cassette) Client() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// Save writes recorded interactions to the cassette file. It is a no-op in replay mode.
+func (c *Cassette) Save() error {
+	if c.mode != ModeRecord {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := json.MarshalIndent(file{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, append(data, '\n'), 0644)
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	key := Request{Method: req.Method, Path: req.URL.RequestURI(), Body: normalize(body)}
+	if c.mode == ModeRecord {
+		return c.record(req, key)
+	}
+	return c.replay(req, key)
+}
+
+// replay returns the first unused interaction matching the request, so repeated
+// identical calls are answered in recorded order.
+func (c *Cassette) replay(req *http.Request, key Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, in := range c.interactions {
+		if c.used[i] || !matches(in.Request, key) {
+			continue
+		}
+		c.used[i] = true
+		return in.Response.toHTTP(req), nil
+	}
+	return nil, fmt.Errorf("cassette %s: no recorded interaction for %s %s", c.path, key.Method, key.Path)
+}
+
+func (c *Cassette) record(req *http.Request, key Request) (*http.Response, error) {
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	recorded := Response{Status: resp.StatusCode, Headers: map[string]string{}, Chunks: splitLines(string(data))}
+	for _, h := range keptHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			recorded.Headers[h] = v
+		}
+	}
+	c.mu.Lock()
+	c.interactions = append(c.interactions, Interaction{Request: key, Response: recorded})
+	c.mu.Unlock()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+func (r Response) toHTTP(req *http.Request) *http.Response {
+	header := make(http.Header)
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(&chunkReader{chunks: r.Chunks}),
+		ContentLength: -1,
+		Request:       req,
+	}
+}
+
+// chunkReader returns at most one recorded chunk per Read, as a streaming server would
+// deliver them, so clients that decode while reading see the same boundaries.
+type chunkReader struct {
+	chunks  []string
+	pending string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for r.pending == "" {
+		if len(r.chunks) == 0 {
+			return 0, io.EOF
+		}
+		r.pending, r.chunks = r.chunks[0], r.chunks[1:]
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// normalize re-encodes JSON bodies so key order and whitespace don't affect matching.
+// Non-JSON bodies are stored as JSON strings.
+func normalize(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err == nil {
+		if out, err := json.Marshal(v); err == nil {
+			return out
+		}
+	}
+	out, _ := json.Marshal(string(body))
+	return out
+}
+
+func matches(recorded, req Request) bool {
+	return recorded.Method == req.Method && recorded.Path == req.Path &&
+		bytes.Equal(normalize(recorded.Body), req.Body)
+}
+
+// splitLines splits s after each newline, keeping the newlines.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,74 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Error("expected the request to reach the server unchanged")
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"content\":\"Hel\"}\n{\"content\":\"lo\",\"done\":true}\n"))
+	}))
+	path := filepath.Join(t.TempDir(), "chat.json")
+
+	rec, err := New(path, ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(c *http.Client, url, body string) (string, error) {
+		req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := c.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+	want, err := post(rec.Client(), srv.URL+"/api/chat", `{"model": "m", "stream": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	play, err := New(path, ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// key order and whitespace in the body don't matter
+	got, err := post(play.Client(), "http://unused.invalid/api/chat", `{"stream":true,"model":"m"}`)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if _, err := post(play.Client(), "http://unused.invalid/api/chat", `{"model":"other"}`); err == nil {
+		t.Error("expected an error for an unrecorded request")
+	}
+}
+
+func TestReplayDeliversChunksSeparately(t *testing.T) {
+	body := Response{Status: http.StatusOK, Chunks: []string{"{\"a\":1}\n", "{\"b\":2}\n"}}.toHTTP(nil).Body
+	buf := make([]byte, 64)
+	n, err := body.Read(buf)
+	if err != nil || string(buf[:n]) != "{\"a\":1}\n" {
+		t.Fatalf("expected the first chunk alone, got %q, %v", buf[:n], err)
+	}
+	rest, _ := io.ReadAll(body)
+	if string(rest) != "{\"b\":2}\n" {
+		t.Errorf("expected the second chunk, got %q", rest)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"context"
 	"gogurt/internal/config"
 	"gogurt/internal/console"
+	"gogurt/internal/factories"
 	"gogurt/internal/pipes"
+	"gogurt/internal/vectorstores"
 	"gogurt/internal/vectorstores/chroma"
 	"os"
 	"strconv"
@@ -21,6 +23,12 @@ type RAGRunner interface {
 	Run(ctx context.Context, prompt string) (<-chan string, <-chan error)
 }
 
+// filterable is implemented by runners that can restrict retrieval by metadata.
+type filterable interface {
+	SetFilter(f *vectorstores.Filter)
+	Filter() *vectorstores.Filter
+}
+
 // Run is the main entry point for the interactive CLI modes.
 func Run(cfg *config.Config, documentPath string, s *chroma.Store, mode string) {
 	if err := configureProviders(cfg); err != nil {
@@ -60,6 +68,10 @@ func runIngestMode(cfg *config.Config, documentPath string, s *chroma.Store) {
 	}
 
 	c.Info("Document ingestion completed successfully\n")
+	if stats, ok := ingestor.EmbeddingCacheStats(); ok {
+		c.Info("Embedding cache: %d reused, %d embedded, %d entries (%.1f MB), %d evicted\n",
+			stats.Hits, stats.Misses, stats.Entries, float64(stats.Bytes)/(1<<20), stats.Evictions)
+	}
 
 	if cfg.VectorStoreProvider == "chroma" {
 		showDBMetrics(cfg, documentPath, s)
@@ -121,7 +133,7 @@ func configureProviders(cfg *config.Config) error {
 func runChatSession(rag RAGRunner, cfg *config.Config, documentPath string, s *chroma.Store) {
 	c.Write("\n==================================================================")
 	c.Title("\n=================== Chat Session Started =========================\n")
-	c.Hdr("\nCommands: [ metrics | help | init-collection | delete-collection | exit ]\n")
+	c.Hdr("\nCommands: [ metrics | filter | help | init-collection | delete-collection | exit ]\n")
 	reader := bufio.NewReader(os.Stdin)
 
 	for {
@@ -138,6 +150,11 @@ func runChatSession(rag RAGRunner, cfg *config.Config, documentPath string, s *c
 			continue
 		}
 
+		if cmd, expr, _ := strings.Cut(prompt, " "); strings.EqualFold(cmd, "filter") {
+			setFilter(rag, expr)
+			continue
+		}
+
 		// Handle local commands
 		switch strings.ToLower(prompt) {
 		case "exit":
@@ -164,7 +181,7 @@ func runChatSession(rag RAGRunner, cfg *config.Config, documentPath string, s *c
 				c.Warn("Collection name cannot be empty.")
 				continue
 			}
-			deleteCollection(s, collectionName)
+			deleteCollection(cfg, s, collectionName)
 			continue
 		case "help":
 			showChatHelp()
@@ -189,6 +206,36 @@ func runChatSession(rag RAGRunner, cfg *config.Config, documentPath string, s *c
 	}
 }
 
+// setFilter parses expr as the retrieval filter of rag, clears it for "off", and shows
+// the current one when expr is empty.
+func setFilter(rag RAGRunner, expr string) {
+	f, ok := rag.(filterable)
+	if !ok {
+		c.Warn("This pipeline does not support filters.")
+		return
+	}
+	expr = strings.TrimSpace(expr)
+	switch {
+	case expr == "":
+		if f.Filter() == nil {
+			c.Info("No filter set; searching all documents.\n")
+		} else {
+			c.Info("Filter: %s\n", f.Filter())
+		}
+	case strings.EqualFold(expr, "off"):
+		f.SetFilter(nil)
+		c.Info("Filter removed; searching all documents.\n")
+	default:
+		filter, err := vectorstores.ParseFilter(expr)
+		if err != nil {
+			c.Warn("%v\n", err)
+			return
+		}
+		f.SetFilter(filter)
+		c.Info("Filter: %s\n", filter)
+	}
+}
+
 func initCollection(s *chroma.Store, collectionName string) {
 	if s == nil {
 		c.Warn("Vector store is not available or not a Chroma store.")
@@ -200,7 +247,7 @@ func initCollection(s *chroma.Store, collectionName string) {
 	}
 	collectionName = strings.TrimSpace(collectionName)
 	ctx := context.Background()
-	col, err := s.Client.CreateCollection(ctx, collectionName)
+	col, err := s.CreateCollection(ctx, collectionName)
 	if err != nil {
 		c.Err("Error creating collection '%s': %v\n", collectionName, err)
 		return
@@ -212,7 +259,9 @@ func initCollection(s *chroma.Store, collectionName string) {
 	}
 }
 
-func deleteCollection(s *chroma.Store, collection string) {
+// deleteCollection deletes a Chroma collection. Deleting the configured collection also
+// clears the BM25 index built from it.
+func deleteCollection(cfg *config.Config, s *chroma.Store, collection string) {
 	if s == nil {
 		c.Warn("Vector store is not available or not a Chroma store.")
 		return
@@ -226,8 +275,17 @@ func deleteCollection(s *chroma.Store, collection string) {
 	err := s.Client.DeleteCollection(ctx, collection)
 	if err != nil {
 		c.Err("Error deleting collection: %v\n", err)
-	} else {
-		c.Info("Collection deleted successfully\n")
+		return
+	}
+	c.Info("Collection deleted successfully\n")
+	if collection == cfg.ChromaCollection {
+		index := factories.GetLexicalIndex(cfg)
+		index.Clear()
+		if cfg.BM25IndexPath != "" {
+			if err := index.Save(cfg.BM25IndexPath); err != nil {
+				c.Err("Error clearing BM25 index: %v\n", err)
+			}
+		}
 	}
 }
 
@@ -265,7 +323,14 @@ func promptForChoice(question string, options []string) string {
 	}
 }
 
+// metricsStore is the Chroma connection showDBMetrics opens when it is not given one. It
+// is kept so repeated metrics commands do not reconnect or build another embedder.
+var metricsStore *chroma.Store
+
 func showDBMetrics(cfg *config.Config, documentPath string, s *chroma.Store) {
+	if s == nil && metricsStore != nil {
+		s = metricsStore
+	}
 	if s == nil && cfg.VectorStoreProvider == "chroma" {
 		c.Write("\n==================================================================")
 		c.Info("\nCreating new Chroma connection for metrics...\n")
@@ -275,12 +340,12 @@ func showDBMetrics(cfg *config.Config, documentPath string, s *chroma.Store) {
 		c.Info("Chroma Collection: %v\n", cfg.ChromaCollection)
 		c.Info("Chroma Tenant: %v\n", cfg.ChromaTenant)
 		c.Info("Chroma URL: %v\n", cfg.ChromaURL)
-		newStore, err := chroma.New(cfg)
+		newStore, err := chroma.New(cfg, factories.GetEmbedder(cfg), factories.EmbeddingsModel(cfg))
 		if err != nil {
 			c.Err("Error creating Chroma connection: %v\n", err)
 			return
 		}
-		s = newStore
+		s, metricsStore = newStore, newStore
 	}
 	if s == nil {
 		c.Warn("Vector store is not available or not a Chroma store.")
@@ -311,6 +376,8 @@ func showChatHelp() {
 	c.Title("\n===================== Chat Help ==================================\n")
 	c.Hdr("\nAvailable commands:\n")
 	c.Info("  metrics - Show database metrics\n")
+	c.Info("  filter <expr> - Only search matching documents, e.g. language=go, ext=md|txt and dir=docs/design,\n")
+	c.Info("                  modified>=2025-01-01; \"filter off\" removes it, \"filter\" shows it\n")
 	c.Info("  help    - Show this help message\n")
 	c.Info("  init-collection - Create a new collection in ChromaDB\n")
 	c.Info("  delete-collection - Delete a collection from ChromaDB\n")
@@ -4,30 +4,125 @@ import (
 	"gogurt/internal/logger"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	LLMProvider          string
-	OllamaHost           string
-	OllamaModel          string
-	OllamaEmbedModel     string
-	AzureOpenAIEndpoint  string
-	AzureOpenAIAPIKey    string
-	AzureDeployment      string
-	OpenAIAPIKey         string
-	AgentMaxIterations   int
-	SplitterProvider     string
-	VectorStoreProvider  string
-	ChromaURL            string
-	ChromaSpace          string
-	ChromaCollection     string
-	ChromaTenant         string
-	ChromaDatabase       string
-	ChromaEFConstruction int
-	ChromaEFSearch       int
-	ChromaMaxNeighbors   int
+	LLMProvider                string
+	OllamaHost                 string
+	OllamaModel                string
+	OllamaEmbedModel           string
+	OllamaAutoPull             bool
+	OllamaKeepAlive            string
+	OllamaNumCtx               int
+	OllamaNumGPU               *int
+	OllamaNumThread            int
+	AzureOpenAIEndpoint        string
+	AzureOpenAIAPIKey          string
+	AzureDeployment            string
+	AzureModel                 string
+	OpenAIAPIKey               string
+	OpenAIModel                string
+	OpenAIEmbedModel           string
+	AzureEmbedDeployment       string
+	EmbeddingsProvider         string
+	EmbeddingsDimensions       int
+	EmbeddingsBatchSize        int
+	EmbeddingsCache            bool
+	EmbeddingsCacheDir         string
+	EmbeddingsCacheMaxMB       int
+	OpenAICompatibleBaseURL    string
+	OpenAICompatibleAPIKey     string
+	OpenAICompatibleModel      string
+	OpenAICompatibleEmbedModel string
+	LLMTemperature             *float32
+	LLMTopP                    *float32
+	LLMMaxTokens               int
+	LLMStop                    []string
+	LLMSeed                    *int
+	PlannerTemperature         float32
+	PlannerSeed                int
+	SynthesisTemperature       float32
+	LLMMaxRetries              int
+	LLMRetryBaseDelay          time.Duration
+	LLMRetryMaxDelay           time.Duration
+	LLMBreakerThreshold        int
+	LLMBreakerCooldown         time.Duration
+	LLMFallbackCooldown        time.Duration
+	LLMContextWindow           int
+	LLMContextReserve          int
+	LLMTrimStrategy            []string
+	TiktokenDir                string
+	PDFVisionFallback          bool
+	LLMCache                   string
+	LLMCacheSize               int
+	LLMCacheDir                string
+	LLMCacheTTL                time.Duration
+	LLMCacheSemantic           bool
+	LLMCacheSimilarity         float32
+	VisionModel                string
+	RateLimits                 map[string]RateLimit
+	AgentMaxIterations         int
+	SplitterProvider           string
+	VectorStoreProvider        string
+	SimpleStorePath            string
+	HNSWM                      int
+	HNSWEFConstruction         int
+	HNSWEFSearch               int
+	Retriever                  string
+	HybridFusion               string
+	HybridVectorWeight         float32
+	BM25IndexPath              string
+	RetrieverK                 int
+	RetrieverFetchK            int
+	RetrieverScoreThreshold    float32
+	RetrieverSearchType        string
+	RetrieverMMRLambda         float32
+	Reranker                   string
+	RerankerModel              string
+	ChromaURL                  string
+	ChromaSpace                string
+	ChromaCollection           string
+	ChromaTenant               string
+	ChromaDatabase             string
+	ChromaEFConstruction       int
+	ChromaEFSearch             int
+	ChromaMaxNeighbors         int
+}
+
+// RateLimit bounds the requests sent to one provider; zero values disable a limit.
+type RateLimit struct {
+	MaxInFlight       int
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// rateLimitPrefixes maps providers to the prefix of their <PREFIX>_MAX_IN_FLIGHT,
+// <PREFIX>_RPM and <PREFIX>_TPM variables.
+var rateLimitPrefixes = map[string]string{
+	"ollama":            "OLLAMA",
+	"openai":            "OPENAI",
+	"azure":             "AZURE_OPENAI",
+	"openai-compatible": "OPENAI_COMPATIBLE",
+}
+
+func loadRateLimits() map[string]RateLimit {
+	limits := make(map[string]RateLimit, len(rateLimitPrefixes))
+	for provider, prefix := range rateLimitPrefixes {
+		// a single local Ollama serves requests a few at a time; hosted APIs publish their own limits
+		inFlight := "0"
+		if provider == "ollama" {
+			inFlight = "4"
+		}
+		maxInFlight, _ := strconv.Atoi(getEnv(prefix+"_MAX_IN_FLIGHT", inFlight))
+		rpm, _ := strconv.Atoi(getEnv(prefix+"_RPM", "0"))
+		tpm, _ := strconv.Atoi(getEnv(prefix+"_TPM", "0"))
+		limits[provider] = RateLimit{MaxInFlight: maxInFlight, RequestsPerMinute: rpm, TokensPerMinute: tpm}
+	}
+	return limits
 }
 
 func Load() *Config {
@@ -43,27 +138,113 @@ func Load() *Config {
 	efConstruction, _ := strconv.Atoi(getEnv("CHROMA_EF_CONSTRUCTION", "100"))
 	efSearch, _ := strconv.Atoi(getEnv("CHROMA_EF_SEARCH", "100"))
 	maxNeighbors, _ := strconv.Atoi(getEnv("CHROMA_MAX_NEIGHBORS", "16"))
+	hnswM, _ := strconv.Atoi(getEnv("HNSW_M", "16"))
+	hnswEFConstruction, _ := strconv.Atoi(getEnv("HNSW_EF_CONSTRUCTION", "100"))
+	hnswEFSearch, _ := strconv.Atoi(getEnv("HNSW_EF_SEARCH", "100"))
+	retrieverK, _ := strconv.Atoi(getEnv("RETRIEVER_K", "3"))
+	retrieverFetchK, _ := strconv.Atoi(getEnv("RETRIEVER_FETCH_K", "0"))
+	maxTokens, _ := strconv.Atoi(getEnv("LLM_MAX_TOKENS", "0"))
+	plannerSeed, _ := strconv.Atoi(getEnv("PLANNER_SEED", "42"))
+	maxRetries, _ := strconv.Atoi(getEnv("LLM_MAX_RETRIES", "3"))
+	breakerThreshold, _ := strconv.Atoi(getEnv("LLM_BREAKER_THRESHOLD", "5"))
+	contextWindow, _ := strconv.Atoi(getEnv("LLM_CONTEXT_WINDOW", "0"))
+	contextReserve, _ := strconv.Atoi(getEnv("LLM_CONTEXT_RESERVE", "1024"))
+	cacheSize, _ := strconv.Atoi(getEnv("LLM_CACHE_SIZE", "1000"))
+	embeddingsDimensions, _ := strconv.Atoi(getEnv("EMBEDDINGS_DIMENSIONS", "0"))
+	embeddingsBatchSize, _ := strconv.Atoi(getEnv("EMBEDDINGS_BATCH_SIZE", "256"))
+	embeddingsCacheMaxMB, _ := strconv.Atoi(getEnv("EMBEDDINGS_CACHE_MAX_MB", "512"))
+	ollamaNumCtx, _ := strconv.Atoi(getEnv("OLLAMA_NUM_CTX", "0"))
+	ollamaNumThread, _ := strconv.Atoi(getEnv("OLLAMA_NUM_THREAD", "0"))
+	var trimStrategy []string
+	if v := getEnv("LLM_TRIM_STRATEGY", "drop_oldest,truncate"); v != "" {
+		trimStrategy = strings.Split(v, ",")
+	}
+	var stop []string
+	if v := getEnv("LLM_STOP", ""); v != "" {
+		stop = strings.Split(v, ",")
+	}
 
 	return &Config{
-		LLMProvider:          getEnv("LLM_PROVIDER", "openai"),
-		OllamaHost:           getEnv("OLLAMA_HOST", "http://localhost:11434"),
-		OllamaModel:          getEnv("OLLAMA_MODEL", "llama3.2:3b"),
-		OllamaEmbedModel:     getEnv("OLLAMA_EMBED_MODEL", "llama3.2:3b"),
-		AzureOpenAIEndpoint:  getEnv("AZURE_OPENAI_ENDPOINT", ""),
-		AzureOpenAIAPIKey:    getEnv("AZURE_OPENAI_API_KEY", ""),
-		AzureDeployment:      getEnv("AZURE_OPENAI_DEPLOYMENT_NAME", ""),
-		OpenAIAPIKey:         getEnv("OPENAI_API_KEY", ""),
-		AgentMaxIterations:   maxIter,
-		SplitterProvider:     getEnv("SPLITTER_PROVIDER", "recursive"),
-		VectorStoreProvider:  getEnv("VECTOR_STORE_PROVIDER", "faiss"),
-		ChromaURL:            getEnv("CHROMA_URL", "http://localhost:8000"),
-		ChromaSpace:          getEnv("CHROMA_SPACE", "cosine"),
-		ChromaCollection:     getEnv("CHROMA_COLLECTION", "GogurtCol"),
-		ChromaTenant:         getEnv("CHROMA_TENANT", "joe"),
-		ChromaDatabase:       getEnv("CHROMA_DATABASE", "GogurtDB"),
-		ChromaEFConstruction: efConstruction,
-		ChromaEFSearch:       efSearch,
-		ChromaMaxNeighbors:   maxNeighbors,
+		LLMProvider:                getEnv("LLM_PROVIDER", "openai"),
+		OllamaHost:                 getEnv("OLLAMA_HOST", "http://localhost:11434"),
+		OllamaModel:                getEnv("OLLAMA_MODEL", "llama3.2:3b"),
+		OllamaEmbedModel:           getEnv("OLLAMA_EMBED_MODEL", "llama3.2:3b"),
+		OllamaAutoPull:             getEnv("OLLAMA_AUTO_PULL", "false") == "true",
+		OllamaKeepAlive:            getEnv("OLLAMA_KEEP_ALIVE", ""),
+		OllamaNumCtx:               ollamaNumCtx,
+		OllamaNumGPU:               getEnvIntPtr("OLLAMA_NUM_GPU"),
+		OllamaNumThread:            ollamaNumThread,
+		AzureOpenAIEndpoint:        getEnv("AZURE_OPENAI_ENDPOINT", ""),
+		AzureOpenAIAPIKey:          getEnv("AZURE_OPENAI_API_KEY", ""),
+		AzureDeployment:            getEnv("AZURE_OPENAI_DEPLOYMENT_NAME", ""),
+		AzureModel:                 getEnv("AZURE_OPENAI_MODEL", ""),
+		OpenAIAPIKey:               getEnv("OPENAI_API_KEY", ""),
+		OpenAIModel:                getEnv("OPENAI_MODEL", "gpt-4o"),
+		OpenAIEmbedModel:           getEnv("OPENAI_EMBED_MODEL", "text-embedding-3-small"),
+		AzureEmbedDeployment:       getEnv("AZURE_OPENAI_EMBED_DEPLOYMENT_NAME", ""),
+		EmbeddingsProvider:         getEnv("EMBEDDINGS_PROVIDER", ""),
+		EmbeddingsDimensions:       embeddingsDimensions,
+		EmbeddingsBatchSize:        embeddingsBatchSize,
+		EmbeddingsCache:            getEnv("EMBEDDINGS_CACHE", "false") == "true",
+		EmbeddingsCacheDir:         getEnv("EMBEDDINGS_CACHE_DIR", ".cache/embeddings"),
+		EmbeddingsCacheMaxMB:       embeddingsCacheMaxMB,
+		OpenAICompatibleBaseURL:    getEnv("OPENAI_COMPATIBLE_BASE_URL", ""),
+		OpenAICompatibleAPIKey:     getEnv("OPENAI_COMPATIBLE_API_KEY", ""),
+		OpenAICompatibleModel:      getEnv("OPENAI_COMPATIBLE_MODEL", ""),
+		OpenAICompatibleEmbedModel: getEnv("OPENAI_COMPATIBLE_EMBED_MODEL", ""),
+		LLMTemperature:             getEnvFloatPtr("LLM_TEMPERATURE"),
+		LLMTopP:                    getEnvFloatPtr("LLM_TOP_P"),
+		LLMMaxTokens:               maxTokens,
+		LLMStop:                    stop,
+		LLMSeed:                    getEnvIntPtr("LLM_SEED"),
+		PlannerTemperature:         getEnvFloat("PLANNER_TEMPERATURE", 0),
+		PlannerSeed:                plannerSeed,
+		SynthesisTemperature:       getEnvFloat("SYNTHESIS_TEMPERATURE", 0.7),
+		LLMMaxRetries:              maxRetries,
+		LLMRetryBaseDelay:          getEnvDuration("LLM_RETRY_BASE_DELAY", 500*time.Millisecond),
+		LLMRetryMaxDelay:           getEnvDuration("LLM_RETRY_MAX_DELAY", 30*time.Second),
+		LLMBreakerThreshold:        breakerThreshold,
+		LLMBreakerCooldown:         getEnvDuration("LLM_BREAKER_COOLDOWN", 30*time.Second),
+		LLMFallbackCooldown:        getEnvDuration("LLM_FALLBACK_COOLDOWN", time.Minute),
+		LLMContextWindow:           contextWindow,
+		LLMContextReserve:          contextReserve,
+		LLMTrimStrategy:            trimStrategy,
+		TiktokenDir:                getEnv("TIKTOKEN_DIR", ""),
+		PDFVisionFallback:          getEnv("PDF_VISION_FALLBACK", "false") == "true",
+		LLMCache:                   getEnv("LLM_CACHE", ""),
+		LLMCacheSize:               cacheSize,
+		LLMCacheDir:                getEnv("LLM_CACHE_DIR", ".cache/llm"),
+		LLMCacheTTL:                getEnvDuration("LLM_CACHE_TTL", 24*time.Hour),
+		LLMCacheSemantic:           getEnv("LLM_CACHE_SEMANTIC", "false") == "true",
+		LLMCacheSimilarity:         getEnvFloat("LLM_CACHE_SIMILARITY", 0.95),
+		VisionModel:                getEnv("VISION_MODEL", ""),
+		RateLimits:                 loadRateLimits(),
+		AgentMaxIterations:         maxIter,
+		SplitterProvider:           getEnv("SPLITTER_PROVIDER", "recursive"),
+		VectorStoreProvider:        getEnv("VECTOR_STORE_PROVIDER", "faiss"),
+		SimpleStorePath:            getEnv("SIMPLE_STORE_PATH", ".cache/simple-store.gvs"),
+		HNSWM:                      hnswM,
+		HNSWEFConstruction:         hnswEFConstruction,
+		HNSWEFSearch:               hnswEFSearch,
+		Retriever:                  getEnv("RETRIEVER", "vector"),
+		HybridFusion:               getEnv("HYBRID_FUSION", "rrf"),
+		HybridVectorWeight:         getEnvFloat("HYBRID_VECTOR_WEIGHT", 0.5),
+		BM25IndexPath:              getEnv("BM25_INDEX_PATH", ".cache/bm25.gob"),
+		RetrieverK:                 retrieverK,
+		RetrieverFetchK:            retrieverFetchK,
+		RetrieverScoreThreshold:    getEnvFloat("RETRIEVER_SCORE_THRESHOLD", 0),
+		RetrieverSearchType:        getEnv("RETRIEVER_SEARCH_TYPE", "similarity"),
+		RetrieverMMRLambda:         getEnvFloat("RETRIEVER_MMR_LAMBDA", 0.5),
+		Reranker:                   getEnv("RERANKER", ""),
+		RerankerModel:              getEnv("RERANKER_MODEL", ""),
+		ChromaURL:                  getEnv("CHROMA_URL", "http://localhost:8000"),
+		ChromaSpace:                getEnv("CHROMA_SPACE", "cosine"),
+		ChromaCollection:           getEnv("CHROMA_COLLECTION", "GogurtCol"),
+		ChromaTenant:               getEnv("CHROMA_TENANT", "joe"),
+		ChromaDatabase:             getEnv("CHROMA_DATABASE", "GogurtDB"),
+		ChromaEFConstruction:       efConstruction,
+		ChromaEFSearch:             efSearch,
+		ChromaMaxNeighbors:         maxNeighbors,
 	}
 }
 
@@ -73,3 +254,52 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvFloat(key string, fallback float32) float32 {
+	if v := getEnvFloatPtr(key); v != nil {
+		return *v
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Error("Invalid %s: %v; using default %s.", key, err, fallback)
+		return fallback
+	}
+	return d
+}
+
+// getEnvFloatPtr returns nil when key is unset or invalid, so callers can tell "unset" from 0.
+func getEnvFloatPtr(key string) *float32 {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		logger.Error("Invalid %s: %v; ignoring.", key, err)
+		return nil
+	}
+	f32 := float32(f)
+	return &f32
+}
+
+// getEnvIntPtr returns nil when key is unset or invalid, so callers can tell "unset" from 0.
+func getEnvIntPtr(key string) *int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Error("Invalid %s: %v; ignoring.", key, err)
+		return nil
+	}
+	return &i
+}
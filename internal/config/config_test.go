@@ -21,3 +21,24 @@ func TestLoad(t *testing.T) {
 	os.Unsetenv("LLM_PROVIDER")
 	os.Unsetenv("AGENT_MAX_ITERATIONS")
 }
+
+func TestLoadGenerationOptions(t *testing.T) {
+	os.Setenv("LLM_TEMPERATURE", "0")
+	os.Setenv("LLM_STOP", "END,STOP")
+	os.Unsetenv("LLM_SEED")
+
+	cfg := Load()
+
+	if cfg.LLMTemperature == nil || *cfg.LLMTemperature != 0 {
+		t.Errorf("expected LLM_TEMPERATURE to be set to 0, got %v", cfg.LLMTemperature)
+	}
+	if cfg.LLMSeed != nil {
+		t.Errorf("expected unset LLM_SEED to be nil, got %v", *cfg.LLMSeed)
+	}
+	if len(cfg.LLMStop) != 2 || cfg.LLMStop[1] != "STOP" {
+		t.Errorf("expected LLM_STOP to be [END STOP], got %v", cfg.LLMStop)
+	}
+
+	os.Unsetenv("LLM_TEMPERATURE")
+	os.Unsetenv("LLM_STOP")
+}
@@ -1,11 +1,14 @@
 package documentloaders
 
 import (
+	"context"
 	"fmt"
+	"gogurt/internal/llm"
 	"gogurt/internal/logger"
 
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gogurt/internal/documentloaders/code"
 	"gogurt/internal/documentloaders/markdown"
@@ -16,25 +19,79 @@ import (
 
 // detects if the path is a file or a directory and loads accordingly.
 func LoadDocuments(path string) ([]types.Document, error) {
+	return LoadDocumentsWithVision(context.Background(), path, nil)
+}
+
+// like LoadDocuments, but PDFs without extractable text (scans) are transcribed by the
+// vision model. A nil model disables the fallback.
+func LoadDocumentsWithVision(ctx context.Context, path string, vision llm.LLM, opts ...llm.Option) ([]types.Document, error) {
 	fileInfo, err := os.Stat(path)
 	if err != nil {
 		return nil, fmt.Errorf("could not access path %s: %w", path, err)
 	}
 
+	l := loader{ctx: ctx, vision: vision, opts: opts}
 	if fileInfo.IsDir() {
-		return loadFromDirectory(path)
+		return l.loadFromDirectory(path)
 	}
 
-	return loadFromFile(path)
+	return l.loadFromFile(path)
+}
+
+type loader struct {
+	ctx    context.Context
+	vision llm.LLM
+	opts   []llm.Option
+}
+
+// languages names the language of each supported extension for the "language" metadata.
+var languages = map[string]string{
+	".txt":  "text",
+	".pdf":  "pdf",
+	".md":   "markdown",
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".ts":   "typescript",
+	".java": "java",
+	".cpp":  "cpp",
+	".c":    "c",
+	".rs":   "rust",
+}
+
+// loads a single file using the appropriate loader, adding the metadata that searches
+// can filter on: dir, ext, language and modified (Unix seconds).
+func (l loader) loadFromFile(filePath string) ([]types.Document, error) {
+	docs, err := l.load(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var modified int
+	if info, err := os.Stat(filePath); err == nil {
+		modified = int(info.ModTime().Unix())
+	}
+	ext := filepath.Ext(filePath)
+	for i := range docs {
+		if docs[i].Metadata == nil {
+			docs[i].Metadata = map[string]any{}
+		}
+		docs[i].Metadata["dir"] = filepath.Dir(filePath)
+		docs[i].Metadata["ext"] = strings.TrimPrefix(ext, ".")
+		docs[i].Metadata["language"] = languages[ext]
+		docs[i].Metadata["modified"] = modified
+	}
+	return docs, nil
 }
 
-// loads a single file using the appropriate loader.
-func loadFromFile(filePath string) ([]types.Document, error) {
+func (l loader) load(filePath string) ([]types.Document, error) {
 	ext := filepath.Ext(filePath)
 	switch ext {
 	case ".txt":
 		return text.NewTextLoader(filePath)
 	case ".pdf":
+		if l.vision != nil {
+			return pdf.NewPDFLoaderWithVision(l.ctx, filePath, l.vision, l.opts...)
+		}
 		return pdf.NewPDFLoader(filePath)
 	case ".md":
 		return markdown.NewMarkdownLoader(filePath)
@@ -46,7 +103,7 @@ func loadFromFile(filePath string) ([]types.Document, error) {
 }
 
 // reads all supported files from a directory.
-func loadFromDirectory(dirPath string) ([]types.Document, error) {
+func (l loader) loadFromDirectory(dirPath string) ([]types.Document, error) {
 	files, err := os.ReadDir(dirPath)
 	if err != nil {
 		return nil, err
@@ -60,7 +117,7 @@ func loadFromDirectory(dirPath string) ([]types.Document, error) {
 	for _, file := range files {
 		if !file.IsDir() {
 			filePath := filepath.Join(dirPath, file.Name())
-			docs, err := loadFromFile(filePath)
+			docs, err := l.loadFromFile(filePath)
 			if err != nil {
 				// log the error for the specific file but continue with others
 				logger.Warn("Failed to load file %s: %v", filePath, err)
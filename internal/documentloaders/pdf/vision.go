@@ -0,0 +1,162 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/logger"
+	"gogurt/internal/types"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// VisionPrompt asks a vision model to transcribe one scanned page.
+const VisionPrompt = "Transcribe all text on this scanned page, keeping its reading order. Reply with the text only."
+
+// NewPDFLoaderWithVision loads a PDF like NewPDFLoader, but checks every page: pages
+// without extractable text whose content is a scanned image are transcribed by model,
+// which must accept images. A page that fails to transcribe is skipped with a warning;
+// only when nothing is left is it an error. If any page was sent to model, one document
+// is returned per page; otherwise the result is the same as NewPDFLoader's.
+func NewPDFLoaderWithVision(ctx context.Context, filePath string, model llm.LLM, opts ...llm.Option) ([]types.Document, error) {
+	f, r, err := pdf.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// encrypted streams cannot be passed on as they are stored
+	encrypted := !r.Trailer().Key("Encrypt").IsNull()
+
+	var (
+		pages    []types.Document
+		all      strings.Builder
+		scanned  int
+		failed   []error
+		fonts    = make(map[string]*pdf.Font)
+		numPages = r.NumPage()
+	)
+	for i := 1; i <= numPages; i++ {
+		page := r.Page(i)
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+		text, err := page.GetPlainText(fonts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page %d of %s: %w", i, filePath, err)
+		}
+		all.WriteString(text)
+
+		blank := strings.TrimSpace(text) == ""
+		if blank && !encrypted {
+			image, err := pageImage(f, page)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the image on page %d of %s: %w", i, filePath, err)
+			}
+			if image != nil {
+				resp, err := model.Generate(ctx, []types.ChatMessage{{
+					Role:    types.RoleUser,
+					Content: VisionPrompt,
+					Parts:   []types.ContentPart{types.ImagePart(image, "image/jpeg")},
+				}}, opts...)
+				scanned++
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					err = fmt.Errorf("failed to transcribe page %d of %s: %w", i, filePath, err)
+					logger.Warn("Skipping page: %v", err)
+					failed = append(failed, err)
+					continue
+				}
+				pages = append(pages, types.Document{
+					PageContent: resp.Content,
+					Metadata:    map[string]any{"source": filePath, "page": i, "extraction": "vision"},
+				})
+				continue
+			}
+		}
+		if !blank {
+			pages = append(pages, types.Document{
+				PageContent: text,
+				Metadata:    map[string]any{"source": filePath, "page": i},
+			})
+		}
+	}
+
+	if scanned == 0 {
+		return []types.Document{{PageContent: all.String(), Metadata: map[string]any{"source": filePath}}}, nil
+	}
+	if len(pages) == 0 {
+		return nil, errors.Join(failed...)
+	}
+	return pages, nil
+}
+
+// minPageImageWidth skips logos and icons; scanned pages are hundreds of pixels wide.
+const minPageImageWidth = 300
+
+// pageImage returns the largest JPEG image drawn on page, or nil if it has none. Scanners
+// store each page as one DCTDecode image, which is a complete JPEG file, so no decoding is
+// needed; images in other encodings are skipped.
+func pageImage(f io.ReaderAt, page pdf.Page) ([]byte, error) {
+	xobjects := page.Resources().Key("XObject")
+	var (
+		best     pdf.Value
+		bestArea int64
+	)
+	for _, name := range xobjects.Keys() {
+		x := xobjects.Key(name)
+		filter := x.Key("Filter")
+		if filter.Kind() == pdf.Array && filter.Len() == 1 {
+			filter = filter.Index(0)
+		}
+		if x.Kind() != pdf.Stream || x.Key("Subtype").Name() != "Image" || filter.Name() != "DCTDecode" {
+			continue
+		}
+		width, height := x.Key("Width").Int64(), x.Key("Height").Int64()
+		if width < minPageImageWidth {
+			continue
+		}
+		if area := width * height; area > bestArea {
+			best, bestArea = x, area
+		}
+	}
+	if best.IsNull() {
+		return nil, nil
+	}
+
+	offset, err := streamOffset(best)
+	if err != nil {
+		return nil, err
+	}
+	image := make([]byte, best.Key("Length").Int64())
+	if _, err := f.ReadAt(image, offset); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(image, []byte{0xFF, 0xD8}) {
+		return nil, nil
+	}
+	return image, nil
+}
+
+// streamOffset returns where the data of stream v starts in the file. The library cannot
+// decode DCT streams and has no accessor for their raw bytes or offset, but it formats a
+// stream value as its dictionary followed by "@" and that offset. TestStreamOffset pins
+// this format, so a library upgrade that changes it fails there rather than here.
+func streamOffset(v pdf.Value) (int64, error) {
+	s := v.String()
+	at := strings.LastIndexByte(s, '@')
+	if at == -1 {
+		return 0, fmt.Errorf("no stream offset in %q", s)
+	}
+	return strconv.ParseInt(s[at+1:], 10, 64)
+}
@@ -0,0 +1,158 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gogurt/internal/llm/fake"
+	"gogurt/internal/types"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// writeScannedPDF writes a PDF with one page per entry of texts. A page with text holds
+// it as a line of Helvetica; an empty one holds only a JPEG image, like a scanner produces.
+func writeScannedPDF(t *testing.T, jpeg []byte, texts ...string) string {
+	t.Helper()
+	kids := make([]string, len(texts))
+	for i := range texts {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(texts)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 612 /Height 792 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream", len(jpeg), jpeg),
+	}
+	for i, text := range texts {
+		content := "q 612 0 0 792 0 0 cm /Im1 Do Q"
+		if text != "" {
+			content = fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		}
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> /XObject << /Im1 4 0 R >> >> /Contents %d 0 R >>", 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	path := filepath.Join(t.TempDir(), "scan.pdf")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVisionFallbackTranscribesScannedPages(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xe0fake jpeg body\xff\xd9")
+	path := writeScannedPDF(t, jpeg, "")
+	model := fake.Reply("Invoice #42\nTotal: $10")
+
+	docs, err := NewPDFLoaderWithVision(context.Background(), path, model)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(docs) != 1 || docs[0].PageContent != "Invoice #42\nTotal: $10" || docs[0].Metadata["extraction"] != "vision" {
+		t.Fatalf("unexpected documents %+v", docs)
+	}
+	sent := model.LastRequest().Messages[0]
+	if len(sent.Parts) != 1 || sent.Parts[0].Type != types.PartImage || !bytes.Equal(sent.Parts[0].Data, jpeg) {
+		t.Errorf("expected the page image to be sent, got %+v", sent.Parts)
+	}
+}
+
+func TestVisionFallbackChecksEveryPage(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xe0fake jpeg body\xff\xd9")
+	path := writeScannedPDF(t, jpeg, "Cover letter", "")
+	model := fake.Reply("Signed contract")
+
+	docs, err := NewPDFLoaderWithVision(context.Background(), path, model)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected one document per page, got %+v", docs)
+	}
+	if !strings.Contains(docs[0].PageContent, "Cover letter") || docs[0].Metadata["page"] != 1 || docs[0].Metadata["extraction"] != nil {
+		t.Errorf("expected the text page to keep its text, got %+v", docs[0])
+	}
+	if docs[1].PageContent != "Signed contract" || docs[1].Metadata["page"] != 2 || docs[1].Metadata["extraction"] != "vision" {
+		t.Errorf("expected the scanned page to be transcribed, got %+v", docs[1])
+	}
+}
+
+func TestVisionFallbackLeavesTextPDFsAlone(t *testing.T) {
+	path := writeScannedPDF(t, nil, "First page", "Second page")
+	model := fake.Reply("unused")
+
+	docs, err := NewPDFLoaderWithVision(context.Background(), path, model)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(docs) != 1 || !strings.Contains(docs[0].PageContent, "Second page") {
+		t.Fatalf("expected the extracted text as one document, got %+v", docs)
+	}
+	if model.Calls() != 0 {
+		t.Error("a PDF with text on every page should not be sent to the model")
+	}
+}
+
+func TestVisionFallbackSkipsPagesThatFail(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xe0fake jpeg body\xff\xd9")
+	path := writeScannedPDF(t, jpeg, "", "")
+	model := fake.New(fake.Response{Err: errors.New("model overloaded")}, fake.Response{Content: "Page two"})
+
+	docs, err := NewPDFLoaderWithVision(context.Background(), path, model)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(docs) != 1 || docs[0].PageContent != "Page two" || docs[0].Metadata["page"] != 2 {
+		t.Fatalf("expected only the second page, got %+v", docs)
+	}
+
+	model = fake.New(fake.Response{Err: errors.New("model overloaded")})
+	if _, err := NewPDFLoaderWithVision(context.Background(), writeScannedPDF(t, jpeg, ""), model); err == nil {
+		t.Fatal("expected an error when no page could be read")
+	}
+}
+
+// TestStreamOffset pins the format streamOffset parses out of the pdf library.
+func TestStreamOffset(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xe0fake jpeg body\xff\xd9")
+	path := writeScannedPDF(t, jpeg, "")
+	f, r, err := pdf.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	offset, err := streamOffset(r.Page(1).Resources().Key("XObject").Key("Im1"))
+	if err != nil {
+		t.Fatalf("the pdf library no longer formats streams as <<dict>>@offset: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(bytes.Index(data, jpeg)); offset != want {
+		t.Errorf("got offset %d, want %d", offset, want)
+	}
+}
@@ -0,0 +1,27 @@
+package azure
+
+import (
+	"fmt"
+	"gogurt/internal/config"
+	embopenai "gogurt/internal/embeddings/openai"
+	"gogurt/internal/llm/retry"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// New creates an embedder for an Azure OpenAI embeddings deployment. Azure speaks the
+// OpenAI embeddings API, so the OpenAI embedder is reused with an Azure client.
+func New(cfg *config.Config) (*embopenai.Embedder, error) {
+	return NewWithHTTPClient(cfg, retry.HTTPClient())
+}
+
+func NewWithHTTPClient(cfg *config.Config, httpClient *http.Client) (*embopenai.Embedder, error) {
+	if cfg.AzureOpenAIEndpoint == "" || cfg.AzureEmbedDeployment == "" {
+		return nil, fmt.Errorf("azure embeddings need AZURE_OPENAI_ENDPOINT and AZURE_OPENAI_EMBED_DEPLOYMENT_NAME")
+	}
+	clientCfg := openai.DefaultAzureConfig(cfg.AzureOpenAIAPIKey, cfg.AzureOpenAIEndpoint)
+	clientCfg.HTTPClient = httpClient
+	client := openai.NewClientWithConfig(clientCfg)
+	return embopenai.NewWithClient(client, cfg.AzureEmbedDeployment, cfg.EmbeddingsDimensions, cfg.EmbeddingsBatchSize), nil
+}
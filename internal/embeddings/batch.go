@@ -0,0 +1,218 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"gogurt/internal/types"
+)
+
+const (
+	// DefaultBatchSize is the number of documents per request when an embedder does not
+	// report its own.
+	DefaultBatchSize = 64
+	// DefaultWorkers is the number of concurrent requests used by vector stores.
+	DefaultWorkers = 4
+)
+
+// BatchOptions control EmbedBatches.
+type BatchOptions struct {
+	// BatchSize is the number of documents per EmbedDocuments call; defaults to BatchSizeOf.
+	BatchSize int
+	// Workers is the number of batches embedded concurrently; defaults to 1.
+	Workers int
+	// MaxRetries is the number of extra passes over documents that failed.
+	MaxRetries int
+	// OnProgress is called after each batch; defaults to the callback attached with WithProgress.
+	OnProgress func(Progress)
+}
+
+// Progress reports how many documents of Total have been embedded or have failed.
+type Progress struct {
+	Done   int
+	Failed int
+	Total  int
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose batch embeddings report progress to fn, so callers
+// can follow embedding done deep inside e.g. a vector store.
+func WithProgress(ctx context.Context, fn func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func progressFrom(ctx context.Context) func(Progress) {
+	fn, _ := ctx.Value(progressKey{}).(func(Progress))
+	return fn
+}
+
+// BatchSizeOf returns the batch size an embedder was configured with, or DefaultBatchSize.
+func BatchSizeOf(e Embedder) int {
+	if b, ok := e.(interface{ BatchSize() int }); ok && b.BatchSize() > 0 {
+		return b.BatchSize()
+	}
+	return DefaultBatchSize
+}
+
+// BatchError reports the documents that could not be embedded, by index. It is returned
+// together with the embeddings of all other documents; failed ones are nil.
+type BatchError struct {
+	Errors map[int]error
+	// IDs holds the IDs of the failed documents, by the same indices as Errors.
+	IDs   map[int]string
+	Total int
+}
+
+func (e *BatchError) Error() string {
+	failed := e.Failed()
+	var details []string
+	for _, i := range failed[:min(len(failed), 3)] {
+		details = append(details, fmt.Sprintf("document %d: %v", i, e.Errors[i]))
+	}
+	if len(failed) > 3 {
+		details = append(details, "...")
+	}
+	return fmt.Sprintf("failed to embed %d of %d documents: %s", len(failed), e.Total, strings.Join(details, "; "))
+}
+
+// Failed returns the indices of the failed documents in ascending order.
+func (e *BatchError) Failed() []int {
+	failed := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		failed = append(failed, i)
+	}
+	slices.Sort(failed)
+	return failed
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, i := range e.Failed() {
+		errs = append(errs, e.Errors[i])
+	}
+	return errs
+}
+
+// EmbedBatches embeds docs with EmbedDocuments in concurrent batches and returns the
+// embeddings in the order of docs. When a batch fails, its documents are embedded one by
+// one so a single bad document does not fail its neighbours; documents that still fail
+// are retried up to MaxRetries times and then reported in a *BatchError, alongside the
+// embeddings of every other document. Cancelling ctx stops the work and returns ctx.Err().
+func EmbedBatches(ctx context.Context, e Embedder, docs []types.Document, opts BatchOptions) ([][]float32, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = BatchSizeOf(e)
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.OnProgress == nil {
+		opts.OnProgress = progressFrom(ctx)
+	}
+
+	b := &batcher{embedder: e, docs: docs, opts: opts, result: make([][]float32, len(docs)), errs: map[int]error{}}
+	pending := make([]int, len(docs))
+	for i := range pending {
+		pending[i] = i
+	}
+	for attempt := 0; len(pending) > 0 && attempt <= opts.MaxRetries; attempt++ {
+		b.run(ctx, pending)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pending = pending[:0]
+		for i := range b.errs {
+			pending = append(pending, i)
+		}
+		slices.Sort(pending)
+	}
+	if len(b.errs) > 0 {
+		ids := make(map[int]string, len(b.errs))
+		for i := range b.errs {
+			ids[i] = docs[i].ID
+		}
+		return b.result, &BatchError{Errors: b.errs, IDs: ids, Total: len(docs)}
+	}
+	return b.result, nil
+}
+
+type batcher struct {
+	embedder Embedder
+	docs     []types.Document
+	opts     BatchOptions
+
+	mu     sync.Mutex
+	result [][]float32
+	errs   map[int]error
+	done   int
+}
+
+// run embeds the documents at indices, recording results and errors.
+func (b *batcher) run(ctx context.Context, indices []int) {
+	work := make(chan []int)
+	var wg sync.WaitGroup
+	for range b.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range work {
+				b.embedBatch(ctx, batch)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(work)
+	for start := 0; start < len(indices); start += b.opts.BatchSize {
+		select {
+		case work <- indices[start:min(start+b.opts.BatchSize, len(indices))]:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *batcher) embedBatch(ctx context.Context, batch []int) {
+	docs := make([]types.Document, len(batch))
+	for i, index := range batch {
+		docs[i] = b.docs[index]
+	}
+	vectors, err := b.embedder.EmbedDocuments(ctx, docs)
+	if err == nil && len(vectors) != len(batch) {
+		err = fmt.Errorf("expected %d embeddings, got %d", len(batch), len(vectors))
+	}
+	errs := make([]error, len(batch))
+	if err != nil {
+		if len(batch) == 1 || ctx.Err() != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+		} else {
+			// isolate the documents that caused the failure
+			vectors = make([][]float32, len(batch))
+			for i, doc := range docs {
+				vectors[i], errs[i] = b.embedder.EmbedQuery(ctx, doc.PageContent)
+			}
+		}
+	}
+	b.record(batch, vectors, errs)
+}
+
+func (b *batcher) record(batch []int, vectors [][]float32, errs []error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, index := range batch {
+		if errs[i] != nil {
+			b.errs[index] = errs[i]
+			continue
+		}
+		delete(b.errs, index)
+		b.result[index] = vectors[i]
+		b.done++
+	}
+	if b.opts.OnProgress != nil {
+		b.opts.OnProgress(Progress{Done: b.done, Failed: len(b.errs), Total: len(b.docs)})
+	}
+}
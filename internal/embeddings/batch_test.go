@@ -0,0 +1,105 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"gogurt/internal/types"
+)
+
+// stubEmbedder embeds a text as its length and fails on texts containing "bad". Texts
+// containing "flaky" fail on their first attempt only.
+type stubEmbedder struct {
+	Embedder
+	mu      sync.Mutex
+	batches int
+	seen    map[string]bool
+}
+
+func (s *stubEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.Contains(text, "bad") {
+		return nil, errors.New("rejected")
+	}
+	if strings.Contains(text, "flaky") && !s.seen[text] {
+		s.seen[text] = true
+		return nil, errors.New("unavailable")
+	}
+	return []float32{float32(len(text))}, nil
+}
+
+func (s *stubEmbedder) EmbedDocuments(ctx context.Context, docs []types.Document) ([][]float32, error) {
+	s.mu.Lock()
+	s.batches++
+	s.mu.Unlock()
+	var out [][]float32
+	for _, doc := range docs {
+		v, err := s.EmbedQuery(ctx, doc.PageContent)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func docs(texts ...string) []types.Document {
+	out := make([]types.Document, len(texts))
+	for i, text := range texts {
+		out[i] = types.Document{PageContent: text}
+	}
+	return out
+}
+
+func TestEmbedBatchesKeepsOrder(t *testing.T) {
+	e := &stubEmbedder{seen: map[string]bool{}}
+	in := docs("a", "bb", "ccc", "dddd", "eeeee")
+	var last Progress
+	got, err := EmbedBatches(context.Background(), e, in, BatchOptions{BatchSize: 2, Workers: 3, OnProgress: func(p Progress) { last = p }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, doc := range in {
+		if got[i][0] != float32(len(doc.PageContent)) {
+			t.Errorf("embedding %d out of order: %v", i, got)
+		}
+	}
+	if e.batches != 3 {
+		t.Errorf("expected 3 batches, got %d", e.batches)
+	}
+	if last != (Progress{Done: 5, Total: 5}) {
+		t.Errorf("unexpected final progress %+v", last)
+	}
+}
+
+func TestEmbedBatchesIsolatesAndRetriesFailures(t *testing.T) {
+	e := &stubEmbedder{seen: map[string]bool{}}
+	in := docs("ok", "bad", "flaky", "fine")
+	in[1].ID = "doc-bad"
+	got, err := EmbedBatches(context.Background(), e, in, BatchOptions{BatchSize: 4, MaxRetries: 1})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a BatchError, got %v", err)
+	}
+	if failed := batchErr.Failed(); len(failed) != 1 || failed[0] != 1 {
+		t.Errorf("expected only document 1 to fail, got %v", failed)
+	}
+	if batchErr.IDs[1] != "doc-bad" {
+		t.Errorf("expected the failed document's ID, got %v", batchErr.IDs)
+	}
+	if got[0] == nil || got[1] != nil || got[2] == nil || got[3] == nil {
+		t.Errorf("unexpected partial result %v", got)
+	}
+}
+
+func TestEmbedBatchesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := EmbedBatches(ctx, &stubEmbedder{seen: map[string]bool{}}, docs("a", "b"), BatchOptions{BatchSize: 1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+
+	"gogurt/internal/embeddings"
+	"gogurt/internal/logger"
+	"gogurt/internal/types"
+)
+
+// CachedEmbedder reuses vectors from a DiskStore, keyed by the embedding model and the
+// SHA-256 of the text, so unchanged text is never embedded twice.
+type CachedEmbedder struct {
+	next  embeddings.Embedder
+	store *DiskStore
+	model string
+
+	hits, misses atomic.Int64
+}
+
+// New caches the vectors of next. model identifies the embedding model and any setting
+// that changes its vectors, e.g. "openai:text-embedding-3-small:256"; vectors from
+// different models never mix.
+func New(next embeddings.Embedder, store *DiskStore, model string) *CachedEmbedder {
+	return &CachedEmbedder{next: next, store: store, model: model}
+}
+
+// Stats counts lookups and reports the size of the store.
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+}
+
+func (c *CachedEmbedder) Stats() Stats {
+	entries, bytes := c.store.Len()
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.store.Evictions(),
+		Entries:   entries,
+		Bytes:     bytes,
+	}
+}
+
+func (c *CachedEmbedder) key(text string) string {
+	sum := sha256.Sum256([]byte(c.model + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *CachedEmbedder) get(text string) ([]float32, bool) {
+	vector, ok := c.store.Get(c.key(text))
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return vector, ok
+}
+
+func (c *CachedEmbedder) put(ctx context.Context, text string, vector []float32) {
+	if err := c.store.Put(c.key(text), vector); err != nil {
+		logger.WarnCtx(ctx, "embedding cache: failed to store vector: %v", err)
+	}
+}
+
+func (c *CachedEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	if vector, ok := c.get(text); ok {
+		return vector, nil
+	}
+	vector, err := c.next.EmbedQuery(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	c.put(ctx, text, vector)
+	return vector, nil
+}
+
+// EmbedDocuments embeds only the documents that are not cached, in one call.
+func (c *CachedEmbedder) EmbedDocuments(ctx context.Context, docs []types.Document) ([][]float32, error) {
+	result := make([][]float32, len(docs))
+	var missing []types.Document
+	var missingAt []int
+	for i, doc := range docs {
+		if vector, ok := c.get(doc.PageContent); ok {
+			result[i] = vector
+			continue
+		}
+		missing = append(missing, doc)
+		missingAt = append(missingAt, i)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+	vectors, err := c.next.EmbedDocuments(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(missing) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(missing), len(vectors))
+	}
+	for j, vector := range vectors {
+		result[missingAt[j]] = vector
+		c.put(ctx, missing[j].PageContent, vector)
+	}
+	return result, nil
+}
+
+// EmbedAll embeds docs in batches with up to workers concurrent requests; cached
+// documents are left out of the requests.
+func (c *CachedEmbedder) EmbedAll(ctx context.Context, docs []types.Document, workers int) ([][]float32, error) {
+	return embeddings.EmbedBatches(ctx, c, docs, embeddings.BatchOptions{Workers: workers, MaxRetries: 1})
+}
+
+// BatchSize is the wrapped embedder's batch size.
+func (c *CachedEmbedder) BatchSize() int {
+	return embeddings.BatchSizeOf(c.next)
+}
+
+// AEmbedQuery provides an asynchronous EmbedQuery.
+func (c *CachedEmbedder) AEmbedQuery(ctx context.Context, text string) (<-chan []float32, <-chan error) {
+	out := make(chan []float32, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		embedding, err := c.EmbedQuery(ctx, text)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		out <- embedding
+	}()
+	return out, errCh
+}
+
+// AEmbedDocuments provides an asynchronous EmbedDocuments.
+func (c *CachedEmbedder) AEmbedDocuments(ctx context.Context, docs []types.Document) (<-chan [][]float32, <-chan error) {
+	out := make(chan [][]float32, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		embeddings, err := c.EmbedDocuments(ctx, docs)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		out <- embeddings
+	}()
+	return out, errCh
+}
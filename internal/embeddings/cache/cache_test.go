@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gogurt/internal/embeddings"
+	"gogurt/internal/types"
+)
+
+// countingEmbedder embeds a text as its length and records what it was asked to embed.
+type countingEmbedder struct {
+	embeddings.Embedder
+	embedded []string
+}
+
+func (e *countingEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	e.embedded = append(e.embedded, text)
+	return []float32{float32(len(text)), 1}, nil
+}
+
+func (e *countingEmbedder) EmbedDocuments(ctx context.Context, docs []types.Document) ([][]float32, error) {
+	var out [][]float32
+	for _, doc := range docs {
+		v, _ := e.EmbedQuery(ctx, doc.PageContent)
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func TestOnlyNewTextIsEmbedded(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := &countingEmbedder{}
+	c := New(next, store, "ollama:nomic-embed-text")
+	ctx := context.Background()
+
+	if _, err := c.EmbedDocuments(ctx, []types.Document{{PageContent: "a"}, {PageContent: "bb"}}); err != nil {
+		t.Fatal(err)
+	}
+	// a new store on the same directory sees the vectors written before
+	store, _ = NewDiskStore(dir, 0)
+	c = New(next, store, "ollama:nomic-embed-text")
+	got, err := c.EmbedDocuments(ctx, []types.Document{{PageContent: "bb"}, {PageContent: "ccc"}, {PageContent: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0][0] != 2 || got[1][0] != 3 || got[2][0] != 1 {
+		t.Errorf("unexpected embeddings %v", got)
+	}
+	if len(next.embedded) != 3 || next.embedded[2] != "ccc" {
+		t.Errorf("expected only ccc to be embedded again, got %v", next.embedded)
+	}
+	if s := c.Stats(); s.Hits != 2 || s.Misses != 1 || s.Entries != 3 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	// another model does not share vectors
+	other := New(next, store, "openai:text-embedding-3-small")
+	other.EmbedQuery(ctx, "a")
+	if len(next.embedded) != 4 {
+		t.Error("expected a miss for a different model")
+	}
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	entrySize := int64(len(encode(make([]float32, 4))))
+	store, err := NewDiskStore(t.TempDir(), 3*entrySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"aa01", "bb02", "cc03"} {
+		store.Put(key, make([]float32, 4))
+		time.Sleep(2 * time.Millisecond)
+	}
+	store.Get("aa01")
+	store.Put("dd04", make([]float32, 4))
+
+	if _, ok := store.Get("bb02"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if _, ok := store.Get("aa01"); !ok {
+		t.Error("expected the recently read entry to be kept")
+	}
+	if entries, bytes := store.Len(); entries != 2 || bytes != 2*entrySize || store.Evictions() != 2 {
+		t.Errorf("unexpected size %d entries, %d bytes, %d evictions", entries, bytes, store.Evictions())
+	}
+}
+
+func TestCorruptFileIsIgnored(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewDiskStore(dir, 0)
+	store.Put("abcd", []float32{1, 2})
+	os.WriteFile(filepath.Join(dir, "ab", "abcd.vec"), []byte("junk"), 0o644)
+	if _, ok := store.Get("abcd"); ok {
+		t.Error("expected a corrupt entry to be a miss")
+	}
+	if entries, _ := store.Len(); entries != 0 {
+		t.Error("expected the corrupt entry to be dropped")
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	in := []float32{0.5, -1.25, 3e-7}
+	out, err := decode(encode(in))
+	if err != nil || len(out) != 3 || out[0] != in[0] || out[1] != in[1] || out[2] != in[2] {
+		t.Errorf("round trip gave %v, %v", out, err)
+	}
+}
+
+func TestConcurrentPutsOfTheSameKey(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- store.Put("abcdef", []float32{1, 2, 3})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if v, ok := store.Get("abcdef"); !ok || len(v) != 3 {
+		t.Errorf("expected the vector to be stored, got %v", v)
+	}
+}
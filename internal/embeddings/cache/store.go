@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// magic starts every vector file, followed by a format version byte.
+var magic = [4]byte{'G', 'G', 'E', 'V'}
+
+const version = 1
+
+// headerSize is magic, version and a uint32 dimension count.
+const headerSize = len(magic) + 1 + 4
+
+// DiskStore keeps one vector per file under a directory, named by its key and sharded by
+// the key's first two characters. A file is a short header followed by the vector as
+// little-endian float32s. When the files exceed maxBytes, the least recently used are
+// deleted. An index of the files is kept in memory.
+type DiskStore struct {
+	dir      string
+	maxBytes int64
+
+	mu        sync.Mutex
+	index     map[string]*fileInfo
+	size      int64
+	evictions int64
+}
+
+type fileInfo struct {
+	size int64
+	used time.Time
+}
+
+// NewDiskStore opens or creates a store in dir holding up to maxBytes of vectors; 0 means
+// unbounded.
+func NewDiskStore(dir string, maxBytes int64) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &DiskStore{dir: dir, maxBytes: maxBytes, index: make(map[string]*fileInfo)}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".vec" {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		key := filepath.Base(path)
+		key = key[:len(key)-len(".vec")]
+		s.index[key] = &fileInfo{size: info.Size(), used: info.ModTime()}
+		s.size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.evict()
+	s.mu.Unlock()
+	return s, nil
+}
+
+func (s *DiskStore) path(key string) string {
+	return filepath.Join(s.dir, key[:min(2, len(key))], key+".vec")
+}
+
+// Get returns the vector stored under key.
+func (s *DiskStore) Get(key string) ([]float32, bool) {
+	s.mu.Lock()
+	info, ok := s.index[key]
+	if ok {
+		info.used = time.Now()
+	}
+	s.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		s.remove(key)
+		return nil, false
+	}
+	vector, err := decode(data)
+	if err != nil {
+		s.remove(key)
+		return nil, false
+	}
+	// keep the recency across restarts
+	now := time.Now()
+	os.Chtimes(s.path(key), now, now)
+	return vector, true
+}
+
+// Put stores vector under key, evicting old vectors if the store is full.
+func (s *DiskStore) Put(key string, vector []float32) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data := encode(vector)
+	// write then rename so readers never see a partial file; the temporary name is unique
+	// because concurrent workers may store the same text at once
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.index[key]; ok {
+		s.size -= old.size
+	}
+	s.index[key] = &fileInfo{size: int64(len(data)), used: time.Now()}
+	s.size += int64(len(data))
+	s.evict()
+	return nil
+}
+
+func (s *DiskStore) remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info, ok := s.index[key]; ok {
+		s.size -= info.size
+		delete(s.index, key)
+	}
+	os.Remove(s.path(key))
+}
+
+// evict deletes least recently used files until the store is at 90% of maxBytes, so
+// that a full store does not evict on every Put. s.mu must be held.
+func (s *DiskStore) evict() {
+	if s.maxBytes <= 0 || s.size <= s.maxBytes {
+		return
+	}
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int { return s.index[a].used.Compare(s.index[b].used) })
+	target := s.maxBytes * 9 / 10
+	for _, key := range keys {
+		if s.size <= target {
+			break
+		}
+		s.size -= s.index[key].size
+		delete(s.index, key)
+		os.Remove(s.path(key))
+		s.evictions++
+	}
+}
+
+// Len returns the number of stored vectors and their size in bytes.
+func (s *DiskStore) Len() (entries int, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.index), s.size
+}
+
+// Evictions returns the number of vectors evicted since the store was opened.
+func (s *DiskStore) Evictions() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.evictions
+}
+
+func encode(vector []float32) []byte {
+	data := make([]byte, headerSize+4*len(vector))
+	copy(data, magic[:])
+	data[len(magic)] = version
+	binary.LittleEndian.PutUint32(data[len(magic)+1:], uint32(len(vector)))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[headerSize+4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+var errCorrupt = errors.New("corrupt vector file")
+
+func decode(data []byte) ([]float32, error) {
+	if len(data) < headerSize || [4]byte(data[:4]) != magic {
+		return nil, errCorrupt
+	}
+	if data[len(magic)] != version {
+		return nil, fmt.Errorf("%w: unknown version %d", errCorrupt, data[len(magic)])
+	}
+	n := int(binary.LittleEndian.Uint32(data[len(magic)+1:]))
+	if len(data) != headerSize+4*n {
+		return nil, errCorrupt
+	}
+	vector := make([]float32, n)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[headerSize+4*i:]))
+	}
+	return vector, nil
+}
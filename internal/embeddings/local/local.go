@@ -0,0 +1,138 @@
+package local
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"gogurt/internal/embeddings"
+	"gogurt/internal/types"
+)
+
+// DefaultDimensions is the size of the vectors when none is configured.
+const DefaultDimensions = 512
+
+// charGramWeight down-weights character trigrams, which are many per word, against words.
+const charGramWeight = 0.5
+
+// Embedder embeds text without a model server, by feature hashing: words, word bigrams
+// and character trigrams are hashed into a fixed number of dimensions and weighted by
+// sublinear term frequency. Vectors are L2-normalized. It is meant for tests, CI and
+// offline use, and as a lexical baseline for neural embeddings.
+//
+// There is no IDF weighting: a vector depends only on its text, so vectors stored by one
+// process stay comparable with queries embedded by another. BM25 retrieval covers term
+// rarity.
+type Embedder struct {
+	dims int
+}
+
+// New returns an embedder producing vectors of dims dimensions; 0 means DefaultDimensions.
+func New(dims int) *Embedder {
+	if dims <= 0 {
+		dims = DefaultDimensions
+	}
+	return &Embedder{dims: dims}
+}
+
+// features returns the weighted term frequency of each feature bucket of text. The sign
+// of a bucket's weight comes from the hash too, so collisions tend to cancel out.
+func (e *Embedder) features(text string) map[int]float64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tf := make(map[int]float64)
+	add := func(feature string, weight float64) {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		sum := h.Sum32()
+		bucket := int(sum % uint32(e.dims))
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		tf[bucket] += weight
+	}
+	for i, word := range words {
+		add("w:"+word, 1)
+		if i > 0 {
+			add("b:"+words[i-1]+" "+word, 1)
+		}
+		padded := []rune("<" + word + ">")
+		for j := 0; j+3 <= len(padded); j++ {
+			add("c:"+string(padded[j:j+3]), charGramWeight)
+		}
+	}
+	return tf
+}
+
+// Vector embeds text.
+func (e *Embedder) Vector(text string) []float32 {
+	vector := make([]float32, e.dims)
+	var norm float64
+	for bucket, tf := range e.features(text) {
+		if tf == 0 {
+			continue
+		}
+		// sublinear tf, keeping the hashed sign
+		w := math.Copysign(1+math.Log(math.Abs(tf)), tf)
+		vector[bucket] = float32(w)
+		norm += w * w
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
+
+func (e *Embedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return e.Vector(text), nil
+}
+
+func (e *Embedder) EmbedDocuments(ctx context.Context, docs []types.Document) ([][]float32, error) {
+	vectors := make([][]float32, len(docs))
+	for i, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = e.Vector(doc.PageContent)
+	}
+	return vectors, nil
+}
+
+func (e *Embedder) EmbedAll(ctx context.Context, docs []types.Document, workers int) ([][]float32, error) {
+	return embeddings.EmbedBatches(ctx, e, docs, embeddings.BatchOptions{Workers: workers})
+}
+
+// AEmbedQuery provides an asynchronous EmbedQuery.
+func (e *Embedder) AEmbedQuery(ctx context.Context, text string) (<-chan []float32, <-chan error) {
+	out := make(chan []float32, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		out <- e.Vector(text)
+	}()
+	return out, errCh
+}
+
+// AEmbedDocuments provides an asynchronous EmbedDocuments.
+func (e *Embedder) AEmbedDocuments(ctx context.Context, docs []types.Document) (<-chan [][]float32, <-chan error) {
+	out := make(chan [][]float32, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		vectors, err := e.EmbedDocuments(ctx, docs)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		out <- vectors
+	}()
+	return out, errCh
+}
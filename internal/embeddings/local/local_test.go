@@ -0,0 +1,66 @@
+package local
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores/simple"
+)
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func TestVectorsAreNormalized(t *testing.T) {
+	e := New(64)
+	v := e.Vector("The quick brown fox jumps over the lazy dog")
+	if len(v) != 64 {
+		t.Fatalf("expected 64 dimensions, got %d", len(v))
+	}
+	if n := dot(v, v); math.Abs(n-1) > 1e-5 {
+		t.Errorf("expected a unit vector, got norm² %f", n)
+	}
+	if n := dot(e.Vector(""), e.Vector("")); n != 0 {
+		t.Errorf("expected a zero vector for empty text, got norm² %f", n)
+	}
+	if len(New(0).Vector("x")) != DefaultDimensions {
+		t.Error("expected the default dimensions")
+	}
+}
+
+func TestSimilarTextScoresHigher(t *testing.T) {
+	e := New(0)
+	query := e.Vector("how do I configure the embedding cache")
+	near := e.Vector("Configuring the embeddings cache directory")
+	far := e.Vector("Bananas are rich in potassium")
+	if dot(query, near) <= dot(query, far) {
+		t.Errorf("expected related text to score higher: %f <= %f", dot(query, near), dot(query, far))
+	}
+}
+
+func TestRetrievesWithSimpleStore(t *testing.T) {
+	ctx := context.Background()
+	docs := []types.Document{
+		{PageContent: "Ollama serves local models over HTTP on port 11434."},
+		{PageContent: "Chroma is a vector database with a REST API."},
+		{PageContent: "The tokenizer estimates token counts for rate limiting."},
+	}
+	store := simple.New(New(0))
+	if err := <-store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	out, errCh := store.SimilaritySearch(ctx, "which port does ollama listen on", 1)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	got := <-out
+	if len(got) != 1 || got[0].PageContent != docs[0].PageContent {
+		t.Errorf("unexpected result %v", got)
+	}
+}
@@ -4,28 +4,80 @@ import (
 	"context"
 	"fmt"
 	"gogurt/internal/config"
+	"gogurt/internal/embeddings"
+	llmollama "gogurt/internal/llm/ollama"
 	"gogurt/internal/types"
+	"maps"
+	"net/http"
 	"sync"
 
 	"github.com/ollama/ollama/api"
 )
 
 type Embedder struct {
-	client *api.Client
-	model  string
+	client    *api.Client
+	model     string
+	options   map[string]any
+	keepAlive *api.Duration
+	autoPull  bool
+	batchSize int
+
+	pullMu sync.Mutex
+	pulled bool
 }
 
 func New(cfg *config.Config) (*Embedder, error) {
-	client, err := api.ClientFromEnvironment()
+	return NewWithHTTPClient(cfg, http.DefaultClient)
+}
+
+func NewWithHTTPClient(cfg *config.Config, httpClient *http.Client) (*Embedder, error) {
+	client, err := llmollama.NewClient(cfg, httpClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ollama client: %w", err)
+		return nil, err
 	}
+	keepAlive, err := llmollama.KeepAlive(cfg.OllamaKeepAlive)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := cfg.EmbeddingsBatchSize
+	if batchSize <= 0 {
+		batchSize = embeddings.DefaultBatchSize
+	}
+	// OLLAMA_NUM_CTX sizes the chat model's context; the embedding model keeps its own
+	options := llmollama.Options(cfg)
+	delete(options, "num_ctx")
 	return &Embedder{
-		client: client,
-		model:  cfg.OllamaEmbedModel,
+		client:    client,
+		model:     cfg.OllamaEmbedModel,
+		options:   options,
+		keepAlive: keepAlive,
+		autoPull:  cfg.OllamaAutoPull,
+		batchSize: batchSize,
 	}, nil
 }
 
+// ensureModel pulls the embedding model on first use when auto-pull is enabled.
+func (e *Embedder) ensureModel(ctx context.Context) error {
+	if !e.autoPull {
+		return nil
+	}
+	e.pullMu.Lock()
+	defer e.pullMu.Unlock()
+	if e.pulled {
+		return nil
+	}
+	if err := llmollama.EnsureModel(ctx, e.client, e.model); err != nil {
+		return err
+	}
+	e.pulled = true
+	return nil
+}
+
+// ListModels returns the models pulled to the server.
+func (e *Embedder) ListModels(ctx context.Context) ([]llmollama.Model, error) {
+	return llmollama.ListModels(ctx, e.client)
+}
+
 // Async: AEmbedDocuments
 func (e *Embedder) AEmbedDocuments(ctx context.Context, docs []types.Document) (<-chan [][]float32, <-chan error) {
 	out := make(chan [][]float32, 1)
@@ -60,33 +112,63 @@ func (e *Embedder) AEmbedQuery(ctx context.Context, text string) (<-chan []float
 	return out, errCh
 }
 
+// EmbedDocuments embeds documents with Ollama's batch endpoint, batchSize at a time.
 func (e *Embedder) EmbedDocuments(ctx context.Context, docs []types.Document) ([][]float32, error) {
-	embeddings := make([][]float32, len(docs))
-	for i, doc := range docs {
-		embedding, err := e.EmbedQuery(ctx, doc.PageContent)
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	embeddings := make([][]float32, 0, len(docs))
+	for start := 0; start < len(docs); start += e.batchSize {
+		batch := docs[start:min(start+e.batchSize, len(docs))]
+		inputs := make([]string, len(batch))
+		for i, doc := range batch {
+			inputs[i] = doc.PageContent
+		}
+		batchEmbeddings, err := e.embed(ctx, inputs)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("documents %d-%d: %w", start, start+len(batch)-1, err)
 		}
-		embeddings[i] = embedding
+		embeddings = append(embeddings, batchEmbeddings...)
 	}
 	return embeddings, nil
 }
 
+// EmbedQuery uses the same endpoint as EmbedDocuments, so queries and documents are
+// normalized alike.
 func (e *Embedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
-	req := &api.EmbeddingRequest{
-		Model:  e.model,
-		Prompt: text,
+	embeddings, err := e.embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
 	}
-	res, err := e.client.Embeddings(ctx, req)
+	return embeddings[0], nil
+}
+
+// embed sends inputs in one /api/embed request, returning embeddings in input order.
+func (e *Embedder) embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	if err := e.ensureModel(ctx); err != nil {
+		return nil, err
+	}
+	req := &api.EmbedRequest{
+		Model:     e.model,
+		Input:     inputs,
+		KeepAlive: e.keepAlive,
+	}
+	if len(e.options) > 0 {
+		req.Options = maps.Clone(e.options)
+	}
+	res, err := e.client.Embed(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	// convert []float64 to []float32
-	embeddingF32 := make([]float32, len(res.Embedding))
-	for i, v := range res.Embedding {
-		embeddingF32[i] = float32(v)
+	if len(res.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(res.Embeddings))
 	}
-	return embeddingF32, nil
+	return res.Embeddings, nil
+}
+
+// BatchSize is the number of documents sent per request.
+func (e *Embedder) BatchSize() int {
+	return e.batchSize
 }
 
 // Async: AEmbedAll
@@ -106,36 +188,8 @@ func (e *Embedder) AEmbedAll(ctx context.Context, docs []types.Document, workers
 	return out, errCh
 }
 
+// EmbedAll embeds docs in batches with up to workers concurrent requests. Embeddings keep
+// the order of docs; documents that fail are reported in an *embeddings.BatchError.
 func (e *Embedder) EmbedAll(ctx context.Context, docs []types.Document, workers int) ([][]float32, error) {
-	work := make(chan types.Document)
-	out := make(chan []float32)
-	go func() {
-		for _, doc := range docs {
-			work <- doc
-		}
-		close(work)
-	}()
-	var wg sync.WaitGroup
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for doc := range work {
-				emb, err := e.EmbedQuery(ctx, doc.PageContent)
-				if err != nil {
-					continue // You might want to send the error out
-				}
-				out <- emb
-			}
-		}()
-	}
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
-	var result [][]float32
-	for emb := range out {
-		result = append(result, emb)
-	}
-	return result, nil
+	return embeddings.EmbedBatches(ctx, e, docs, embeddings.BatchOptions{Workers: workers, MaxRetries: 1})
 }
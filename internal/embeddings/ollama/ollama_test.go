@@ -0,0 +1,109 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gogurt/internal/config"
+	"gogurt/internal/types"
+)
+
+// fakeOllama serves /api/embed, embedding "doc N" as [N]. An input containing "drop"
+// makes the server return one embedding too few.
+type fakeOllama struct {
+	mu       sync.Mutex
+	requests []map[string]any
+}
+
+func (f *fakeOllama) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Input   []string       `json:"input"`
+		Options map[string]any `json:"options"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	f.mu.Lock()
+	f.requests = append(f.requests, map[string]any{"input": body.Input, "options": body.Options})
+	f.mu.Unlock()
+
+	var embeddings [][]float32
+	for _, input := range body.Input {
+		if strings.Contains(input, "drop") {
+			continue
+		}
+		n, _ := strconv.Atoi(strings.TrimPrefix(input, "doc "))
+		embeddings = append(embeddings, []float32{float32(n)})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"model": "nomic-embed-text", "embeddings": embeddings})
+}
+
+func newTestEmbedder(t *testing.T, srv *fakeOllama, cfg config.Config) *Embedder {
+	t.Helper()
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	cfg.OllamaHost = ts.URL
+	cfg.OllamaEmbedModel = "nomic-embed-text"
+	e, err := NewWithHTTPClient(&cfg, ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEmbedDocumentsBatchesInOrder(t *testing.T) {
+	srv := &fakeOllama{}
+	e := newTestEmbedder(t, srv, config.Config{EmbeddingsBatchSize: 2})
+	var docs []types.Document
+	for i := range 5 {
+		docs = append(docs, types.Document{PageContent: "doc " + strconv.Itoa(i)})
+	}
+
+	vectors, err := e.EmbedDocuments(context.Background(), docs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vectors) != 5 {
+		t.Fatalf("expected 5 embeddings, got %d", len(vectors))
+	}
+	for i, v := range vectors {
+		if len(v) != 1 || v[0] != float32(i) {
+			t.Fatalf("expected embeddings in input order, got %v", vectors)
+		}
+	}
+	var sizes []int
+	for _, req := range srv.requests {
+		sizes = append(sizes, len(req["input"].([]string)))
+	}
+	if !slices.Equal(sizes, []int{2, 2, 1}) {
+		t.Errorf("expected batches of 2, 2 and 1, got %v", sizes)
+	}
+}
+
+func TestEmbedReportsCountMismatch(t *testing.T) {
+	e := newTestEmbedder(t, &fakeOllama{}, config.Config{})
+	docs := []types.Document{{PageContent: "doc 0"}, {PageContent: "drop me"}}
+	if _, err := e.EmbedDocuments(context.Background(), docs); err == nil || !strings.Contains(err.Error(), "expected 2 embeddings, got 1") {
+		t.Fatalf("expected a count mismatch error, got %v", err)
+	}
+}
+
+func TestEmbedSendsOnlyEmbeddingOptions(t *testing.T) {
+	srv := &fakeOllama{}
+	e := newTestEmbedder(t, srv, config.Config{OllamaNumCtx: 8192, OllamaNumThread: 4})
+	if _, err := e.EmbedQuery(context.Background(), "doc 1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	options, _ := srv.requests[0]["options"].(map[string]any)
+	if _, ok := options["num_ctx"]; ok {
+		t.Errorf("expected the chat model's num_ctx to be left out, got %v", options)
+	}
+	if options["num_thread"] != float64(4) {
+		t.Errorf("expected num_thread to be sent, got %v", options)
+	}
+}
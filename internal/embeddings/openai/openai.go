@@ -0,0 +1,164 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"gogurt/internal/config"
+	"gogurt/internal/embeddings"
+	"gogurt/internal/llm/retry"
+	"gogurt/internal/types"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultBatchSize is the number of inputs sent per embeddings request; the API accepts
+// up to 2048.
+const DefaultBatchSize = 256
+
+type Embedder struct {
+	client *openai.Client
+	model  string
+	// dimensions shortens embeddings; 0 keeps the model's size
+	dimensions int
+	batchSize  int
+}
+
+// NewWithClient creates an embedder for model on any client speaking the OpenAI
+// embeddings API, including Azure.
+func NewWithClient(client *openai.Client, model string, dimensions, batchSize int) *Embedder {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Embedder{client: client, model: model, dimensions: dimensions, batchSize: batchSize}
+}
+
+func New(cfg *config.Config) (*Embedder, error) {
+	return NewWithHTTPClient(cfg, retry.HTTPClient())
+}
+
+func NewWithHTTPClient(cfg *config.Config, httpClient *http.Client) (*Embedder, error) {
+	if cfg.OpenAIAPIKey == "" {
+		return nil, fmt.Errorf("openai api key not provided (OPENAI_API_KEY)")
+	}
+	// only text-embedding-3 and later models can shorten their output
+	if cfg.EmbeddingsDimensions > 0 && strings.HasPrefix(cfg.OpenAIEmbedModel, "text-embedding-ada") {
+		return nil, fmt.Errorf("EMBEDDINGS_DIMENSIONS is not supported by %s", cfg.OpenAIEmbedModel)
+	}
+	clientCfg := openai.DefaultConfig(cfg.OpenAIAPIKey)
+	clientCfg.HTTPClient = httpClient
+	return NewWithClient(openai.NewClientWithConfig(clientCfg), cfg.OpenAIEmbedModel, cfg.EmbeddingsDimensions, cfg.EmbeddingsBatchSize), nil
+}
+
+// NewCompatible creates an embedder for a server implementing the OpenAI embeddings API.
+func NewCompatible(cfg *config.Config) (*Embedder, error) {
+	if cfg.OpenAICompatibleBaseURL == "" {
+		return nil, fmt.Errorf("openai-compatible base url not provided (OPENAI_COMPATIBLE_BASE_URL)")
+	}
+	model := cfg.OpenAICompatibleEmbedModel
+	if model == "" {
+		model = cfg.OpenAICompatibleModel
+	}
+	clientCfg := openai.DefaultConfig(cfg.OpenAICompatibleAPIKey)
+	clientCfg.BaseURL = strings.TrimRight(cfg.OpenAICompatibleBaseURL, "/")
+	clientCfg.HTTPClient = retry.HTTPClient()
+	return NewWithClient(openai.NewClientWithConfig(clientCfg), model, cfg.EmbeddingsDimensions, cfg.EmbeddingsBatchSize), nil
+}
+
+// Async: AEmbedDocuments
+func (e *Embedder) AEmbedDocuments(ctx context.Context, docs []types.Document) (<-chan [][]float32, <-chan error) {
+	out := make(chan [][]float32, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		embeddings, err := e.EmbedDocuments(ctx, docs)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		out <- embeddings
+	}()
+	return out, errCh
+}
+
+// Async: AEmbedQuery
+func (e *Embedder) AEmbedQuery(ctx context.Context, text string) (<-chan []float32, <-chan error) {
+	out := make(chan []float32, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		embedding, err := e.EmbedQuery(ctx, text)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		out <- embedding
+	}()
+	return out, errCh
+}
+
+// EmbedDocuments embeds documents in requests of up to batchSize inputs each.
+func (e *Embedder) EmbedDocuments(ctx context.Context, docs []types.Document) ([][]float32, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	embeddings := make([][]float32, 0, len(docs))
+	for start := 0; start < len(docs); start += e.batchSize {
+		batch := docs[start:min(start+e.batchSize, len(docs))]
+		inputs := make([]string, len(batch))
+		for i, doc := range batch {
+			inputs[i] = doc.PageContent
+		}
+		batchEmbeddings, err := e.embed(ctx, inputs)
+		if err != nil {
+			return nil, fmt.Errorf("documents %d-%d: %w", start, start+len(batch)-1, err)
+		}
+		embeddings = append(embeddings, batchEmbeddings...)
+	}
+	return embeddings, nil
+}
+
+func (e *Embedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := e.embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedAll embeds docs in batches with up to workers concurrent requests. Embeddings keep
+// the order of docs; documents that fail are reported in an *embeddings.BatchError.
+func (e *Embedder) EmbedAll(ctx context.Context, docs []types.Document, workers int) ([][]float32, error) {
+	return embeddings.EmbedBatches(ctx, e, docs, embeddings.BatchOptions{Workers: workers, MaxRetries: 1})
+}
+
+// BatchSize is the number of documents sent per request.
+func (e *Embedder) BatchSize() int {
+	return e.batchSize
+}
+
+// embed sends inputs in one request, returning embeddings in input order.
+func (e *Embedder) embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	res, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input:      inputs,
+		Model:      openai.EmbeddingModel(e.model),
+		Dimensions: e.dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(res.Data))
+	}
+	embeddings := make([][]float32, len(inputs))
+	for _, d := range res.Data {
+		if d.Index < 0 || d.Index >= len(inputs) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	return embeddings, nil
+}
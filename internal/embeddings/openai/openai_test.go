@@ -0,0 +1,90 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gogurt/internal/config"
+	"gogurt/internal/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestEmbedDocumentsKeepsInputOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		// servers may return data out of order; index is authoritative
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer srv.Close()
+
+	embedder, err := NewCompatible(&config.Config{
+		OpenAICompatibleBaseURL:    srv.URL + "/v1",
+		OpenAICompatibleEmbedModel: "nomic-embed-text",
+	})
+	if err != nil {
+		t.Fatalf("NewCompatible: %v", err)
+	}
+	got, err := embedder.EmbedDocuments(context.Background(), []types.Document{{PageContent: "a"}, {PageContent: "b"}})
+	if err != nil {
+		t.Fatalf("EmbedDocuments: %v", err)
+	}
+	if len(got) != 2 || got[0][0] != 1 || got[1][1] != 1 {
+		t.Errorf("unexpected embeddings %v", got)
+	}
+}
+
+func TestEmbedDocumentsBatchesWithDimensions(t *testing.T) {
+	var batches []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input      []string `json:"input"`
+			Dimensions int      `json:"dimensions"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Dimensions != 2 {
+			t.Errorf("expected dimensions 2, got %d", req.Dimensions)
+		}
+		batches = append(batches, len(req.Input))
+		var data []string
+		for i, input := range req.Input {
+			data = append(data, fmt.Sprintf(`{"index":%d,"embedding":[%s,0]}`, i, input))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":[%s]}`, strings.Join(data, ","))
+	}))
+	defer srv.Close()
+
+	clientCfg := openai.DefaultConfig("test")
+	clientCfg.BaseURL = srv.URL
+	embedder := NewWithClient(openai.NewClientWithConfig(clientCfg), "text-embedding-3-small", 2, 2)
+	docs := []types.Document{{PageContent: "1"}, {PageContent: "2"}, {PageContent: "3"}}
+	got, err := embedder.EmbedDocuments(context.Background(), docs)
+	if err != nil {
+		t.Fatalf("EmbedDocuments: %v", err)
+	}
+	if len(batches) != 2 || batches[0] != 2 || batches[1] != 1 {
+		t.Errorf("expected batches of 2 and 1, got %v", batches)
+	}
+	for i := range docs {
+		if got[i][0] != float32(i+1) {
+			t.Errorf("embedding %d out of order: %v", i, got)
+		}
+	}
+}
+
+func TestDimensionsRejectedForAda(t *testing.T) {
+	_, err := New(&config.Config{OpenAIAPIKey: "test", OpenAIEmbedModel: "text-embedding-ada-002", EmbeddingsDimensions: 256})
+	if err == nil {
+		t.Error("expected an error for dimensions with ada")
+	}
+}
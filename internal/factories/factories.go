@@ -2,44 +2,271 @@ package factories
 
 import (
 	"context"
+	"fmt"
 	"gogurt/internal/config"
 	"gogurt/internal/embeddings"
+	embazure "gogurt/internal/embeddings/azure"
+	embcache "gogurt/internal/embeddings/cache"
+	"gogurt/internal/embeddings/local"
 	embollama "gogurt/internal/embeddings/ollama"
+	embopenai "gogurt/internal/embeddings/openai"
 	"gogurt/internal/llm"
 	"gogurt/internal/llm/azure"
+	"gogurt/internal/llm/cache"
+	"gogurt/internal/llm/fallback"
 	llmollama "gogurt/internal/llm/ollama"
 	"gogurt/internal/llm/openai"
+	"gogurt/internal/llm/retry"
+	"gogurt/internal/llm/tokenizer"
+	"gogurt/internal/llm/window"
 	"gogurt/internal/logger"
+	"gogurt/internal/ratelimit"
+	"gogurt/internal/retrievers"
+	"gogurt/internal/retrievers/bm25"
 	"gogurt/internal/splitters"
 	"gogurt/internal/splitters/character"
 	"gogurt/internal/splitters/markdown"
 	"gogurt/internal/splitters/recursive"
 	"gogurt/internal/vectorstores"
 	"gogurt/internal/vectorstores/chroma"
+	"gogurt/internal/vectorstores/hnsw"
 	"gogurt/internal/vectorstores/simple"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-// llm factory (synchronous)
+// llm factory (synchronous). LLM_PROVIDER may list several providers, e.g. "ollama,azure",
+// which are tried in order on every call. Retry breakers and the chain's record of which
+// providers are down are kept per provider for the life of the process.
 func GetLLM(cfg *config.Config) llm.LLM {
-	var llmModel llm.LLM
-	var err error
-	switch cfg.LLMProvider {
+	var chain []fallback.Provider
+	for _, name := range strings.Split(cfg.LLMProvider, ",") {
+		name = strings.TrimSpace(name)
+		model, err := newLLM(cfg, name)
+		if err != nil {
+			logger.Error("failed to create LLM %q: %v", name, err)
+			continue
+		}
+		model = withRateLimit(cfg, name, model)
+		if cfg.LLMMaxRetries > 0 || cfg.LLMBreakerThreshold > 0 {
+			model = retry.ForProvider(name, model, retry.Config{
+				MaxRetries:       cfg.LLMMaxRetries,
+				BaseDelay:        cfg.LLMRetryBaseDelay,
+				MaxDelay:         cfg.LLMRetryMaxDelay,
+				BreakerThreshold: cfg.LLMBreakerThreshold,
+				BreakerCooldown:  cfg.LLMBreakerCooldown,
+			})
+		}
+		chain = append(chain, fallback.Provider{Name: name, LLM: model})
+	}
+	var model llm.LLM
+	switch len(chain) {
+	case 0:
+		logger.Error("failed to create LLM: no usable provider in %q", cfg.LLMProvider)
+		os.Exit(1)
+	case 1:
+		model = chain[0].LLM
+	default:
+		f := fallback.New(cfg.LLMFallbackCooldown, chain...)
+		checkChainOnce(cfg.LLMProvider, f)
+		model = f
+	}
+	return withCache(cfg, withContextWindow(cfg, model))
+}
+
+// chainHealthTimeout bounds the health check of a new fallback chain.
+const chainHealthTimeout = 30 * time.Second
+
+var (
+	checkedChainsMu sync.Mutex
+	checkedChains   = map[string]bool{}
+)
+
+// checkChainOnce health-checks the providers of chain in the background the first time
+// it is built, so a provider that is already down is tried last from the start. After
+// that, only failed calls mark providers down; checking on every build would cost a
+// completion per request with the hosted providers.
+func checkChainOnce(providers string, chain *fallback.FallbackLLM) {
+	checkedChainsMu.Lock()
+	defer checkedChainsMu.Unlock()
+	if checkedChains[providers] {
+		return
+	}
+	checkedChains[providers] = true
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), chainHealthTimeout)
+		defer cancel()
+		if err := chain.HealthCheck(ctx); err != nil {
+			logger.Warn("No LLM provider in %q passed its health check: %v", providers, err)
+		}
+	}()
+}
+
+// providerLimiter returns the limiter shared by the LLM and embedder of provider, or nil
+// when none of its <PREFIX>_MAX_IN_FLIGHT, _RPM or _TPM limits is set.
+func providerLimiter(cfg *config.Config, provider string) *ratelimit.Limiter {
+	limit := ratelimit.Config(cfg.RateLimits[provider])
+	if !limit.Enabled() {
+		return nil
+	}
+	return ratelimit.ForProvider(provider, limit)
+}
+
+// withRateLimit queues calls to model behind its provider's limiter. It sits below the
+// retry decorator so that every attempt waits its turn.
+func withRateLimit(cfg *config.Config, provider string, model llm.LLM) llm.LLM {
+	limiter := providerLimiter(cfg, provider)
+	if limiter == nil {
+		return model
+	}
+	return ratelimit.NewLLM(model, limiter)
+}
+
+// withCache answers repeated prompts from the cache selected by LLM_CACHE ("memory" or "disk").
+func withCache(cfg *config.Config, model llm.LLM) llm.LLM {
+	if cfg.LLMCache == "" {
+		return model
+	}
+	store, err := llmCacheStore(cfg)
+	if err != nil {
+		logger.Error("%v; caching disabled", err)
+		return model
+	}
+	cacheCfg := cache.Config{TTL: cfg.LLMCacheTTL, Similarity: float64(cfg.LLMCacheSimilarity)}
+	if cfg.LLMCacheSemantic {
+		cacheCfg.Embedder = semanticEmbedder(cfg)
+	}
+	logger.Info("Using %s LLM cache", cfg.LLMCache)
+	return cache.New(model, store, cacheCfg)
+}
+
+// llmCacheStores and semanticEmbedders are shared so that handlers, which build their
+// pipes and so their LLM on every request, hit what earlier requests cached.
+var (
+	llmCacheMu        sync.Mutex
+	llmCacheStores    = map[llmCacheKey]cache.Store{}
+	semanticEmbedders = map[string]embeddings.Embedder{}
+)
+
+type llmCacheKey struct {
+	mode, dir string
+	size      int
+}
+
+// llmCacheStore returns the store selected by LLM_CACHE, opening it on first use.
+func llmCacheStore(cfg *config.Config) (cache.Store, error) {
+	key := llmCacheKey{mode: cfg.LLMCache, size: cfg.LLMCacheSize}
+	if cfg.LLMCache == "disk" {
+		key.dir = cfg.LLMCacheDir
+	}
+	llmCacheMu.Lock()
+	defer llmCacheMu.Unlock()
+	if store, ok := llmCacheStores[key]; ok {
+		return store, nil
+	}
+	var store cache.Store
+	switch cfg.LLMCache {
+	case "memory":
+		store = cache.NewMemoryStore(cfg.LLMCacheSize)
+	case "disk":
+		disk, err := cache.NewDiskStore(cfg.LLMCacheDir, cfg.LLMCacheSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open LLM cache in %s: %w", cfg.LLMCacheDir, err)
+		}
+		store = disk
+	default:
+		return nil, fmt.Errorf("unknown LLM_CACHE %q", cfg.LLMCache)
+	}
+	llmCacheStores[key] = store
+	return store, nil
+}
+
+// semanticEmbedder returns the embedder for semantic cache lookups, one per embedding model.
+func semanticEmbedder(cfg *config.Config) embeddings.Embedder {
+	model := EmbeddingsModel(cfg)
+	llmCacheMu.Lock()
+	defer llmCacheMu.Unlock()
+	embedder, ok := semanticEmbedders[model]
+	if !ok {
+		embedder = GetEmbedder(cfg)
+		semanticEmbedders[model] = embedder
+	}
+	return embedder
+}
+
+// withContextWindow trims prompts to the model's context window using LLM_TRIM_STRATEGY.
+func withContextWindow(cfg *config.Config, model llm.LLM) llm.LLM {
+	if cfg.TiktokenDir != "" {
+		if err := tokenizer.LoadDir(cfg.TiktokenDir); err != nil {
+			logger.Error("failed to load tiktoken encodings from %s: %v", cfg.TiktokenDir, err)
+		}
+	}
+	strategies, err := window.Strategies(cfg.LLMTrimStrategy, model)
+	if err != nil {
+		logger.Error("invalid LLM_TRIM_STRATEGY: %v", err)
+		os.Exit(1)
+	}
+	if len(strategies) == 0 {
+		return model
+	}
+	return window.New(model, window.Config{
+		ContextWindow: cfg.LLMContextWindow,
+		Reserve:       cfg.LLMContextReserve,
+		Strategies:    strategies,
+	})
+}
+
+func newLLM(cfg *config.Config, provider string) (llm.LLM, error) {
+	switch provider {
 	case "azure":
 		logger.Info("Using AzureOpenAI for LLM")
-		llmModel, err = azure.New(cfg)
+		return azure.New(cfg)
 	case "ollama":
 		logger.Info("Using Ollama for LLM")
-		llmModel, err = llmollama.New(cfg)
+		return llmollama.New(cfg)
+	case "openai-compatible":
+		logger.Info("Using OpenAI-compatible server at %s for LLM", cfg.OpenAICompatibleBaseURL)
+		return openai.NewCompatible(cfg)
 	default:
 		logger.Info("Using OpenAI for LLM")
-		llmModel, err = openai.New(cfg)
+		return openai.New(cfg)
 	}
-	if err != nil {
-		logger.Error("failed to create LLM: %v", err)
-		os.Exit(1)
+}
+
+// primaryProvider returns the first provider in LLM_PROVIDER, the one tried before any
+// fallbacks.
+func primaryProvider(cfg *config.Config) string {
+	provider, _, _ := strings.Cut(cfg.LLMProvider, ",")
+	return strings.TrimSpace(provider)
+}
+
+// ContextWindow returns the context length of the model configured for the first
+// provider in LLM_PROVIDER.
+func ContextWindow(cfg *config.Config) int {
+	if primaryProvider(cfg) == "ollama" {
+		return llmollama.ContextWindow(cfg)
+	}
+	return llm.ConfiguredContextWindow(cfg, ModelName(cfg))
+}
+
+// ModelName returns the model configured for the first provider in LLM_PROVIDER.
+func ModelName(cfg *config.Config) string {
+	switch primaryProvider(cfg) {
+	case "azure":
+		if cfg.AzureModel != "" {
+			return cfg.AzureModel
+		}
+		return cfg.AzureDeployment
+	case "ollama":
+		return cfg.OllamaModel
+	case "openai-compatible":
+		return cfg.OpenAICompatibleModel
+	default:
+		return cfg.OpenAIModel
 	}
-	return llmModel
 }
 
 // llm factory (async)
@@ -81,23 +308,37 @@ func AGetSplitter(ctx context.Context, cfg *config.Config) <-chan splitters.Spli
 	return out
 }
 
-// vector store factory
+// vector store factory. The store keeps the BM25 index at BM25_INDEX_PATH in step with it.
 func GetVectorStore(cfg *config.Config, embedder embeddings.Embedder) vectorstores.VectorStore {
 	var store vectorstores.VectorStore
 	var err error
 	switch cfg.VectorStoreProvider {
 	case "chroma":
-		logger.Info("Using Chroma vector store")
-		store, err = chroma.New(cfg)
+		logger.Info("Using Chroma vector store with %s embeddings", EmbeddingsModel(cfg))
+		store, err = chroma.New(cfg, embedder, EmbeddingsModel(cfg))
 	default:
-		logger.Info("Using in-memory vector store")
-		store = simple.New(embedder)
+		var opts []simple.Option
+		if cfg.VectorStoreProvider == "hnsw" {
+			logger.Info("Indexing vectors with HNSW (M=%d, efConstruction=%d, efSearch=%d)", cfg.HNSWM, cfg.HNSWEFConstruction, cfg.HNSWEFSearch)
+			opts = append(opts, simple.WithHNSW(hnsw.Config{M: cfg.HNSWM, EfConstruction: cfg.HNSWEFConstruction, EfSearch: cfg.HNSWEFSearch}))
+		}
+		if cfg.SimpleStorePath == "" {
+			logger.Info("Using in-memory vector store")
+			store = simple.New(embedder, opts...)
+			break
+		}
+		logger.Info("Using in-memory vector store persisted to %s", cfg.SimpleStorePath)
+		var s *simple.Store
+		s, err = simple.Open(embedder, cfg.SimpleStorePath, EmbeddingsModel(cfg), opts...)
+		if err == nil {
+			store = s
+		}
 	}
 	if err != nil {
 		logger.Error("failed to create vector store: %v", err)
 		os.Exit(1)
 	}
-	return store
+	return bm25.NewStore(store, GetLexicalIndex(cfg), cfg.BM25IndexPath)
 }
 
 // async vector store factory
@@ -113,14 +354,194 @@ func AGetVectorStore(ctx context.Context, cfg *config.Config, embedder embedding
 	return out, errCh
 }
 
-// embedder factory
+// lexicalIndexes are shared per path so that chunks indexed during ingestion are
+// searchable by later queries in the same process.
+var (
+	lexicalMu      sync.Mutex
+	lexicalIndexes = map[string]*bm25.Index{}
+)
+
+// GetLexicalIndex returns the BM25 index at BM25_INDEX_PATH, or an in-memory one when the
+// path is empty.
+func GetLexicalIndex(cfg *config.Config) *bm25.Index {
+	lexicalMu.Lock()
+	defer lexicalMu.Unlock()
+	if x, ok := lexicalIndexes[cfg.BM25IndexPath]; ok {
+		return x
+	}
+	x := bm25.New()
+	if cfg.BM25IndexPath != "" {
+		loaded, err := bm25.Open(cfg.BM25IndexPath)
+		if err != nil {
+			logger.Error("failed to open BM25 index, starting empty: %v", err)
+		} else {
+			x = loaded
+		}
+	}
+	lexicalIndexes[cfg.BM25IndexPath] = x
+	return x
+}
+
+// retriever factory. RETRIEVER selects the first stage, "vector", "bm25" or "hybrid",
+// which fetches RETRIEVER_FETCH_K candidates. Vector hits scoring below
+// RETRIEVER_SCORE_THRESHOLD are dropped, RERANKER=llm regrades the candidates and
+// RETRIEVER_SEARCH_TYPE=mmr picks diverse ones from them.
+func GetRetriever(cfg *config.Config, store vectorstores.VectorStore, embedder embeddings.Embedder) retrievers.Retriever {
+	r, err := newRetriever(cfg, store, embedder)
+	if err != nil {
+		logger.Error("failed to create retriever: %v", err)
+		os.Exit(1)
+	}
+	return r
+}
+
+func newRetriever(cfg *config.Config, store vectorstores.VectorStore, embedder embeddings.Embedder) (retrievers.Retriever, error) {
+	var vector retrievers.Retriever = retrievers.NewVector(store)
+	if cfg.RetrieverScoreThreshold != 0 {
+		vector = retrievers.NewThreshold(vector, float64(cfg.RetrieverScoreThreshold))
+	}
+	var r retrievers.Retriever
+	switch cfg.Retriever {
+	case "bm25":
+		logger.Info("Using BM25 retrieval")
+		r = retrievers.NewLexical(GetLexicalIndex(cfg))
+	case "hybrid":
+		logger.Info("Using hybrid retrieval with %s fusion", cfg.HybridFusion)
+		hybrid, err := retrievers.NewHybrid(vector, retrievers.NewLexical(GetLexicalIndex(cfg)), retrievers.HybridConfig{
+			Fusion:       retrievers.Fusion(cfg.HybridFusion),
+			VectorWeight: float64(cfg.HybridVectorWeight),
+			FetchK:       cfg.RetrieverFetchK,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r = hybrid
+	case "", "vector":
+		r = vector
+	default:
+		logger.Error("unknown RETRIEVER %q; using vector retrieval", cfg.Retriever)
+		r = vector
+	}
+
+	switch cfg.Reranker {
+	case "":
+	case "llm":
+		var opts []llm.Option
+		if cfg.RerankerModel != "" {
+			opts = append(opts, llm.WithModel(cfg.RerankerModel))
+		}
+		logger.Info("Reranking retrieved documents with the LLM")
+		reranker, err := retrievers.NewLLMReranker(GetLLM(cfg), opts...)
+		if err != nil {
+			return nil, err
+		}
+		r = retrievers.NewReranking(r, reranker, cfg.RetrieverFetchK)
+	default:
+		logger.Error("unknown RERANKER %q; reranking disabled", cfg.Reranker)
+	}
+
+	switch cfg.RetrieverSearchType {
+	case "", "similarity":
+	case "mmr":
+		logger.Info("Diversifying retrieved documents with MMR (lambda=%.2f)", cfg.RetrieverMMRLambda)
+		mmr, err := retrievers.NewMMR(r, embedder, float64(cfg.RetrieverMMRLambda), cfg.RetrieverFetchK)
+		if err != nil {
+			return nil, err
+		}
+		r = mmr
+	default:
+		logger.Error("unknown RETRIEVER_SEARCH_TYPE %q; using similarity", cfg.RetrieverSearchType)
+	}
+	return r, nil
+}
+
+// embedder factory. EMBEDDINGS_PROVIDER selects "ollama", "openai", "azure",
+// "openai-compatible" or "local" (offline feature hashing); when unset, the first LLM
+// provider also serves embeddings if it can, see EmbeddingsProvider.
 func GetEmbedder(cfg *config.Config) embeddings.Embedder {
-	embedder, err := embollama.New(cfg)
+	provider := EmbeddingsProvider(cfg)
+	var embedder embeddings.Embedder
+	var err error
+	switch provider {
+	case "openai":
+		logger.Info("Using OpenAI %s for embeddings", cfg.OpenAIEmbedModel)
+		embedder, err = embopenai.New(cfg)
+	case "azure":
+		logger.Info("Using AzureOpenAI deployment %s for embeddings", cfg.AzureEmbedDeployment)
+		embedder, err = embazure.New(cfg)
+	case "openai-compatible":
+		logger.Info("Using OpenAI-compatible server at %s for embeddings", cfg.OpenAICompatibleBaseURL)
+		embedder, err = embopenai.NewCompatible(cfg)
+	case "ollama":
+		embedder, err = embollama.New(cfg)
+	case "local":
+		logger.Info("Using local hashed n-gram embeddings")
+		// cheap to compute, so not worth caching
+		return local.New(cfg.EmbeddingsDimensions)
+	default:
+		err = fmt.Errorf("unknown EMBEDDINGS_PROVIDER %q", provider)
+	}
 	if err != nil {
 		logger.Error("failed to create embedder: %v", err)
 		os.Exit(1)
 	}
-	return embedder
+	if limiter := providerLimiter(cfg, provider); limiter != nil {
+		embedder = ratelimit.NewEmbedder(embedder, limiter)
+	}
+	return withEmbeddingCache(cfg, embedder)
+}
+
+// withEmbeddingCache reuses vectors stored in EMBEDDINGS_CACHE_DIR for text embedded before.
+func withEmbeddingCache(cfg *config.Config, embedder embeddings.Embedder) embeddings.Embedder {
+	if !cfg.EmbeddingsCache {
+		return embedder
+	}
+	store, err := embcache.NewDiskStore(cfg.EmbeddingsCacheDir, int64(cfg.EmbeddingsCacheMaxMB)<<20)
+	if err != nil {
+		logger.Error("failed to open embedding cache in %s: %v", cfg.EmbeddingsCacheDir, err)
+		return embedder
+	}
+	return embcache.New(embedder, store, EmbeddingsModel(cfg))
+}
+
+// EmbeddingsModel identifies the embedding model GetEmbedder uses, as
+// "provider:model[:dimensions]". Vectors from different identifiers are not comparable.
+func EmbeddingsModel(cfg *config.Config) string {
+	provider := EmbeddingsProvider(cfg)
+	var model string
+	switch provider {
+	case "openai":
+		model = cfg.OpenAIEmbedModel
+	case "azure":
+		model = cfg.AzureEmbedDeployment
+	case "local":
+		model = "hashing"
+	case "openai-compatible":
+		model = cfg.OpenAICompatibleEmbedModel
+		if model == "" {
+			model = cfg.OpenAICompatibleModel
+		}
+	default:
+		model = cfg.OllamaEmbedModel
+	}
+	id := provider + ":" + model
+	if cfg.EmbeddingsDimensions > 0 && provider != "ollama" {
+		id += ":" + strconv.Itoa(cfg.EmbeddingsDimensions)
+	}
+	return id
+}
+
+// EmbeddingsProvider returns the embeddings provider GetEmbedder uses: EMBEDDINGS_PROVIDER,
+// else the first LLM provider when it also serves embeddings, else Ollama.
+func EmbeddingsProvider(cfg *config.Config) string {
+	if cfg.EmbeddingsProvider != "" {
+		return cfg.EmbeddingsProvider
+	}
+	switch provider := primaryProvider(cfg); provider {
+	case "openai", "azure", "openai-compatible":
+		return provider
+	}
+	return "ollama"
 }
 
 // async embedder factory
@@ -0,0 +1,67 @@
+package factories
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"gogurt/internal/config"
+	"gogurt/internal/embeddings/local"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores/simple"
+)
+
+func TestRetrieverConfigErrorsAreNotMasked(t *testing.T) {
+	embedder := local.New(16)
+	store := simple.New(embedder)
+	cfg := &config.Config{Retriever: "hybrid", HybridFusion: "max", Reranker: "llm", RetrieverSearchType: "mmr", RetrieverMMRLambda: 0.5}
+	if r, err := newRetriever(cfg, store, embedder); err == nil {
+		t.Errorf("expected an unknown fusion to fail, got retriever %T", r)
+	}
+	cfg = &config.Config{Retriever: "vector", RetrieverSearchType: "mmr", RetrieverMMRLambda: 2}
+	if _, err := newRetriever(cfg, store, embedder); err == nil {
+		t.Error("expected an MMR lambda above 1 to fail")
+	}
+}
+
+func TestEmbeddingsProviderFollowsPrimaryLLM(t *testing.T) {
+	for llmProvider, want := range map[string]string{
+		"":                         "ollama",
+		"ollama,openai":            "ollama",
+		"openai":                   "openai",
+		" azure , ollama":          "azure",
+		"openai-compatible,ollama": "openai-compatible",
+		"openai-compatible-x":      "ollama",
+		"openai,openai-compatible": "openai",
+	} {
+		if got := EmbeddingsProvider(&config.Config{LLMProvider: llmProvider}); got != want {
+			t.Errorf("LLM_PROVIDER=%q: got %q, want %q", llmProvider, got, want)
+		}
+	}
+	if got := EmbeddingsProvider(&config.Config{LLMProvider: "openai", EmbeddingsProvider: "local"}); got != "local" {
+		t.Errorf("expected EMBEDDINGS_PROVIDER to win, got %q", got)
+	}
+}
+
+func TestGetLLMSharesCacheAcrossCalls(t *testing.T) {
+	var chats atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chats.Add(1)
+		w.Write([]byte(`{"model":"m","message":{"role":"assistant","content":"hello"},"done":true}` + "\n"))
+	}))
+	defer srv.Close()
+	cfg := &config.Config{LLMProvider: "ollama", OllamaHost: srv.URL, OllamaModel: "m", LLMCache: "memory", LLMCacheSize: 10}
+	prompt := []types.ChatMessage{{Role: types.RoleUser, Content: "hi"}}
+
+	// each HTTP request builds its pipes, and so its LLM, anew
+	for range 2 {
+		if _, err := GetLLM(cfg).Generate(context.Background(), prompt); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := chats.Load(); got != 1 {
+		t.Errorf("expected the second LLM to answer from the cache, got %d requests", got)
+	}
+}
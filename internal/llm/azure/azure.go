@@ -2,9 +2,15 @@ package azure
 
 import (
 	"context"
+	"fmt"
 	"gogurt/internal/config"
 	"gogurt/internal/llm"
+	"gogurt/internal/llm/openaiconv"
+	"gogurt/internal/llm/retry"
+	"gogurt/internal/tools"
 	"gogurt/internal/types"
+	"math"
+	"net/http"
 	"strings"
 
 	"github.com/sashabaranov/go-openai"
@@ -13,6 +19,10 @@ import (
 type AzureLLM struct {
 	client         *openai.Client
 	deploymentName string
+	// model is the model behind deploymentName, used for pricing and limits
+	model         string
+	defaults      llm.GenerateOptions
+	contextWindow int
 }
 
 // HealthCheck implements types.LLM.
@@ -24,40 +34,99 @@ func (a *AzureLLM) HealthCheck(ctx context.Context) error {
 // Metadata implements types.LLM.
 func (a *AzureLLM) Metadata() map[string]any {
 	md := make(map[string]any)
+	md["provider"] = "azure"
 	md["model"] = a.deploymentName
+	md["context_window"] = a.contextWindow
 	return md
 }
 
 func New(cfg *config.Config) (llm.LLM, error) {
-	client := openai.NewClient(cfg.AzureOpenAIAPIKey)
+	return NewWithHTTPClient(cfg, retry.HTTPClient())
+}
+
+func NewWithHTTPClient(cfg *config.Config, httpClient *http.Client) (llm.LLM, error) {
+	clientCfg := openai.DefaultAzureConfig(cfg.AzureOpenAIAPIKey, cfg.AzureOpenAIEndpoint)
+	clientCfg.HTTPClient = httpClient
+	client := openai.NewClientWithConfig(clientCfg)
+	// deployment names often match the model they serve
+	model := cfg.AzureModel
+	if model == "" {
+		model = cfg.AzureDeployment
+	}
 	return &AzureLLM{
 		client:         client,
 		deploymentName: cfg.AzureDeployment,
+		model:          model,
+		defaults:       llm.DefaultOptions(cfg),
+		contextWindow:  llm.ConfiguredContextWindow(cfg, model),
 	}, nil
 }
 
-// Convert types.ChatMessage to openai.ChatCompletionMessage
-func toOpenAIMessages(messages []types.ChatMessage) []openai.ChatCompletionMessage {
-	apiMessages := make([]openai.ChatCompletionMessage, len(messages))
-	for i, msg := range messages {
-		apiMessages[i] = openai.ChatCompletionMessage{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		}
+// modelOf returns the model served by deployment: the configured model for the default
+// deployment, and the deployment name itself for others.
+func (a *AzureLLM) modelOf(deployment string) string {
+	if deployment == a.deploymentName {
+		return a.model
+	}
+	return deployment
+}
+
+// Report which content parts the deployment selected by opts accepts. Text-only models
+// are recognized by the name of the model the deployment serves.
+func (a *AzureLLM) contentSupport(opts []llm.Option) llm.ContentSupport {
+	deployment := a.deploymentName
+	if m := llm.ApplyOptions(a.defaults, opts...).Model; m != "" {
+		deployment = m
 	}
-	return apiMessages
+	return llm.ContentSupport{Images: llm.SupportsImages(a.modelOf(deployment)), ImageURLs: true}
+}
+
+// Build a chat completion request with the configured defaults and per-call options applied.
+// A model override in the options selects a different deployment.
+func (a *AzureLLM) newRequest(messages []openai.ChatCompletionMessage, opts []llm.Option) openai.ChatCompletionRequest {
+	options := llm.ApplyOptions(a.defaults, opts...)
+	req := openai.ChatCompletionRequest{
+		Model:     a.deploymentName,
+		Messages:  messages,
+		MaxTokens: options.MaxTokens,
+		Stop:      options.Stop,
+		Seed:      options.Seed,
+	}
+	if options.Model != "" {
+		req.Model = options.Model
+	}
+	if options.Temperature != nil {
+		// the client omits a zero temperature, so send the smallest non-zero value instead
+		req.Temperature = max(*options.Temperature, math.SmallestNonzeroFloat32)
+	}
+	if options.TopP != nil {
+		req.TopP = *options.TopP
+	}
+	// JSONSchema is not mapped: structured outputs need a newer API version than the
+	// client's default, so Azure relies on the prompt and the caller's validation.
+	return req
 }
 
 // Generate generates a response from the Azure API.
-func (a *AzureLLM) Generate(ctx context.Context, messages []types.ChatMessage) (*types.ChatMessage, error) {
-	apiMessages := toOpenAIMessages(messages)
-	res, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:    a.deploymentName,
-		Messages: apiMessages,
-	})
+func (a *AzureLLM) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (*types.ChatMessage, error) {
+	return a.GenerateWithTools(ctx, messages, nil, opts...)
+}
+
+// GenerateWithTools generates a response from the Azure API, offering toolset as callable functions.
+func (a *AzureLLM) GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...llm.Option) (*types.ChatMessage, error) {
+	apiMessages, err := openaiconv.Messages(messages, a.contentSupport(opts))
+	if err != nil {
+		return nil, err
+	}
+	req := a.newRequest(apiMessages, opts)
+	req.Tools = openaiconv.Tools(toolset)
+	res, err := a.client.CreateChatCompletion(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	if len(res.Choices) == 0 {
+		return nil, fmt.Errorf("no choices returned from Azure")
+	}
 	var responseContent strings.Builder
 	var responseRole types.Role
 	responseContent.WriteString(res.Choices[0].Message.Content)
@@ -65,20 +134,28 @@ func (a *AzureLLM) Generate(ctx context.Context, messages []types.ChatMessage) (
 	if responseRole == "" {
 		responseRole = types.RoleAssistant
 	}
+	toolCalls, err := openaiconv.ToolCalls(res.Choices[0].Message.ToolCalls)
+	if err != nil {
+		return nil, err
+	}
+	usage := openaiconv.Usage(res.Usage)
+	llm.RecordUsage(ctx, a.modelOf(req.Model), usage)
 	return &types.ChatMessage{
-		Role:    responseRole,
-		Content: responseContent.String(),
+		Role:      responseRole,
+		Content:   responseContent.String(),
+		ToolCalls: toolCalls,
+		Usage:     usage,
 	}, nil
 }
 
 // AGenerate provides an asynchronous Generate.
-func (a *AzureLLM) AGenerate(ctx context.Context, messages []types.ChatMessage) (<-chan *types.ChatMessage, <-chan error) {
+func (a *AzureLLM) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan *types.ChatMessage, <-chan error) {
 	msgCh := make(chan *types.ChatMessage, 1)
 	errCh := make(chan error, 1)
 	go func() {
 		defer close(msgCh)
 		defer close(errCh)
-		msg, err := a.Generate(ctx, messages)
+		msg, err := a.Generate(ctx, messages, opts...)
 		if err != nil {
 			errCh <- err
 			return
@@ -89,25 +166,30 @@ func (a *AzureLLM) AGenerate(ctx context.Context, messages []types.ChatMessage)
 }
 
 // Stream streams model output from Azure, forwarding chunks to onToken.
-func (a *AzureLLM) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error) (*types.ChatMessage, error) {
-	apiMessages := toOpenAIMessages(messages)
-	stream := true
-	resStream, err := a.client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
-		Model:    a.deploymentName,
-		Messages: apiMessages,
-		Stream:   stream,
-	})
+func (a *AzureLLM) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error, opts ...llm.Option) (*types.ChatMessage, error) {
+	apiMessages, err := openaiconv.Messages(messages, a.contentSupport(opts))
+	if err != nil {
+		return nil, err
+	}
+	req := a.newRequest(apiMessages, opts)
+	req.Stream = true
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	resStream, err := a.client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	var responseContent strings.Builder
 	var responseRole types.Role
+	var usage *types.Usage
 	for {
 		resp, err := resStream.Recv()
 		if err != nil {
 			// io.EOF => end of stream, treat as normal exit
 			break
 		}
+		if resp.Usage != nil {
+			usage = openaiconv.Usage(*resp.Usage)
+		}
 		if len(resp.Choices) == 0 {
 			continue
 		}
@@ -127,14 +209,16 @@ func (a *AzureLLM) Stream(ctx context.Context, messages []types.ChatMessage, onT
 	if responseRole == "" {
 		responseRole = types.RoleAssistant
 	}
+	llm.RecordUsage(ctx, a.modelOf(req.Model), usage)
 	return &types.ChatMessage{
 		Role:    responseRole,
 		Content: responseContent.String(),
+		Usage:   usage,
 	}, nil
 }
 
 // AStream provides an asynchronous streaming interface returning tokens.
-func (a *AzureLLM) AStream(ctx context.Context, messages []types.ChatMessage) (<-chan string, <-chan error) {
+func (a *AzureLLM) AStream(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan string, <-chan error) {
 	tokenCh := make(chan string)
 	errCh := make(chan error, 1)
 	go func() {
@@ -143,7 +227,7 @@ func (a *AzureLLM) AStream(ctx context.Context, messages []types.ChatMessage) (<
 		_, err := a.Stream(ctx, messages, func(token string) error {
 			tokenCh <- token
 			return nil
-		})
+		}, opts...)
 		if err != nil {
 			errCh <- err
 		}
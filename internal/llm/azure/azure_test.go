@@ -0,0 +1,32 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gogurt/internal/config"
+	"gogurt/internal/llm"
+	"gogurt/internal/types"
+)
+
+func TestUsageIsPricedByConfiguredModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"}}],"usage":{"prompt_tokens":1000,"completion_tokens":100,"total_tokens":1100}}`))
+	}))
+	defer server.Close()
+
+	model, err := NewWithHTTPClient(&config.Config{AzureOpenAIEndpoint: server.URL, AzureDeployment: "prod-chat", AzureModel: "gpt-4o"}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := llm.NewUsageTracker()
+	if _, err := model.Generate(llm.WithUsageTracker(context.Background(), tracker), []types.ChatMessage{{Role: types.RoleUser, Content: "hello"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := tracker.Summary(); got.TotalTokens != 1100 || got.EstimatedCostUSD <= 0 {
+		t.Errorf("expected the deployment's usage priced as gpt-4o, got %+v", got)
+	}
+}
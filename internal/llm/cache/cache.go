@@ -0,0 +1,328 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gogurt/internal/embeddings"
+	"gogurt/internal/llm"
+	"gogurt/internal/logger"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+)
+
+// DefaultSimilarity is the cosine similarity a prompt needs to reuse a semantic match.
+const DefaultSimilarity = 0.95
+
+// Config controls lookups and expiry.
+type Config struct {
+	// TTL expires entries; 0 keeps them until evicted.
+	TTL time.Duration
+	// Embedder enables semantic matching of prompts that are not byte-identical.
+	Embedder embeddings.Embedder
+	// Similarity is the minimum cosine similarity for a semantic match.
+	Similarity float64
+}
+
+// CachedLLM answers repeated calls from a Store. Calls are matched exactly on a hash of
+// the messages, options, tools and model and, when an Embedder is configured, by
+// similarity of the prompt to earlier prompts with the same options and tools. Errors
+// are never cached. Hits are marked with "cache" in the response metadata.
+type CachedLLM struct {
+	next  llm.LLM
+	store Store
+	cfg   Config
+	model string
+}
+
+func New(next llm.LLM, store Store, cfg Config) *CachedLLM {
+	if cfg.Similarity <= 0 {
+		cfg.Similarity = DefaultSimilarity
+	}
+	return &CachedLLM{next: next, store: store, cfg: cfg, model: llm.ModelName(next)}
+}
+
+// Stats counts cache lookups across all caches in the process.
+type Stats struct {
+	Hits         int64 `json:"hits"`
+	SemanticHits int64 `json:"semantic_hits"`
+	Misses       int64 `json:"misses"`
+	Evictions    int64 `json:"evictions"`
+	Expired      int64 `json:"expired"`
+}
+
+var stats struct {
+	hits, semanticHits, misses, evictions, expired atomic.Int64
+}
+
+// GlobalStats returns a snapshot of the process-wide counters.
+func GlobalStats() Stats {
+	return Stats{
+		Hits:         stats.hits.Load(),
+		SemanticHits: stats.semanticHits.Load(),
+		Misses:       stats.misses.Load(),
+		Evictions:    stats.evictions.Load(),
+		Expired:      stats.expired.Load(),
+	}
+}
+
+// request is what identifies a call; it is hashed into cache keys.
+type request struct {
+	Model    string              `json:"model"`
+	Options  llm.GenerateOptions `json:"options"`
+	Tools    []toolKey           `json:"tools,omitempty"`
+	Messages []types.ChatMessage `json:"messages,omitempty"`
+}
+
+type toolKey struct {
+	Name       string         `json:"name"`
+	Parameters map[string]any `json:"parameters"`
+}
+
+func hash(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// keys returns the exact-match key and the scope used for semantic matches.
+func (c *CachedLLM) keys(messages []types.ChatMessage, toolset []*tools.Tool, opts []llm.Option) (key, scope string) {
+	req := request{Model: c.model, Options: llm.ApplyOptions(llm.GenerateOptions{}, opts...)}
+	for _, tool := range toolset {
+		req.Tools = append(req.Tools, toolKey{Name: tool.Name, Parameters: tool.Parameters()})
+	}
+	scope = hash(req)
+	req.Messages = messages
+	return hash(req), scope
+}
+
+// lookup finds a fresh entry for the call. embedding is the prompt's embedding when
+// semantic matching ran, so a miss can be stored without embedding twice.
+func (c *CachedLLM) lookup(ctx context.Context, key, scope string, messages []types.ChatMessage) (entry *Entry, semantic bool, embedding []float32) {
+	if e, ok := c.store.Get(key); ok {
+		if c.fresh(e) {
+			return e, false, nil
+		}
+		c.store.Delete(key)
+		stats.expired.Add(1)
+	}
+	if c.cfg.Embedder == nil || !textOnly(messages) {
+		return nil, false, nil
+	}
+	embedding, err := c.cfg.Embedder.EmbedQuery(ctx, promptText(messages))
+	if err != nil {
+		logger.WarnCtx(ctx, "LLM cache: failed to embed prompt, skipping semantic lookup: %v", err)
+		return nil, false, nil
+	}
+	best := c.cfg.Similarity
+	c.store.Range(func(e *Entry) bool {
+		if e.Scope != scope || len(e.Embedding) == 0 || !c.fresh(e) {
+			return true
+		}
+		if sim := cosineSimilarity(embedding, e.Embedding); sim >= best {
+			entry, best = e, sim
+		}
+		return true
+	})
+	return entry, entry != nil, embedding
+}
+
+func (c *CachedLLM) fresh(e *Entry) bool {
+	return c.cfg.TTL <= 0 || time.Since(e.CreatedAt) < c.cfg.TTL
+}
+
+func (c *CachedLLM) save(ctx context.Context, key, scope string, embedding []float32, messages []types.ChatMessage, resp *types.ChatMessage) {
+	if c.cfg.Embedder != nil && embedding == nil && textOnly(messages) {
+		var err error
+		if embedding, err = c.cfg.Embedder.EmbedQuery(ctx, promptText(messages)); err != nil {
+			logger.WarnCtx(ctx, "LLM cache: failed to embed prompt: %v", err)
+		}
+	}
+	entry := &Entry{Key: key, Scope: scope, Embedding: embedding, Response: *resp, CreatedAt: time.Now()}
+	if err := c.store.Put(entry); err != nil {
+		logger.WarnCtx(ctx, "LLM cache: failed to store response: %v", err)
+	}
+}
+
+// hit returns a copy of a cached response. It carries no usage, as no tokens were spent.
+func hit(entry *Entry, semantic bool) *types.ChatMessage {
+	resp := entry.Response
+	resp.Usage = nil
+	resp.Metadata = make(map[string]any, len(entry.Response.Metadata)+1)
+	for k, v := range entry.Response.Metadata {
+		resp.Metadata[k] = v
+	}
+	if semantic {
+		stats.semanticHits.Add(1)
+		resp.Metadata["cache"] = "semantic"
+	} else {
+		stats.hits.Add(1)
+		resp.Metadata["cache"] = "hit"
+	}
+	return &resp
+}
+
+func (c *CachedLLM) generate(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts []llm.Option,
+	call func() (*types.ChatMessage, error)) (*types.ChatMessage, error) {
+	key, scope := c.keys(messages, toolset, opts)
+	entry, semantic, embedding := c.lookup(ctx, key, scope, messages)
+	if entry != nil {
+		return hit(entry, semantic), nil
+	}
+	stats.misses.Add(1)
+	resp, err := call()
+	if err != nil {
+		return nil, err
+	}
+	c.save(ctx, key, scope, embedding, messages, resp)
+	return resp, nil
+}
+
+func (c *CachedLLM) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (*types.ChatMessage, error) {
+	return c.generate(ctx, messages, nil, opts, func() (*types.ChatMessage, error) {
+		return c.next.Generate(ctx, messages, opts...)
+	})
+}
+
+func (c *CachedLLM) GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...llm.Option) (*types.ChatMessage, error) {
+	return c.generate(ctx, messages, toolset, opts, func() (*types.ChatMessage, error) {
+		return c.next.GenerateWithTools(ctx, messages, toolset, opts...)
+	})
+}
+
+// AGenerate provides an asynchronous Generate.
+func (c *CachedLLM) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan *types.ChatMessage, <-chan error) {
+	msgCh := make(chan *types.ChatMessage, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(msgCh)
+		defer close(errCh)
+		msg, err := c.Generate(ctx, messages, opts...)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		msgCh <- msg
+	}()
+	return msgCh, errCh
+}
+
+// Stream replays a cached response as a word-by-word token stream; misses are streamed
+// from the wrapped LLM and stored once complete.
+func (c *CachedLLM) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error, opts ...llm.Option) (*types.ChatMessage, error) {
+	resp, err := c.generate(ctx, messages, nil, opts, func() (*types.ChatMessage, error) {
+		return c.next.Stream(ctx, messages, onToken, opts...)
+	})
+	if err != nil || resp.Metadata["cache"] == nil {
+		return resp, err
+	}
+	for _, token := range splitTokens(resp.Content) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onToken(token); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// AStream provides an asynchronous streaming interface returning tokens.
+func (c *CachedLLM) AStream(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan string, <-chan error) {
+	tokenCh := make(chan string)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(tokenCh)
+		defer close(errCh)
+		_, err := c.Stream(ctx, messages, func(token string) error {
+			select {
+			case tokenCh <- token:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, opts...)
+		if err != nil {
+			errCh <- err
+		}
+	}()
+	return tokenCh, errCh
+}
+
+func (c *CachedLLM) HealthCheck(ctx context.Context) error {
+	return c.next.HealthCheck(ctx)
+}
+
+func (c *CachedLLM) Metadata() map[string]any {
+	return c.next.Metadata()
+}
+
+// promptText is the text embedded for semantic matching.
+func promptText(messages []types.ChatMessage) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		b.WriteString(string(msg.Role))
+		b.WriteString(": ")
+		b.WriteString(msg.Content)
+		for _, part := range msg.Parts {
+			b.WriteString(part.Text)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// textOnly reports whether messages carry nothing but text. Only such prompts are matched
+// semantically, as their text does not describe images or files.
+func textOnly(messages []types.ChatMessage) bool {
+	for _, msg := range messages {
+		for _, part := range msg.Parts {
+			if part.Type != types.PartText {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splitTokens splits content into words, each keeping its trailing whitespace.
+func splitTokens(content string) []string {
+	var tokens []string
+	start := 0
+	for i := 1; i < len(content); i++ {
+		if isSpace(content[i-1]) && !isSpace(content[i]) {
+			tokens = append(tokens, content[start:i])
+			start = i
+		}
+	}
+	if start < len(content) {
+		tokens = append(tokens, content[start:])
+	}
+	return tokens
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t'
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/llm/fake"
+	"gogurt/internal/types"
+)
+
+func ask(q string) []types.ChatMessage {
+	return []types.ChatMessage{{Role: types.RoleUser, Content: q}}
+}
+
+func TestExactHit(t *testing.T) {
+	model := fake.Reply("Paris", "Lyon")
+	c := New(model, NewMemoryStore(10), Config{})
+	ctx := context.Background()
+
+	first, _ := c.Generate(ctx, ask("capital of France?"))
+	second, err := c.Generate(ctx, ask("capital of France?"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.Content != first.Content || second.Metadata["cache"] != "hit" || model.Calls() != 1 {
+		t.Errorf("expected a cache hit, got %+v after %d calls", second, model.Calls())
+	}
+	// different options are a different request
+	if resp, _ := c.Generate(ctx, ask("capital of France?"), llm.WithTemperature(0.9)); resp.Content != "Lyon" {
+		t.Errorf("expected a miss for different options, got %q", resp.Content)
+	}
+}
+
+func TestTTLExpires(t *testing.T) {
+	model := fake.Reply("old", "new")
+	store := NewMemoryStore(10)
+	c := New(model, store, Config{TTL: time.Minute})
+	c.Generate(context.Background(), ask("q"))
+	store.Range(func(e *Entry) bool {
+		e.CreatedAt = time.Now().Add(-2 * time.Minute)
+		return true
+	})
+	if resp, _ := c.Generate(context.Background(), ask("q")); resp.Content != "new" {
+		t.Errorf("expected expired entry to be refreshed, got %q", resp.Content)
+	}
+}
+
+// keywordEmbedder embeds text as counts of a few keywords, so paraphrases land close together.
+type keywordEmbedder struct{}
+
+func (keywordEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	text = strings.ToLower(text)
+	var v []float32
+	for _, w := range []string{"capital", "france", "weather", "paris"} {
+		v = append(v, float32(strings.Count(text, w)))
+	}
+	return v, nil
+}
+
+func (e keywordEmbedder) EmbedDocuments(ctx context.Context, docs []types.Document) ([][]float32, error) {
+	return nil, nil
+}
+
+func (e keywordEmbedder) EmbedAll(ctx context.Context, docs []types.Document, workers int) ([][]float32, error) {
+	return nil, nil
+}
+
+func (e keywordEmbedder) AEmbedQuery(ctx context.Context, text string) (<-chan []float32, <-chan error) {
+	return nil, nil
+}
+
+func (e keywordEmbedder) AEmbedDocuments(ctx context.Context, docs []types.Document) (<-chan [][]float32, <-chan error) {
+	return nil, nil
+}
+
+func TestSemanticHit(t *testing.T) {
+	model := fake.Reply("Paris", "Sunny")
+	c := New(model, NewMemoryStore(10), Config{Embedder: keywordEmbedder{}})
+	ctx := context.Background()
+	c.Generate(ctx, ask("What is the capital of France?"))
+
+	resp, _ := c.Generate(ctx, ask("capital of France, please"))
+	if resp.Content != "Paris" || resp.Metadata["cache"] != "semantic" {
+		t.Errorf("expected a semantic hit, got %+v", resp)
+	}
+	if resp, _ := c.Generate(ctx, ask("weather today?")); resp.Content != "Sunny" {
+		t.Errorf("expected a miss for an unrelated prompt, got %q", resp.Content)
+	}
+}
+
+func TestSemanticLookupSkipsImages(t *testing.T) {
+	model := fake.Reply("a cat", "a dog")
+	c := New(model, NewMemoryStore(10), Config{Embedder: keywordEmbedder{}})
+	ctx := context.Background()
+	withImage := func(data string) []types.ChatMessage {
+		msgs := ask("What is in this picture?")
+		msgs[0].Parts = []types.ContentPart{{Type: types.PartImage, Data: []byte(data), MIMEType: "image/png"}}
+		return msgs
+	}
+	c.Generate(ctx, withImage("cat.png"))
+	if resp, _ := c.Generate(ctx, withImage("dog.png")); resp.Content != "a dog" {
+		t.Errorf("expected a different image to miss, got %+v", resp)
+	}
+}
+
+func TestStreamReplaysCachedResponse(t *testing.T) {
+	model := fake.Reply("the answer is 42")
+	c := New(model, NewMemoryStore(10), Config{})
+	ctx := context.Background()
+	collect := func() []string {
+		var tokens []string
+		if _, err := c.Stream(ctx, ask("q"), func(tok string) error {
+			tokens = append(tokens, tok)
+			return nil
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return tokens
+	}
+	collect()
+	replayed := collect()
+	if strings.Join(replayed, "") != "the answer is 42" || len(replayed) != 4 || model.Calls() != 1 {
+		t.Errorf("expected replayed tokens, got %q after %d calls", replayed, model.Calls())
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewMemoryStore(2)
+	s.Put(&Entry{Key: "a"})
+	s.Put(&Entry{Key: "b"})
+	s.Get("a")
+	s.Put(&Entry{Key: "c"})
+	if _, ok := s.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := s.Get("a"); !ok {
+		t.Error("expected a to survive")
+	}
+}
+
+func TestDiskStorePersists(t *testing.T) {
+	dir := t.TempDir()
+	model := fake.Reply("cached")
+	s, err := NewDiskStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	New(model, s, Config{}).Generate(context.Background(), ask("q"))
+
+	reopened, err := NewDiskStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := New(fake.Reply(), reopened, Config{}).Generate(context.Background(), ask("q"))
+	if err != nil || resp.Content != "cached" {
+		t.Errorf("expected the response to survive a restart, got %v, %v", resp, err)
+	}
+}
+
+func TestDiskStoreEvictsAndSkipsCorruptEntries(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewDiskStore(dir, 2)
+	if err != nil {
+		t.Fatalf("expected a corrupt entry to be skipped, got %v", err)
+	}
+	start := time.Now()
+	for i, key := range []string{"a", "b", "c"} {
+		if err := s.Put(&Entry{Key: key, CreatedAt: start.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Errorf("expected 2 entries on disk, got %v", files)
+	}
+
+	reopened, err := NewDiskStore(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("c"); !ok {
+		t.Error("expected the newest entry to be kept")
+	}
+	if _, ok := reopened.Get("b"); ok {
+		t.Error("expected the older entry to be evicted on reopening with a smaller capacity")
+	}
+}
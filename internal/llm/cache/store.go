@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gogurt/internal/logger"
+	"gogurt/internal/types"
+)
+
+// Entry is a cached response.
+type Entry struct {
+	Key string `json:"key"`
+	// Scope hashes everything but the messages (model, options, tools); semantic matches
+	// are only considered within the same scope.
+	Scope string `json:"scope"`
+	// Embedding of the prompt, set when semantic matching is enabled.
+	Embedding []float32         `json:"embedding,omitempty"`
+	Response  types.ChatMessage `json:"response"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Store holds cache entries. Implementations must be safe for concurrent use.
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Put(entry *Entry) error
+	Delete(key string)
+	// Range calls fn for each entry until fn returns false.
+	Range(fn func(entry *Entry) bool)
+}
+
+// MemoryStore is an in-memory LRU store.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+	// onEvict is called, with mu held, for each entry evicted to stay within capacity
+	onEvict func(*Entry)
+}
+
+// NewMemoryStore returns an LRU store holding up to capacity entries; 0 means unbounded.
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (s *MemoryStore) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(el)
+	return el.Value.(*Entry), true
+}
+
+func (s *MemoryStore) Put(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[entry.Key]; ok {
+		el.Value = entry
+		s.order.MoveToFront(el)
+		return nil
+	}
+	s.items[entry.Key] = s.order.PushFront(entry)
+	if s.capacity > 0 && s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*Entry).Key)
+		stats.evictions.Add(1)
+		if s.onEvict != nil {
+			s.onEvict(oldest.Value.(*Entry))
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.order.Remove(el)
+		delete(s.items, key)
+	}
+}
+
+func (s *MemoryStore) Range(fn func(entry *Entry) bool) {
+	s.mu.Lock()
+	entries := make([]*Entry, 0, len(s.items))
+	for el := s.order.Front(); el != nil; el = el.Next() {
+		entries = append(entries, el.Value.(*Entry))
+	}
+	s.mu.Unlock()
+	for _, e := range entries {
+		if !fn(e) {
+			return
+		}
+	}
+}
+
+// DiskStore persists entries as one JSON file each under a directory, so the cache
+// survives restarts. Entries are also kept in memory for lookups, and the least recently
+// used ones are deleted from both once there are more than the capacity.
+type DiskStore struct {
+	dir string
+	mem *MemoryStore
+}
+
+// NewDiskStore opens or creates a store in dir holding up to capacity entries, 0 meaning
+// unbounded. It loads the entries already there, newest last, and skips unreadable ones.
+func NewDiskStore(dir string, capacity int) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &DiskStore{dir: dir, mem: NewMemoryStore(capacity)}
+	s.mem.onEvict = func(e *Entry) { os.Remove(s.path(e.Key)) }
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.Warn("LLM cache: skipping unreadable entry %s: %v", path, err)
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			logger.Warn("LLM cache: removing corrupt entry %s: %v", path, err)
+			os.Remove(path)
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	slices.SortFunc(entries, func(a, b *Entry) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	for _, entry := range entries {
+		s.mem.Put(entry)
+	}
+	return s, nil
+}
+
+func (s *DiskStore) path(key string) string {
+	// keys are hex digests, but never let one escape the directory
+	return filepath.Join(s.dir, strings.ReplaceAll(key, string(filepath.Separator), "_")+".json")
+}
+
+func (s *DiskStore) Get(key string) (*Entry, bool) {
+	return s.mem.Get(key)
+}
+
+func (s *DiskStore) Put(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// write then rename so readers never see a partial file
+	tmp := s.path(entry.Key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path(entry.Key)); err != nil {
+		return err
+	}
+	return s.mem.Put(entry)
+}
+
+func (s *DiskStore) Delete(key string) {
+	s.mem.Delete(key)
+	os.Remove(s.path(key))
+}
+
+func (s *DiskStore) Range(fn func(entry *Entry) bool) {
+	s.mem.Range(fn)
+}
@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"gogurt/internal/types"
+)
+
+// ErrUnsupportedContent is returned when a message carries content parts the backend
+// or model cannot accept, such as images for a text-only model.
+var ErrUnsupportedContent = errors.New("unsupported message content")
+
+// ContentSupport describes which content parts a backend can send.
+type ContentSupport struct {
+	Images bool
+	// ImageURLs is false for backends that need image bytes; data: URLs are always decoded.
+	ImageURLs bool
+}
+
+// TextOnlyModels lists model prefixes without image input, checked by SupportsImages.
+var TextOnlyModels = []string{"gpt-3.5", "gpt-35", "gpt-4-0", "gpt-4-32k", "o1-mini", "o3-mini"}
+
+// SupportsImages reports whether model accepts image input, as far as is known.
+// Unknown models are assumed to, leaving the final word to the server.
+func SupportsImages(model string) bool {
+	if model == "gpt-4" {
+		return false
+	}
+	for _, prefix := range TextOnlyModels {
+		if strings.HasPrefix(model, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasImages reports whether any message carries an image part.
+func HasImages(messages []types.ChatMessage) bool {
+	for _, msg := range messages {
+		for _, part := range msg.Parts {
+			if part.Type == types.PartImage {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ResolveParts returns msg's content as parts a backend can send: Content becomes a
+// leading text part, text files are inlined as text, and images are checked against
+// support. Files in other formats are rejected with ErrUnsupportedContent.
+func ResolveParts(msg types.ChatMessage, support ContentSupport) ([]types.ContentPart, error) {
+	var parts []types.ContentPart
+	if msg.Content != "" {
+		parts = append(parts, types.TextPart(msg.Content))
+	}
+	for _, part := range msg.Parts {
+		switch part.Type {
+		case types.PartText:
+			parts = append(parts, part)
+		case types.PartImage:
+			if !support.Images {
+				return nil, fmt.Errorf("%w: images are not supported by this model", ErrUnsupportedContent)
+			}
+			if part.URL != "" && !strings.HasPrefix(part.URL, "data:") && !support.ImageURLs {
+				return nil, fmt.Errorf("%w: image URLs are not supported by this backend, pass image bytes", ErrUnsupportedContent)
+			}
+			parts = append(parts, part)
+		case types.PartFile:
+			text, err := fileText(part)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, types.TextPart(text))
+		default:
+			return nil, fmt.Errorf("%w: unknown part type %q", ErrUnsupportedContent, part.Type)
+		}
+	}
+	return parts, nil
+}
+
+// fileText inlines a text file so that every backend can send it.
+func fileText(part types.ContentPart) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(part.MIMEType)
+	isText := strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" || mediaType == "application/xml" || mediaType == "application/yaml" ||
+		(mediaType == "" && part.URL == "")
+	if !isText || part.URL != "" || !utf8.Valid(part.Data) {
+		return "", fmt.Errorf("%w: file %q (%s) cannot be sent, only text files are supported", ErrUnsupportedContent, part.Name, part.MIMEType)
+	}
+	return fmt.Sprintf("File %s:\n%s", part.Name, part.Data), nil
+}
+
+// DataURL returns an image part's URL, or its bytes encoded as a data: URL.
+func DataURL(part types.ContentPart) string {
+	if part.URL != "" {
+		return part.URL
+	}
+	return "data:" + part.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(part.Data)
+}
+
+// ImageBytes returns an image part's bytes, decoding data: URLs.
+func ImageBytes(part types.ContentPart) ([]byte, error) {
+	if part.URL == "" {
+		return part.Data, nil
+	}
+	header, data, ok := strings.Cut(strings.TrimPrefix(part.URL, "data:"), ",")
+	if !strings.HasPrefix(part.URL, "data:") || !ok || !strings.HasSuffix(header, ";base64") {
+		return nil, fmt.Errorf("%w: cannot read image from %q", ErrUnsupportedContent, part.URL)
+	}
+	return base64.StdEncoding.DecodeString(data)
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"strings"
+
+	"gogurt/internal/config"
+)
+
+// DefaultContextWindow is assumed for models missing from ContextWindows. It is kept
+// small so unknown models are trimmed rather than overflowed.
+const DefaultContextWindow = 4096
+
+// ContextWindows holds context lengths in tokens, matched by longest model prefix like
+// ModelPrices. Ollama tags ("llama3.1:8b") match their family. Ollama serves models with
+// the num_ctx of each request instead, see ollama.ContextWindow.
+var ContextWindows = map[string]int{
+	"gpt-4o":        128000,
+	"gpt-4.1":       1047576,
+	"gpt-4-turbo":   128000,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"gpt-5":         400000,
+	"o1":            200000,
+	"o3":            200000,
+	"o4-mini":       200000,
+	"llama3":        8192,
+	"llama3.1":      131072,
+	"llama3.2":      131072,
+	"llama3.3":      131072,
+	"mistral":       32768,
+	"mixtral":       32768,
+	"qwen2.5":       32768,
+	"qwen3":         40960,
+	"gemma2":        8192,
+	"gemma3":        131072,
+	"phi3":          4096,
+	"phi4":          16384,
+	"deepseek-r1":   131072,
+}
+
+// ContextWindow returns the context length of model in tokens.
+func ContextWindow(model string) int {
+	window, matched := DefaultContextWindow, ""
+	for prefix, w := range ContextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			matched, window = prefix, w
+		}
+	}
+	return window
+}
+
+// ConfiguredContextWindow returns LLM_CONTEXT_WINDOW when set, else the registry value for model.
+func ConfiguredContextWindow(cfg *config.Config, model string) int {
+	if cfg != nil && cfg.LLMContextWindow > 0 {
+		return cfg.LLMContextWindow
+	}
+	return ContextWindow(model)
+}
+
+// ContextWindowOf returns the context window a model reports in its Metadata, falling
+// back to the registry entry for its model name.
+func ContextWindowOf(model LLM) int {
+	md := model.Metadata()
+	if w, ok := md["context_window"].(int); ok && w > 0 {
+		return w
+	}
+	name, _ := md["model"].(string)
+	return ContextWindow(name)
+}
+
+// ModelName returns the model name a backend reports in its Metadata.
+func ModelName(model LLM) string {
+	name, _ := model.Metadata()["model"].(string)
+	return name
+}
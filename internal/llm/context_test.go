@@ -0,0 +1,24 @@
+package llm
+
+import (
+	"testing"
+
+	"gogurt/internal/config"
+)
+
+func TestContextWindow(t *testing.T) {
+	cases := map[string]int{
+		"gpt-4o-2024-08-06": 128000,
+		"gpt-4-0613":        8192,
+		"llama3.1:8b":       131072,
+		"unknown-model":     DefaultContextWindow,
+	}
+	for model, want := range cases {
+		if got := ContextWindow(model); got != want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", model, got, want)
+		}
+	}
+	if got := ConfiguredContextWindow(&config.Config{LLMContextWindow: 2048}, "gpt-4o"); got != 2048 {
+		t.Errorf("expected LLM_CONTEXT_WINDOW to win, got %d", got)
+	}
+}
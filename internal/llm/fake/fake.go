@@ -0,0 +1,289 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+)
+
+// ErrNoResponse is returned when a call matches no rule and the script has run out.
+var ErrNoResponse = errors.New("fake llm: no scripted response")
+
+// Response is a scripted reply. When Err is set the call fails; a streamed call first
+// emits Content and then fails, simulating a dropped stream.
+type Response struct {
+	Content   string
+	ToolCalls []types.ToolCall
+	Usage     *types.Usage
+	Err       error
+	// Delay is waited before replying, honoring context cancellation.
+	Delay time.Duration
+}
+
+// Request is a recorded call.
+type Request struct {
+	Method   string
+	Messages []types.ChatMessage
+	Tools    []*tools.Tool
+	Options  llm.GenerateOptions
+}
+
+type rule struct {
+	match    string
+	response Response
+}
+
+// LLM replays scripted responses. For each call, rules added with OnMatch are checked
+// first; otherwise the n-th call gets the n-th scripted response, then the default.
+type LLM struct {
+	mu         sync.Mutex
+	script     []Response
+	rules      []rule
+	fallback   *Response
+	latency    time.Duration
+	tokenDelay time.Duration
+	requests   []Request
+}
+
+// New returns a fake that answers calls in order with responses.
+func New(responses ...Response) *LLM {
+	return &LLM{script: responses}
+}
+
+// Reply is shorthand for a fake that answers calls in order with the given contents.
+func Reply(contents ...string) *LLM {
+	responses := make([]Response, len(contents))
+	for i, c := range contents {
+		responses[i] = Response{Content: c}
+	}
+	return New(responses...)
+}
+
+// OnMatch answers any call whose messages contain substr with resp.
+func (f *LLM) OnMatch(substr string, resp Response) *LLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, rule{match: substr, response: resp})
+	return f
+}
+
+// WithDefault answers calls left over once the script is exhausted.
+func (f *LLM) WithDefault(resp Response) *LLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fallback = &resp
+	return f
+}
+
+// WithLatency delays every call by d, in addition to any per-response Delay.
+func (f *LLM) WithLatency(d time.Duration) *LLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = d
+	return f
+}
+
+// WithTokenDelay waits d between streamed tokens.
+func (f *LLM) WithTokenDelay(d time.Duration) *LLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokenDelay = d
+	return f
+}
+
+// Requests returns every call received so far.
+func (f *LLM) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
+
+// Calls returns the number of calls received so far.
+func (f *LLM) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+// LastRequest returns the most recent call; it panics if there was none.
+func (f *LLM) LastRequest() Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func (f *LLM) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (*types.ChatMessage, error) {
+	return f.respond(ctx, "Generate", messages, nil, opts)
+}
+
+func (f *LLM) GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...llm.Option) (*types.ChatMessage, error) {
+	return f.respond(ctx, "GenerateWithTools", messages, toolset, opts)
+}
+
+// AGenerate provides an asynchronous Generate.
+func (f *LLM) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan *types.ChatMessage, <-chan error) {
+	msgCh := make(chan *types.ChatMessage, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(msgCh)
+		defer close(errCh)
+		msg, err := f.Generate(ctx, messages, opts...)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		msgCh <- msg
+	}()
+	return msgCh, errCh
+}
+
+// Stream emits the scripted content word by word (whitespace is kept with the preceding word).
+func (f *LLM) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error, opts ...llm.Option) (*types.ChatMessage, error) {
+	resp, err := f.next(ctx, "Stream", messages, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range Tokens(resp.Content) {
+		if err := wait(ctx, f.tokenDelay); err != nil {
+			return nil, err
+		}
+		if err := onToken(token); err != nil {
+			return nil, err
+		}
+	}
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return f.message(ctx, resp), nil
+}
+
+// AStream provides an asynchronous streaming interface returning tokens.
+func (f *LLM) AStream(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan string, <-chan error) {
+	tokenCh := make(chan string)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(tokenCh)
+		defer close(errCh)
+		_, err := f.Stream(ctx, messages, func(token string) error {
+			select {
+			case tokenCh <- token:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, opts...)
+		if err != nil {
+			errCh <- err
+		}
+	}()
+	return tokenCh, errCh
+}
+
+func (f *LLM) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func (f *LLM) Metadata() map[string]any {
+	return map[string]any{"provider": "fake", "model": "fake"}
+}
+
+// Tokens splits content the way Stream emits it.
+func Tokens(content string) []string {
+	var tokens []string
+	for len(content) > 0 {
+		i := strings.IndexAny(content, " \n\t")
+		if i < 0 {
+			tokens = append(tokens, content)
+			break
+		}
+		end := i + 1
+		for end < len(content) && strings.ContainsRune(" \n\t", rune(content[end])) {
+			end++
+		}
+		tokens = append(tokens, content[:end])
+		content = content[end:]
+	}
+	return tokens
+}
+
+func (f *LLM) respond(ctx context.Context, method string, messages []types.ChatMessage, toolset []*tools.Tool, opts []llm.Option) (*types.ChatMessage, error) {
+	resp, err := f.next(ctx, method, messages, toolset, opts)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return f.message(ctx, resp), nil
+}
+
+// next records the request, picks its response and waits out any latency.
+func (f *LLM) next(ctx context.Context, method string, messages []types.ChatMessage, toolset []*tools.Tool, opts []llm.Option) (Response, error) {
+	f.mu.Lock()
+	index := len(f.requests)
+	f.requests = append(f.requests, Request{
+		Method:   method,
+		Messages: append([]types.ChatMessage(nil), messages...),
+		Tools:    toolset,
+		Options:  llm.ApplyOptions(llm.GenerateOptions{}, opts...),
+	})
+	resp, ok := f.pick(index, messages)
+	latency := f.latency
+	f.mu.Unlock()
+
+	if !ok {
+		return Response{}, fmt.Errorf("%w for call %d", ErrNoResponse, index)
+	}
+	if err := wait(ctx, latency+resp.Delay); err != nil {
+		return Response{}, err
+	}
+	return resp, nil
+}
+
+func (f *LLM) pick(index int, messages []types.ChatMessage) (Response, bool) {
+	for _, r := range f.rules {
+		for _, msg := range messages {
+			if strings.Contains(msg.Content, r.match) {
+				return r.response, true
+			}
+		}
+	}
+	if index < len(f.script) {
+		return f.script[index], true
+	}
+	if f.fallback != nil {
+		return *f.fallback, true
+	}
+	return Response{}, false
+}
+
+func (f *LLM) message(ctx context.Context, resp Response) *types.ChatMessage {
+	llm.RecordUsage(ctx, "fake", resp.Usage)
+	return &types.ChatMessage{
+		Role:      types.RoleAssistant,
+		Content:   resp.Content,
+		ToolCalls: resp.ToolCalls,
+		Usage:     resp.Usage,
+	}
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
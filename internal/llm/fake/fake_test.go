@@ -0,0 +1,60 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/types"
+)
+
+func TestScriptAndMatching(t *testing.T) {
+	f := Reply("first", "second").
+		OnMatch("weather", Response{Content: "sunny"}).
+		WithDefault(Response{Content: "default"})
+	ctx := context.Background()
+	ask := func(content string) string {
+		t.Helper()
+		msg, err := f.Generate(ctx, []types.ChatMessage{{Role: types.RoleUser, Content: content}}, llm.WithSeed(7))
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		return msg.Content
+	}
+
+	got := []string{ask("a"), ask("what's the weather?"), ask("b"), ask("c")}
+	want := []string{"first", "sunny", "default", "default"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("call %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+	if f.Calls() != 4 || *f.LastRequest().Options.Seed != 7 {
+		t.Errorf("unexpected recorded requests: %+v", f.Requests())
+	}
+}
+
+func TestStreamTokensThenError(t *testing.T) {
+	boom := errors.New("connection reset")
+	f := New(Response{Content: "hello big world", Err: boom})
+
+	var tokens []string
+	_, err := f.Stream(context.Background(), nil, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+	if strings.Join(tokens, "|") != "hello |big |world" {
+		t.Errorf("unexpected tokens %q", tokens)
+	}
+}
+
+func TestNoResponse(t *testing.T) {
+	if _, err := New().Generate(context.Background(), nil); !errors.Is(err, ErrNoResponse) {
+		t.Fatalf("expected ErrNoResponse, got %v", err)
+	}
+}
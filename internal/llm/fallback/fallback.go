@@ -0,0 +1,203 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/logger"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+)
+
+// Provider is a named LLM in a fallback chain.
+type Provider struct {
+	Name string
+	LLM  llm.LLM
+}
+
+// FallbackLLM tries an ordered list of providers on every call, moving to the next one
+// when a provider errors. A provider that fails a call or a health check is moved to the
+// end of the chain for a cooldown. The provider that answered is recorded under
+// "provider" in the response metadata.
+type FallbackLLM struct {
+	providers []Provider
+	cooldown  time.Duration
+}
+
+// downUntil is shared by every chain in the process, keyed by provider name, so a
+// provider that failed one request is tried last by the next until its cooldown ends.
+var (
+	downMu    sync.Mutex
+	downUntil = map[string]time.Time{}
+)
+
+func New(cooldown time.Duration, providers ...Provider) *FallbackLLM {
+	return &FallbackLLM{
+		providers: providers,
+		cooldown:  cooldown,
+	}
+}
+
+func (f *FallbackLLM) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (*types.ChatMessage, error) {
+	return f.do(ctx, func(ctx context.Context, p Provider) (*types.ChatMessage, error) {
+		return p.LLM.Generate(ctx, messages, opts...)
+	})
+}
+
+func (f *FallbackLLM) GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...llm.Option) (*types.ChatMessage, error) {
+	return f.do(ctx, func(ctx context.Context, p Provider) (*types.ChatMessage, error) {
+		return p.LLM.GenerateWithTools(ctx, messages, toolset, opts...)
+	})
+}
+
+// AGenerate provides an asynchronous Generate.
+func (f *FallbackLLM) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan *types.ChatMessage, <-chan error) {
+	msgCh := make(chan *types.ChatMessage, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(msgCh)
+		defer close(errCh)
+		msg, err := f.Generate(ctx, messages, opts...)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		msgCh <- msg
+	}()
+	return msgCh, errCh
+}
+
+// Stream falls back only while no token has been delivered; once a provider has started
+// streaming, its error is returned as is.
+func (f *FallbackLLM) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error, opts ...llm.Option) (*types.ChatMessage, error) {
+	started := false
+	return f.do(ctx, func(ctx context.Context, p Provider) (*types.ChatMessage, error) {
+		msg, err := p.LLM.Stream(ctx, messages, func(token string) error {
+			started = true
+			return onToken(token)
+		}, opts...)
+		if err != nil && started {
+			return nil, &startedError{err}
+		}
+		return msg, err
+	})
+}
+
+// AStream provides an asynchronous streaming interface returning tokens.
+func (f *FallbackLLM) AStream(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan string, <-chan error) {
+	tokenCh := make(chan string)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(tokenCh)
+		defer close(errCh)
+		_, err := f.Stream(ctx, messages, func(token string) error {
+			tokenCh <- token
+			return nil
+		}, opts...)
+		if err != nil {
+			errCh <- err
+		}
+	}()
+	return tokenCh, errCh
+}
+
+// HealthCheck checks every provider, marking failing ones down. It succeeds if any provider is healthy.
+func (f *FallbackLLM) HealthCheck(ctx context.Context) error {
+	var errs []error
+	for _, p := range f.providers {
+		if err := p.LLM.HealthCheck(ctx); err != nil {
+			f.markDown(p.Name)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+			continue
+		}
+		f.markUp(p.Name)
+	}
+	if len(errs) == len(f.providers) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func (f *FallbackLLM) Metadata() map[string]any {
+	names := make([]string, len(f.providers))
+	for i, p := range f.providers {
+		names[i] = p.Name
+	}
+	md := make(map[string]any)
+	if len(f.providers) > 0 {
+		for k, v := range f.providers[0].LLM.Metadata() {
+			md[k] = v
+		}
+	}
+	md["provider"] = strings.Join(names, ",")
+	return md
+}
+
+// startedError stops the chain after a provider has already streamed output.
+type startedError struct{ err error }
+
+func (e *startedError) Error() string { return e.err.Error() }
+func (e *startedError) Unwrap() error { return e.err }
+
+func (f *FallbackLLM) do(ctx context.Context, call func(ctx context.Context, p Provider) (*types.ChatMessage, error)) (*types.ChatMessage, error) {
+	var errs []error
+	for i, p := range f.order() {
+		if i > 0 {
+			logger.WarnCtx(ctx, "Falling back to LLM provider %s after: %v", p.Name, errs[len(errs)-1])
+		}
+		msg, err := call(ctx, p)
+		if err == nil {
+			f.markUp(p.Name)
+			if msg.Metadata == nil {
+				msg.Metadata = make(map[string]any)
+			}
+			msg.Metadata["provider"] = p.Name
+			return msg, nil
+		}
+		var started *startedError
+		if errors.As(err, &started) {
+			f.markDown(p.Name)
+			return nil, started.err
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		f.markDown(p.Name)
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+	}
+	return nil, fmt.Errorf("all LLM providers failed: %w", errors.Join(errs...))
+}
+
+// order returns healthy providers first, keeping the configured order within each group,
+// so a provider that is down is still tried as a last resort.
+func (f *FallbackLLM) order() []Provider {
+	downMu.Lock()
+	defer downMu.Unlock()
+	now := time.Now()
+	var up, down []Provider
+	for _, p := range f.providers {
+		if now.Before(downUntil[p.Name]) {
+			down = append(down, p)
+		} else {
+			up = append(up, p)
+		}
+	}
+	return append(up, down...)
+}
+
+func (f *FallbackLLM) markDown(name string) {
+	downMu.Lock()
+	defer downMu.Unlock()
+	downUntil[name] = time.Now().Add(f.cooldown)
+}
+
+func (f *FallbackLLM) markUp(name string) {
+	downMu.Lock()
+	defer downMu.Unlock()
+	delete(downUntil, name)
+}
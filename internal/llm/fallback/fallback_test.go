@@ -0,0 +1,103 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+)
+
+// stubLLM answers with content, or fails with err.
+type stubLLM struct {
+	content string
+	err     error
+	calls   int
+}
+
+func (s *stubLLM) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (*types.ChatMessage, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &types.ChatMessage{Role: types.RoleAssistant, Content: s.content}, nil
+}
+
+func (s *stubLLM) GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...llm.Option) (*types.ChatMessage, error) {
+	return s.Generate(ctx, messages, opts...)
+}
+
+func (s *stubLLM) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan *types.ChatMessage, <-chan error) {
+	panic("not used")
+}
+
+func (s *stubLLM) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(string) error, opts ...llm.Option) (*types.ChatMessage, error) {
+	msg, err := s.Generate(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return msg, onToken(msg.Content)
+}
+
+func (s *stubLLM) AStream(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan string, <-chan error) {
+	panic("not used")
+}
+
+func (s *stubLLM) HealthCheck(ctx context.Context) error { return s.err }
+func (s *stubLLM) Metadata() map[string]any              { return map[string]any{} }
+
+func TestFallsThroughAndRecordsProvider(t *testing.T) {
+	primary := &stubLLM{err: errors.New("connection refused")}
+	secondary := &stubLLM{content: "from azure"}
+	f := New(time.Minute, Provider{Name: "ollama", LLM: primary}, Provider{Name: "azure", LLM: secondary})
+
+	msg, err := f.Generate(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.Content != "from azure" || msg.Metadata["provider"] != "azure" {
+		t.Errorf("expected answer from azure, got %q (provider %v)", msg.Content, msg.Metadata["provider"])
+	}
+
+	// the failed provider is tried last while it cools down
+	if _, err := f.Generate(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if primary.calls != 1 || secondary.calls != 2 {
+		t.Errorf("expected ollama to be skipped, calls: ollama=%d azure=%d", primary.calls, secondary.calls)
+	}
+}
+
+func TestAllProvidersFail(t *testing.T) {
+	f := New(time.Minute,
+		Provider{Name: "ollama", LLM: &stubLLM{err: errors.New("down")}},
+		Provider{Name: "openai", LLM: &stubLLM{err: errors.New("quota")}},
+	)
+	if _, err := f.Generate(context.Background(), nil); err == nil {
+		t.Fatal("expected error when every provider fails")
+	}
+	if err := f.HealthCheck(context.Background()); err == nil {
+		t.Fatal("expected health check to fail when every provider fails")
+	}
+}
+
+func TestChainsShareDownProviders(t *testing.T) {
+	primary := &stubLLM{err: errors.New("connection refused")}
+	secondary := &stubLLM{content: "ok"}
+	chain := func() *FallbackLLM {
+		return New(time.Minute, Provider{Name: "shared-primary", LLM: primary}, Provider{Name: "shared-secondary", LLM: secondary})
+	}
+
+	// each request builds its own chain
+	for range 2 {
+		if _, err := chain().Generate(context.Background(), nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if primary.calls != 1 {
+		t.Errorf("expected the second chain to skip the provider the first found down, got %d calls", primary.calls)
+	}
+}
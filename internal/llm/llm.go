@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"gogurt/internal/tools"
 	"gogurt/internal/types"
 )
 
@@ -22,11 +23,16 @@ import (
 // 	Stream(ctx context.Context, req *CompletionRequest) (<-chan string, <-chan error)
 // }
 
+// LLM is the interface for a chat model. Every call accepts per-call Options
+// (temperature, seed, model override, ...) layered over the backend's configured defaults.
 type LLM interface {
-	Generate(ctx context.Context, messages []types.ChatMessage) (*types.ChatMessage, error)
-	AGenerate(ctx context.Context, messages []types.ChatMessage) (<-chan *types.ChatMessage, <-chan error)
-	Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error) (*types.ChatMessage, error)
-	AStream(ctx context.Context, messages []types.ChatMessage) (<-chan string, <-chan error)
+	Generate(ctx context.Context, messages []types.ChatMessage, opts ...Option) (*types.ChatMessage, error)
+	AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...Option) (<-chan *types.ChatMessage, <-chan error)
+	// GenerateWithTools offers the given tools to the model using the provider's native
+	// function-calling API. Requested calls are returned in the message's ToolCalls.
+	GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...Option) (*types.ChatMessage, error)
+	Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error, opts ...Option) (*types.ChatMessage, error)
+	AStream(ctx context.Context, messages []types.ChatMessage, opts ...Option) (<-chan string, <-chan error)
 	HealthCheck(ctx context.Context) error
 	Metadata() map[string]any
 }
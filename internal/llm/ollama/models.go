@@ -0,0 +1,154 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gogurt/internal/config"
+	"gogurt/internal/logger"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
+)
+
+// ErrModelNotFound is returned when a model has not been pulled to the Ollama server.
+var ErrModelNotFound = errors.New("model not found")
+
+// NewClient returns a client for cfg.OllamaHost. When it is unset, OLLAMA_HOST is
+// resolved as by api.ClientFromEnvironment.
+func NewClient(cfg *config.Config, httpClient *http.Client) (*api.Client, error) {
+	if cfg.OllamaHost == "" {
+		return api.NewClient(envconfig.Host(), httpClient), nil
+	}
+	host := cfg.OllamaHost
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	base, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OLLAMA_HOST %q: %w", cfg.OllamaHost, err)
+	}
+	return api.NewClient(base, httpClient), nil
+}
+
+// Options returns the model options set in config (num_ctx, num_gpu, num_thread),
+// which are sent with every chat and embedding request.
+func Options(cfg *config.Config) map[string]any {
+	options := map[string]any{}
+	if cfg.OllamaNumCtx > 0 {
+		options["num_ctx"] = cfg.OllamaNumCtx
+	}
+	if cfg.OllamaNumGPU != nil {
+		options["num_gpu"] = *cfg.OllamaNumGPU
+	}
+	if cfg.OllamaNumThread > 0 {
+		options["num_thread"] = cfg.OllamaNumThread
+	}
+	return options
+}
+
+// KeepAlive parses OLLAMA_KEEP_ALIVE as Ollama does: a duration such as "10m", or a
+// number of seconds, where negative values keep the model loaded indefinitely. An empty
+// value leaves the server default.
+func KeepAlive(value string) (*api.Duration, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return &api.Duration{Duration: time.Duration(seconds) * time.Second}, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OLLAMA_KEEP_ALIVE %q: %w", value, err)
+	}
+	return &api.Duration{Duration: d}, nil
+}
+
+// Model describes a model available on the Ollama server.
+type Model struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	ModifiedAt    time.Time `json:"modified_at"`
+	Family        string    `json:"family,omitempty"`
+	ParameterSize string    `json:"parameter_size,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+}
+
+// ListModels returns the models pulled to the server.
+func ListModels(ctx context.Context, client *api.Client) ([]Model, error) {
+	res, err := client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]Model, len(res.Models))
+	for i, m := range res.Models {
+		models[i] = Model{
+			Name:          m.Name,
+			Size:          m.Size,
+			ModifiedAt:    m.ModifiedAt,
+			Family:        m.Details.Family,
+			ParameterSize: m.Details.ParameterSize,
+			Quantization:  m.Details.QuantizationLevel,
+		}
+	}
+	return models, nil
+}
+
+// CheckModel returns ErrModelNotFound if model has not been pulled. It reads the model's
+// manifest and does not load it.
+func CheckModel(ctx context.Context, client *api.Client, model string) error {
+	_, err := client.Show(ctx, &api.ShowRequest{Model: model})
+	var status api.StatusError
+	if errors.As(err, &status) && status.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrModelNotFound, model)
+	}
+	return err
+}
+
+// Pull downloads model, reporting progress to fn if it is not nil.
+func Pull(ctx context.Context, client *api.Client, model string, fn func(api.ProgressResponse)) error {
+	return client.Pull(ctx, &api.PullRequest{Model: model}, func(p api.ProgressResponse) error {
+		if fn != nil {
+			fn(p)
+		}
+		return nil
+	})
+}
+
+// EnsureModel pulls model if it is missing, logging the download's progress.
+func EnsureModel(ctx context.Context, client *api.Client, model string) error {
+	err := CheckModel(ctx, client, model)
+	if !errors.Is(err, ErrModelNotFound) {
+		return err
+	}
+	logger.InfoCtx(ctx, "Pulling Ollama model %s", model)
+	if err := Pull(ctx, client, model, LogProgress(ctx, model)); err != nil {
+		return fmt.Errorf("failed to pull %s: %w", model, err)
+	}
+	logger.InfoCtx(ctx, "Pulled Ollama model %s", model)
+	return nil
+}
+
+// LogProgress returns a Pull callback logging status changes and every 10% of each layer.
+func LogProgress(ctx context.Context, model string) func(api.ProgressResponse) {
+	var status string
+	var step int64 = -1
+	return func(p api.ProgressResponse) {
+		if p.Total > 0 {
+			if s := p.Completed * 10 / p.Total; p.Status != status || s != step {
+				logger.InfoCtx(ctx, "Pulling %s: %s %d%%", model, p.Status, s*10)
+				step = s
+			}
+		} else if p.Status != status {
+			logger.InfoCtx(ctx, "Pulling %s: %s", model, p.Status)
+			step = -1
+		}
+		status = p.Status
+	}
+}
@@ -0,0 +1,155 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gogurt/internal/config"
+	"gogurt/internal/types"
+)
+
+// fakeServer is an Ollama server that has pulled only the models in pulled.
+type fakeServer struct {
+	mu     sync.Mutex
+	pulled map[string]bool
+	chats  []map[string]any
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/api/show":
+		if !f.pulled[body["model"].(string)] {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"model not found"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	case "/api/pull":
+		f.pulled[body["model"].(string)] = true
+		w.Write([]byte(`{"status":"pulling manifest"}` + "\n" + `{"status":"success"}` + "\n"))
+	case "/api/tags":
+		w.Write([]byte(`{"models":[{"name":"llama3.2:3b","size":2019393189,"details":{"family":"llama","parameter_size":"3.2B"}}]}`))
+	case "/api/chat":
+		f.chats = append(f.chats, body)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"hi"},"done":true}`))
+	}
+}
+
+func newTestOllama(t *testing.T, srv *fakeServer, cfg config.Config) *Ollama {
+	t.Helper()
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	cfg.OllamaHost = ts.URL
+	cfg.OllamaModel = "llama3.2:3b"
+	model, err := NewWithHTTPClient(&cfg, ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model.(*Ollama)
+}
+
+func TestHealthCheckReportsMissingModel(t *testing.T) {
+	srv := &fakeServer{pulled: map[string]bool{}}
+	o := newTestOllama(t, srv, config.Config{})
+	if err := o.HealthCheck(context.Background()); !errors.Is(err, ErrModelNotFound) {
+		t.Fatalf("expected ErrModelNotFound, got %v", err)
+	}
+	srv.pulled["llama3.2:3b"] = true
+	if err := o.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(srv.chats) != 0 {
+		t.Error("health check should not generate")
+	}
+}
+
+func TestAutoPullAndOptions(t *testing.T) {
+	srv := &fakeServer{pulled: map[string]bool{}}
+	gpu := 0
+	o := newTestOllama(t, srv, config.Config{OllamaAutoPull: true, OllamaKeepAlive: "10m", OllamaNumCtx: 8192, OllamaNumGPU: &gpu})
+
+	if _, err := o.Generate(context.Background(), []types.ChatMessage{{Role: types.RoleUser, Content: "hello"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !srv.pulled["llama3.2:3b"] {
+		t.Error("expected the model to be pulled")
+	}
+	options := srv.chats[0]["options"].(map[string]any)
+	if options["num_ctx"] != 8192.0 || options["num_gpu"] != 0.0 {
+		t.Errorf("unexpected options %v", options)
+	}
+	if srv.chats[0]["keep_alive"] == nil {
+		t.Error("expected keep_alive to be sent")
+	}
+	if o.Metadata()["context_window"] != 8192 {
+		t.Errorf("expected num_ctx as context window, got %v", o.Metadata()["context_window"])
+	}
+}
+
+func TestNumCtxMatchesContextWindow(t *testing.T) {
+	for _, tt := range []struct {
+		cfg  config.Config
+		want int
+	}{
+		{config.Config{}, DefaultNumCtx},
+		{config.Config{LLMContextWindow: 16384}, 16384},
+		{config.Config{LLMContextWindow: 16384, OllamaNumCtx: 8192}, 16384},
+	} {
+		srv := &fakeServer{pulled: map[string]bool{"llama3.2:3b": true}}
+		o := newTestOllama(t, srv, tt.cfg)
+		if _, err := o.Generate(context.Background(), []types.ChatMessage{{Role: types.RoleUser, Content: "hello"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		options := srv.chats[0]["options"].(map[string]any)
+		if options["num_ctx"] != float64(tt.want) || o.Metadata()["context_window"] != tt.want {
+			t.Errorf("%+v: expected num_ctx and context window %d, got %v and %v", tt.cfg, tt.want, options["num_ctx"], o.Metadata()["context_window"])
+		}
+	}
+}
+
+func TestListModels(t *testing.T) {
+	o := newTestOllama(t, &fakeServer{}, config.Config{})
+	models, err := o.ListModels(context.Background())
+	if err != nil || len(models) != 1 || models[0].Family != "llama" {
+		t.Fatalf("unexpected models %+v, %v", models, err)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	for value, want := range map[string]time.Duration{"10m": 10 * time.Minute, "300": 5 * time.Minute, "-1": -time.Second} {
+		d, err := KeepAlive(value)
+		if err != nil || d.Duration != want {
+			t.Errorf("KeepAlive(%q) = %v, %v; want %v", value, d, err, want)
+		}
+	}
+	if d, _ := KeepAlive(""); d != nil {
+		t.Error("expected nil for an empty value")
+	}
+	if _, err := KeepAlive("soon"); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
+
+func TestContentSupportFailsWhenCapabilitiesAreUnknown(t *testing.T) {
+	srv := &fakeServer{pulled: map[string]bool{}}
+	o := newTestOllama(t, srv, config.Config{})
+	messages := []types.ChatMessage{{Role: types.RoleUser, Parts: []types.ContentPart{types.ImagePart([]byte("img"), "image/png")}}}
+	if _, err := o.contentSupport(context.Background(), "llava", messages); err == nil {
+		t.Fatal("expected an error when the model cannot be looked up")
+	}
+	srv.pulled["llava"] = true
+	support, err := o.contentSupport(context.Background(), "llava", messages)
+	if err != nil || support.Images {
+		t.Fatalf("expected a model without the vision capability to refuse images, got %+v, %v", support, err)
+	}
+}
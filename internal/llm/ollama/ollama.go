@@ -2,70 +2,171 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"net/http"
+	"slices"
 	"strings"
+	"sync"
 
 	"gogurt/internal/config"
 	"gogurt/internal/llm"
+	"gogurt/internal/llm/retry"
+	"gogurt/internal/tools"
 	"gogurt/internal/types"
 
 	"github.com/ollama/ollama/api"
+	ollamamodel "github.com/ollama/ollama/types/model"
 )
 
 type Ollama struct {
-	client *api.Client
-	model  string
+	client   *api.Client
+	model    string
+	defaults llm.GenerateOptions
+	// contextWindow is the model's context length in tokens
+	contextWindow int
+	// options are the configured model options, e.g. num_ctx, sent with every request
+	options   map[string]any
+	keepAlive *api.Duration
+	autoPull  bool
+
+	mu sync.Mutex
+	// vision caches whether each model accepts images
+	vision map[string]bool
+
+	pullMu sync.Mutex
+	// pulled records models known to be present when autoPull is set
+	pulled map[string]bool
 }
 
-// HealthCheck implements types.LLM.
+// HealthCheck implements types.LLM. It checks that the server is up and the model
+// pulled, without loading the model or generating tokens.
 func (o *Ollama) HealthCheck(ctx context.Context) error {
-	_, err := o.Generate(ctx, []types.ChatMessage{{Role: "system", Content: "ping"}})
-	return err
+	return CheckModel(ctx, o.client, o.model)
+}
+
+// ListModels returns the models pulled to the server.
+func (o *Ollama) ListModels(ctx context.Context) ([]Model, error) {
+	return ListModels(ctx, o.client)
+}
+
+// Pull downloads model, reporting progress to fn if it is not nil.
+func (o *Ollama) Pull(ctx context.Context, model string, fn func(api.ProgressResponse)) error {
+	return Pull(ctx, o.client, model, fn)
 }
 
 // Metadata implements types.LLM.
 func (o *Ollama) Metadata() map[string]any {
 	md := make(map[string]any)
 	md["model"] = o.model
+	md["context_window"] = o.contextWindow
 	return md
 }
 
+// DefaultNumCtx is the context length used when neither LLM_CONTEXT_WINDOW nor
+// OLLAMA_NUM_CTX is set. It matches what recent Ollama servers default to.
+const DefaultNumCtx = 4096
+
+// ContextWindow returns the context length of chat requests: LLM_CONTEXT_WINDOW, else
+// OLLAMA_NUM_CTX, else DefaultNumCtx, capped by the model's registry value. Chat requests
+// send it as num_ctx, so the server keeps exactly the prompt trimmed to fit it.
+func ContextWindow(cfg *config.Config) int {
+	switch {
+	case cfg.LLMContextWindow > 0:
+		return cfg.LLMContextWindow
+	case cfg.OllamaNumCtx > 0:
+		return cfg.OllamaNumCtx
+	}
+	return min(DefaultNumCtx, llm.ContextWindow(cfg.OllamaModel))
+}
+
 func New(cfg *config.Config) (llm.LLM, error) {
-	client, err := api.ClientFromEnvironment()
+	return NewWithHTTPClient(cfg, retry.HTTPClient())
+}
+
+func NewWithHTTPClient(cfg *config.Config, httpClient *http.Client) (llm.LLM, error) {
+	client, err := NewClient(cfg, httpClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ollama client: %w", err)
+		return nil, err
+	}
+	keepAlive, err := KeepAlive(cfg.OllamaKeepAlive)
+	if err != nil {
+		return nil, err
 	}
+	contextWindow := ContextWindow(cfg)
+	options := Options(cfg)
+	options["num_ctx"] = contextWindow
 
 	return &Ollama{
-		client: client,
-		model:  cfg.OllamaModel,
+		client:        client,
+		model:         cfg.OllamaModel,
+		defaults:      llm.DefaultOptions(cfg),
+		contextWindow: contextWindow,
+		options:       options,
+		keepAlive:     keepAlive,
+		autoPull:      cfg.OllamaAutoPull,
+		vision:        make(map[string]bool),
+		pulled:        make(map[string]bool),
 	}, nil
 }
 
+// ensureModel pulls model on first use when auto-pull is enabled. Pulls are serialized
+// so concurrent first calls download the model once.
+func (o *Ollama) ensureModel(ctx context.Context, model string) error {
+	if !o.autoPull {
+		return nil
+	}
+	o.pullMu.Lock()
+	defer o.pullMu.Unlock()
+	if o.pulled[model] {
+		return nil
+	}
+	if err := EnsureModel(ctx, o.client, model); err != nil {
+		return err
+	}
+	o.pulled[model] = true
+	return nil
+}
+
 // Generate generates a response from the Ollama API.
-func (o *Ollama) Generate(ctx context.Context, messages []types.ChatMessage) (*types.ChatMessage, error) {
-	apiMessages := make([]api.Message, len(messages))
-	for i, msg := range messages {
-		apiMessages[i] = api.Message{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		}
+func (o *Ollama) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (*types.ChatMessage, error) {
+	return o.GenerateWithTools(ctx, messages, nil, opts...)
+}
+
+// GenerateWithTools generates a response from the Ollama API, offering toolset as callable functions.
+func (o *Ollama) GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...llm.Option) (*types.ChatMessage, error) {
+	apiTools, err := toOllamaTools(toolset)
+	if err != nil {
+		return nil, err
 	}
 
-	req := &api.ChatRequest{
-		Model:    o.model,
-		Messages: apiMessages,
+	req, err := o.newRequest(ctx, messages, opts)
+	if err != nil {
+		return nil, err
 	}
+	req.Tools = apiTools
 
 	var responseContent strings.Builder
 	var responseRole types.Role
+	var toolCalls []types.ToolCall
+	var usage *types.Usage
 
-	err := o.client.Chat(ctx, req, func(res api.ChatResponse) error {
+	err = o.client.Chat(ctx, req, func(res api.ChatResponse) error {
 		responseContent.WriteString(res.Message.Content)
+		if res.Done {
+			usage = fromOllamaMetrics(res.Metrics)
+		}
 
 		if responseRole == "" {
 			responseRole = types.Role(res.Message.Role)
 		}
+		for _, call := range res.Message.ToolCalls {
+			toolCalls = append(toolCalls, types.ToolCall{
+				Name: call.Function.Name,
+				Args: map[string]any(call.Function.Arguments),
+			})
+		}
 		return nil
 	})
 
@@ -77,20 +178,170 @@ func (o *Ollama) Generate(ctx context.Context, messages []types.ChatMessage) (*t
 		responseRole = types.RoleAssistant
 	}
 
+	llm.RecordUsage(ctx, req.Model, usage)
 	return &types.ChatMessage{
-		Role:    responseRole,
-		Content: responseContent.String(),
+		Role:      responseRole,
+		Content:   responseContent.String(),
+		ToolCalls: toolCalls,
+		Usage:     usage,
 	}, nil
 }
 
+// newRequest builds a chat request with the configured defaults and per-call options
+// mapped onto Ollama's model options, pulling the model first if needed.
+func (o *Ollama) newRequest(ctx context.Context, messages []types.ChatMessage, opts []llm.Option) (*api.ChatRequest, error) {
+	options := llm.ApplyOptions(o.defaults, opts...)
+	req := &api.ChatRequest{
+		Model:     o.model,
+		Options:   maps.Clone(o.options),
+		KeepAlive: o.keepAlive,
+	}
+	if options.Model != "" {
+		req.Model = options.Model
+	}
+	if err := o.ensureModel(ctx, req.Model); err != nil {
+		return nil, err
+	}
+	support, err := o.contentSupport(ctx, req.Model, messages)
+	if err != nil {
+		return nil, err
+	}
+	apiMessages, err := toOllamaMessages(messages, support)
+	if err != nil {
+		return nil, err
+	}
+	req.Messages = apiMessages
+	if options.Temperature != nil {
+		req.Options["temperature"] = *options.Temperature
+	}
+	if options.TopP != nil {
+		req.Options["top_p"] = *options.TopP
+	}
+	if options.MaxTokens > 0 {
+		req.Options["num_predict"] = options.MaxTokens
+	}
+	if len(options.Stop) > 0 {
+		req.Options["stop"] = options.Stop
+	}
+	if options.Seed != nil {
+		req.Options["seed"] = *options.Seed
+	}
+	if options.JSONSchema != nil {
+		// Ollama constrains decoding to the schema
+		if schema, err := json.Marshal(options.JSONSchema); err == nil {
+			req.Format = schema
+		}
+	}
+	return req, nil
+}
+
+// contentSupport reports whether model can take the images in messages. Ollama lists a
+// "vision" capability per model; the answer is cached. If it cannot be looked up, images
+// are not sent blind and an error is returned instead.
+func (o *Ollama) contentSupport(ctx context.Context, model string, messages []types.ChatMessage) (llm.ContentSupport, error) {
+	if !llm.HasImages(messages) {
+		return llm.ContentSupport{Images: true}, nil
+	}
+	o.mu.Lock()
+	vision, ok := o.vision[model]
+	o.mu.Unlock()
+	if !ok {
+		show, err := o.client.Show(ctx, &api.ShowRequest{Model: model})
+		if err != nil {
+			return llm.ContentSupport{}, fmt.Errorf("cannot tell whether %s accepts images: %w", model, err)
+		}
+		vision = slices.Contains(show.Capabilities, ollamamodel.CapabilityVision)
+		o.mu.Lock()
+		o.vision[model] = vision
+		o.mu.Unlock()
+	}
+	return llm.ContentSupport{Images: vision}, nil
+}
+
+// toOllamaMessages converts chat messages, including tool calls, tool results and images.
+// Ollama takes images as raw bytes, so image URLs other than data: URLs are rejected.
+func toOllamaMessages(messages []types.ChatMessage, support llm.ContentSupport) ([]api.Message, error) {
+	apiMessages := make([]api.Message, len(messages))
+	for i, msg := range messages {
+		apiMessages[i] = api.Message{
+			Role:     string(msg.Role),
+			Content:  msg.Content,
+			ToolName: msg.Name,
+		}
+		if len(msg.Parts) > 0 {
+			parts, err := llm.ResolveParts(msg, support)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			var text []string
+			for _, part := range parts {
+				if part.Type == types.PartText {
+					text = append(text, part.Text)
+					continue
+				}
+				image, err := llm.ImageBytes(part)
+				if err != nil {
+					return nil, fmt.Errorf("message %d: %w", i, err)
+				}
+				apiMessages[i].Images = append(apiMessages[i].Images, api.ImageData(image))
+			}
+			apiMessages[i].Content = strings.Join(text, "\n\n")
+		}
+		for _, call := range msg.ToolCalls {
+			apiMessages[i].ToolCalls = append(apiMessages[i].ToolCalls, api.ToolCall{
+				Function: api.ToolCallFunction{
+					Name:      call.Name,
+					Arguments: api.ToolCallFunctionArguments(call.Args),
+				},
+			})
+		}
+	}
+	return apiMessages, nil
+}
+
+// fromOllamaMetrics converts the token counts reported on the final chunk of a response.
+func fromOllamaMetrics(m api.Metrics) *types.Usage {
+	return &types.Usage{
+		PromptTokens:     m.PromptEvalCount,
+		CompletionTokens: m.EvalCount,
+		TotalTokens:      m.PromptEvalCount + m.EvalCount,
+	}
+}
+
+// toOllamaTools describes tools as Ollama function definitions. Ollama uses a typed
+// parameter schema, so the tool's JSON schema is round-tripped through JSON.
+func toOllamaTools(toolset []*tools.Tool) (api.Tools, error) {
+	if len(toolset) == 0 {
+		return nil, nil
+	}
+	apiTools := make(api.Tools, len(toolset))
+	for i, tool := range toolset {
+		apiTools[i] = api.Tool{
+			Type: "function",
+			Function: api.ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+			},
+		}
+		schema, err := json.Marshal(tool.Parameters())
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal schema for tool %q: %w", tool.Name, err)
+		}
+		if err := json.Unmarshal(schema, &apiTools[i].Function.Parameters); err != nil {
+			return nil, fmt.Errorf("unsupported schema for tool %q: %w", tool.Name, err)
+		}
+	}
+	return apiTools, nil
+}
+
 // AGenerate provides an asynchronous Generate.
-func (o *Ollama) AGenerate(ctx context.Context, messages []types.ChatMessage) (<-chan *types.ChatMessage, <-chan error) {
+func (o *Ollama) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan *types.ChatMessage, <-chan error) {
 	msgCh := make(chan *types.ChatMessage, 1)
 	errCh := make(chan error, 1)
 	go func() {
 		defer close(msgCh)
 		defer close(errCh)
-		msg, err := o.Generate(ctx, messages)
+		msg, err := o.Generate(ctx, messages, opts...)
 		if err != nil {
 			errCh <- err
 			return
@@ -101,26 +352,22 @@ func (o *Ollama) AGenerate(ctx context.Context, messages []types.ChatMessage) (<
 }
 
 // Stream streams model output from Ollama, forwarding chunks to onToken.
-func (o *Ollama) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error) (*types.ChatMessage, error) {
-	apiMessages := make([]api.Message, len(messages))
-	for i, msg := range messages {
-		apiMessages[i] = api.Message{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		}
-	}
-
+func (o *Ollama) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error, opts ...llm.Option) (*types.ChatMessage, error) {
 	stream := true
-	req := &api.ChatRequest{
-		Model:    o.model,
-		Messages: apiMessages,
-		Stream:   &stream,
+	req, err := o.newRequest(ctx, messages, opts)
+	if err != nil {
+		return nil, err
 	}
+	req.Stream = &stream
 
 	var responseContent strings.Builder
 	var responseRole types.Role
+	var usage *types.Usage
 
-	err := o.client.Chat(ctx, req, func(res api.ChatResponse) error {
+	err = o.client.Chat(ctx, req, func(res api.ChatResponse) error {
+		if res.Done {
+			usage = fromOllamaMetrics(res.Metrics)
+		}
 		if responseRole == "" && res.Message.Role != "" {
 			responseRole = types.Role(res.Message.Role)
 		}
@@ -143,14 +390,16 @@ func (o *Ollama) Stream(ctx context.Context, messages []types.ChatMessage, onTok
 		responseRole = types.RoleAssistant
 	}
 
+	llm.RecordUsage(ctx, req.Model, usage)
 	return &types.ChatMessage{
 		Role:    responseRole,
 		Content: responseContent.String(),
+		Usage:   usage,
 	}, nil
 }
 
 // AStream provides an asynchronous streaming interface returning tokens.
-func (o *Ollama) AStream(ctx context.Context, messages []types.ChatMessage) (<-chan string, <-chan error) {
+func (o *Ollama) AStream(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan string, <-chan error) {
 	tokenCh := make(chan string)
 	errCh := make(chan error, 1)
 	go func() {
@@ -160,7 +409,7 @@ func (o *Ollama) AStream(ctx context.Context, messages []types.ChatMessage) (<-c
 		_, err := o.Stream(ctx, messages, func(token string) error {
 			tokenCh <- token
 			return nil
-		})
+		}, opts...)
 		if err != nil {
 			errCh <- err
 		}
@@ -0,0 +1,77 @@
+package ollama
+
+import (
+	"errors"
+	"testing"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+)
+
+func TestToOllamaTools(t *testing.T) {
+	apiTools, err := toOllamaTools([]*tools.Tool{tools.AddTool, tools.UppercaseTool})
+	if err != nil {
+		t.Fatalf("toOllamaTools() error = %v", err)
+	}
+	if len(apiTools) != 2 {
+		t.Fatalf("expected 2 tools, got %d", len(apiTools))
+	}
+	add := apiTools[0].Function
+	if add.Name != "add" || apiTools[0].Type != "function" {
+		t.Errorf("unexpected tool: %+v", apiTools[0])
+	}
+	if add.Parameters.Type != "object" {
+		t.Errorf("parameters type = %q, want object", add.Parameters.Type)
+	}
+	if prop, ok := add.Parameters.Properties["a"]; !ok || prop.Type.String() != "integer" {
+		t.Errorf("property a = %+v, want integer", prop)
+	}
+	if len(add.Parameters.Required) != 2 {
+		t.Errorf("required = %v, want [a b]", add.Parameters.Required)
+	}
+}
+
+func TestToOllamaMessagesToolRoundTrip(t *testing.T) {
+	messages := []types.ChatMessage{
+		{Role: types.RoleAssistant, ToolCalls: []types.ToolCall{{Name: "add", Args: map[string]any{"a": 1, "b": 2}}}},
+		{Role: types.RoleTool, Name: "add", Content: "3"},
+	}
+	apiMessages, err := toOllamaMessages(messages, llm.ContentSupport{})
+	if err != nil {
+		t.Fatalf("toOllamaMessages() error = %v", err)
+	}
+	if len(apiMessages[0].ToolCalls) != 1 || apiMessages[0].ToolCalls[0].Function.Name != "add" {
+		t.Errorf("assistant tool calls not converted: %+v", apiMessages[0])
+	}
+	if apiMessages[1].Role != "tool" || apiMessages[1].ToolName != "add" || apiMessages[1].Content != "3" {
+		t.Errorf("tool result not converted: %+v", apiMessages[1])
+	}
+}
+
+func TestToOllamaMessagesImages(t *testing.T) {
+	messages := []types.ChatMessage{{
+		Role:    types.RoleUser,
+		Content: "What is this?",
+		Parts:   []types.ContentPart{types.ImageURLPart("data:image/png;base64,iVBO"), types.TextPart("Be brief.")},
+	}}
+	apiMessages, err := toOllamaMessages(messages, llm.ContentSupport{Images: true})
+	if err != nil {
+		t.Fatalf("toOllamaMessages() error = %v", err)
+	}
+	if len(apiMessages[0].Images) != 1 || string(apiMessages[0].Images[0]) != "\x89PN" {
+		t.Errorf("image not decoded: %q", apiMessages[0].Images)
+	}
+	if apiMessages[0].Content != "What is this?\n\nBe brief." {
+		t.Errorf("unexpected content %q", apiMessages[0].Content)
+	}
+
+	messages[0].Parts = []types.ContentPart{types.ImageURLPart("https://example.com/cat.png")}
+	if _, err := toOllamaMessages(messages, llm.ContentSupport{Images: true}); !errors.Is(err, llm.ErrUnsupportedContent) {
+		t.Errorf("expected ErrUnsupportedContent for a remote URL, got %v", err)
+	}
+	messages[0].Parts = []types.ContentPart{types.ImagePart([]byte("img"), "image/png")}
+	if _, err := toOllamaMessages(messages, llm.ContentSupport{}); !errors.Is(err, llm.ErrUnsupportedContent) {
+		t.Errorf("expected ErrUnsupportedContent for a text-only model, got %v", err)
+	}
+}
@@ -2,11 +2,17 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"gogurt/internal/config"
 	"gogurt/internal/llm"
+	"gogurt/internal/llm/openaiconv"
+	"gogurt/internal/llm/retry"
+	"gogurt/internal/tools"
 	"gogurt/internal/types"
 	"io"
+	"math"
+	"net/http"
 	"os"
 	"strings"
 
@@ -14,10 +20,19 @@ import (
 )
 
 type OpenAI struct {
-	client *openai.Client
+	client   *openai.Client
+	model    string
+	provider string
+	defaults llm.GenerateOptions
+	// contextWindow is the model's context length in tokens
+	contextWindow int
 }
 
 func New(cfg *config.Config) (llm.LLM, error) {
+	return NewWithHTTPClient(cfg, retry.HTTPClient())
+}
+
+func NewWithHTTPClient(cfg *config.Config, httpClient *http.Client) (llm.LLM, error) {
 	apiKey := ""
 	if cfg != nil {
 		apiKey = cfg.OpenAIAPIKey
@@ -28,23 +43,56 @@ func New(cfg *config.Config) (llm.LLM, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("openai api key not provided (config.OpenAIAPIKey or OPENAI_API_KEY)")
 	}
-	client := openai.NewClient(apiKey)
-	return &OpenAI{client: client}, nil
+	clientCfg := openai.DefaultConfig(apiKey)
+	clientCfg.HTTPClient = httpClient
+	model := openai.GPT4o
+	if cfg != nil && cfg.OpenAIModel != "" {
+		model = cfg.OpenAIModel
+	}
+	return &OpenAI{
+		client:        openai.NewClientWithConfig(clientCfg),
+		model:         model,
+		provider:      "OpenAI",
+		defaults:      llm.DefaultOptions(cfg),
+		contextWindow: llm.ConfiguredContextWindow(cfg, model),
+	}, nil
+}
+
+// NewCompatible creates a backend for a server implementing the OpenAI chat API, such as
+// llama.cpp server, vLLM, LM Studio or LocalAI. The API key is optional.
+func NewCompatible(cfg *config.Config) (llm.LLM, error) {
+	if cfg.OpenAICompatibleBaseURL == "" {
+		return nil, fmt.Errorf("openai-compatible base url not provided (OPENAI_COMPATIBLE_BASE_URL)")
+	}
+	if cfg.OpenAICompatibleModel == "" {
+		return nil, fmt.Errorf("openai-compatible model not provided (OPENAI_COMPATIBLE_MODEL)")
+	}
+	clientCfg := openai.DefaultConfig(cfg.OpenAICompatibleAPIKey)
+	clientCfg.BaseURL = strings.TrimRight(cfg.OpenAICompatibleBaseURL, "/")
+	clientCfg.HTTPClient = retry.HTTPClient()
+	return &OpenAI{
+		client:        openai.NewClientWithConfig(clientCfg),
+		model:         cfg.OpenAICompatibleModel,
+		provider:      "openai-compatible",
+		defaults:      llm.DefaultOptions(cfg),
+		contextWindow: llm.ConfiguredContextWindow(cfg, cfg.OpenAICompatibleModel),
+	}, nil
 }
 
 // Generate generates a response from the OpenAI API.
-func (o *OpenAI) Generate(ctx context.Context, messages []types.ChatMessage) (*types.ChatMessage, error) {
-	apiMessages := make([]openai.ChatCompletionMessage, len(messages))
-	for i, msg := range messages {
-		apiMessages[i] = openai.ChatCompletionMessage{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		}
+func (o *OpenAI) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (*types.ChatMessage, error) {
+	return o.GenerateWithTools(ctx, messages, nil, opts...)
+}
+
+// GenerateWithTools generates a response, offering toolset as callable functions.
+func (o *OpenAI) GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...llm.Option) (*types.ChatMessage, error) {
+	apiMessages, err := openaiconv.Messages(messages, o.contentSupport(opts))
+	if err != nil {
+		return nil, err
 	}
-	resp, err := o.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:    openai.GPT4o,
-		Messages: apiMessages,
-	})
+	req := o.newRequest(apiMessages, opts)
+	req.Tools = openaiconv.Tools(toolset)
+	resp, err := o.client.CreateChatCompletion(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -52,20 +100,28 @@ func (o *OpenAI) Generate(ctx context.Context, messages []types.ChatMessage) (*t
 		return nil, fmt.Errorf("no choices returned from OpenAI")
 	}
 	responseMessage := resp.Choices[0].Message
+	toolCalls, err := openaiconv.ToolCalls(responseMessage.ToolCalls)
+	if err != nil {
+		return nil, err
+	}
+	usage := openaiconv.Usage(resp.Usage)
+	llm.RecordUsage(ctx, req.Model, usage)
 	return &types.ChatMessage{
-		Role:    types.Role(responseMessage.Role),
-		Content: responseMessage.Content,
+		Role:      types.Role(responseMessage.Role),
+		Content:   responseMessage.Content,
+		ToolCalls: toolCalls,
+		Usage:     usage,
 	}, nil
 }
 
 // AGenerate provides an asynchronous Generate.
-func (o *OpenAI) AGenerate(ctx context.Context, messages []types.ChatMessage) (<-chan *types.ChatMessage, <-chan error) {
+func (o *OpenAI) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan *types.ChatMessage, <-chan error) {
 	msgCh := make(chan *types.ChatMessage, 1)
 	errCh := make(chan error, 1)
 	go func() {
 		defer close(msgCh)
 		defer close(errCh)
-		msg, err := o.Generate(ctx, messages)
+		msg, err := o.Generate(ctx, messages, opts...)
 		if err != nil {
 			errCh <- err
 			return
@@ -76,18 +132,14 @@ func (o *OpenAI) AGenerate(ctx context.Context, messages []types.ChatMessage) (<
 }
 
 // Stream streams the model output. onToken is called for each streamed chunk.
-func (o *OpenAI) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error) (*types.ChatMessage, error) {
-	apiMessages := make([]openai.ChatCompletionMessage, len(messages))
-	for i, msg := range messages {
-		apiMessages[i] = openai.ChatCompletionMessage{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		}
-	}
-	req := openai.ChatCompletionRequest{
-		Model:    openai.GPT4o,
-		Messages: apiMessages,
+func (o *OpenAI) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error, opts ...llm.Option) (*types.ChatMessage, error) {
+	apiMessages, err := openaiconv.Messages(messages, o.contentSupport(opts))
+	if err != nil {
+		return nil, err
 	}
+	req := o.newRequest(apiMessages, opts)
+	// ask for a final chunk carrying the token usage of the whole stream
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
 	stream, err := o.client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
 		return nil, err
@@ -95,6 +147,7 @@ func (o *OpenAI) Stream(ctx context.Context, messages []types.ChatMessage, onTok
 	defer stream.Close()
 	var responseContent strings.Builder
 	var responseRole types.Role
+	var usage *types.Usage
 	for {
 		part, err := stream.Recv()
 		if err == io.EOF {
@@ -103,6 +156,9 @@ func (o *OpenAI) Stream(ctx context.Context, messages []types.ChatMessage, onTok
 		if err != nil {
 			return nil, err
 		}
+		if part.Usage != nil {
+			usage = openaiconv.Usage(*part.Usage)
+		}
 		for _, choice := range part.Choices {
 			if responseRole == "" && choice.Delta.Role != "" {
 				responseRole = types.Role(choice.Delta.Role)
@@ -119,14 +175,16 @@ func (o *OpenAI) Stream(ctx context.Context, messages []types.ChatMessage, onTok
 	if responseRole == "" {
 		responseRole = types.RoleAssistant
 	}
+	llm.RecordUsage(ctx, req.Model, usage)
 	return &types.ChatMessage{
 		Role:    responseRole,
 		Content: responseContent.String(),
+		Usage:   usage,
 	}, nil
 }
 
 // AStream provides an asynchronous streaming interface returning tokens.
-func (o *OpenAI) AStream(ctx context.Context, messages []types.ChatMessage) (<-chan string, <-chan error) {
+func (o *OpenAI) AStream(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan string, <-chan error) {
 	tokenCh := make(chan string)
 	errCh := make(chan error, 1)
 	go func() {
@@ -135,7 +193,7 @@ func (o *OpenAI) AStream(ctx context.Context, messages []types.ChatMessage) (<-c
 		_, err := o.Stream(ctx, messages, func(token string) error {
 			tokenCh <- token
 			return nil
-		})
+		}, opts...)
 		if err != nil {
 			errCh <- err
 		}
@@ -143,13 +201,71 @@ func (o *OpenAI) AStream(ctx context.Context, messages []types.ChatMessage) (<-c
 	return tokenCh, errCh
 }
 
+// contentSupport reports which content parts the model selected by opts accepts.
+func (o *OpenAI) contentSupport(opts []llm.Option) llm.ContentSupport {
+	model := o.model
+	if m := llm.ApplyOptions(o.defaults, opts...).Model; m != "" {
+		model = m
+	}
+	return llm.ContentSupport{Images: llm.SupportsImages(model), ImageURLs: true}
+}
+
+// newRequest builds a chat completion request with the configured defaults and per-call options applied.
+func (o *OpenAI) newRequest(messages []openai.ChatCompletionMessage, opts []llm.Option) openai.ChatCompletionRequest {
+	options := llm.ApplyOptions(o.defaults, opts...)
+	req := openai.ChatCompletionRequest{
+		Model:     o.model,
+		Messages:  messages,
+		MaxTokens: options.MaxTokens,
+		Stop:      options.Stop,
+		Seed:      options.Seed,
+	}
+	if options.Model != "" {
+		req.Model = options.Model
+	}
+	if options.Temperature != nil {
+		// the client omits a zero temperature, so send the smallest non-zero value instead
+		req.Temperature = max(*options.Temperature, math.SmallestNonzeroFloat32)
+	}
+	if options.TopP != nil {
+		req.TopP = *options.TopP
+	}
+	req.ResponseFormat = responseFormat(options)
+	return req
+}
+
+// responseFormat maps a requested JSON schema onto OpenAI structured outputs. The API
+// only accepts object schemas, so other schemas are left to prompt-level instructions.
+func responseFormat(options llm.GenerateOptions) *openai.ChatCompletionResponseFormat {
+	if options.JSONSchema == nil || options.JSONSchema["type"] != "object" {
+		return nil
+	}
+	schema, err := json.Marshal(options.JSONSchema)
+	if err != nil {
+		return nil
+	}
+	name := options.JSONSchemaName
+	if name == "" {
+		name = "response"
+	}
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   name,
+			Schema: json.RawMessage(schema),
+		},
+	}
+}
+
 func (o *OpenAI) HealthCheck(ctx context.Context) error {
 	_, err := o.Generate(ctx, []types.ChatMessage{{Role: "system", Content: "ping"}})
 	return err
 }
 func (o *OpenAI) Metadata() map[string]interface{} {
 	return map[string]interface{}{
-		"provider": "OpenAI",
-		"version":  "latest",
+		"provider":       o.provider,
+		"model":          o.model,
+		"version":        "latest",
+		"context_window": o.contextWindow,
 	}
 }
@@ -0,0 +1,106 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gogurt/internal/config"
+	"gogurt/internal/llm"
+	"gogurt/internal/types"
+)
+
+// fakeCompatibleServer mimics the chat completions endpoint of a local OpenAI-compatible server.
+func fakeCompatibleServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("expected no Authorization header without an api key, got %q", auth)
+		}
+		var req struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "qwen2.5-7b-instruct" {
+			t.Errorf("unexpected model %q", req.Model)
+		}
+		if req.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, tok := range []string{"Hel", "lo"} {
+				w.Write([]byte(`data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"` + tok + `"}}]}` + "\n\n"))
+			}
+			w.Write([]byte(`data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}` + "\n\n"))
+			w.Write([]byte("data: [DONE]\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"Hello"}}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNewCompatible(t *testing.T) {
+	srv := fakeCompatibleServer(t)
+	model, err := NewCompatible(&config.Config{
+		OpenAICompatibleBaseURL: srv.URL + "/v1/",
+		OpenAICompatibleModel:   "qwen2.5-7b-instruct",
+	})
+	if err != nil {
+		t.Fatalf("NewCompatible: %v", err)
+	}
+	messages := []types.ChatMessage{{Role: types.RoleUser, Content: "hi"}}
+
+	msg, err := model.Generate(context.Background(), messages)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if msg.Content != "Hello" || msg.Usage == nil || msg.Usage.TotalTokens != 4 {
+		t.Errorf("unexpected response %+v", msg)
+	}
+
+	var streamed strings.Builder
+	msg, err = model.Stream(context.Background(), messages, func(token string) error {
+		streamed.WriteString(token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if streamed.String() != "Hello" || msg.Content != "Hello" || msg.Usage == nil || msg.Usage.TotalTokens != 5 {
+		t.Errorf("unexpected stream result %q, %+v", streamed.String(), msg)
+	}
+	if got := model.Metadata()["provider"]; got != "openai-compatible" {
+		t.Errorf("unexpected provider %v", got)
+	}
+}
+
+func TestNewCompatibleRequiresBaseURL(t *testing.T) {
+	if _, err := NewCompatible(&config.Config{OpenAICompatibleModel: "m"}); err == nil {
+		t.Fatal("expected error without base url")
+	}
+}
+
+func TestTextOnlyModelRejectsImages(t *testing.T) {
+	messages := []types.ChatMessage{{
+		Role:    types.RoleUser,
+		Content: "Describe the screenshot.",
+		Parts:   []types.ContentPart{types.ImagePart([]byte("png"), "image/png")},
+	}}
+	model, err := NewWithHTTPClient(&config.Config{OpenAIAPIKey: "test", OpenAIModel: "gpt-3.5-turbo"}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.Generate(context.Background(), messages); !errors.Is(err, llm.ErrUnsupportedContent) {
+		t.Errorf("expected ErrUnsupportedContent for a text-only model, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Package openaiconv converts between gogurt's chat types and the go-openai client's, for
+// the backends speaking the OpenAI chat completions API.
+package openaiconv
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Messages converts chat messages, including tool calls and tool results. Content parts
+// are resolved against what the model supports.
+func Messages(messages []types.ChatMessage, support llm.ContentSupport) ([]openai.ChatCompletionMessage, error) {
+	apiMessages := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		apiMessages[i] = openai.ChatCompletionMessage{
+			Role:       string(msg.Role),
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		if len(msg.Parts) > 0 {
+			parts, err := llm.ResolveParts(msg, support)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			// Content and MultiContent are mutually exclusive
+			apiMessages[i].Content = ""
+			apiMessages[i].MultiContent = contentParts(parts)
+		}
+		for _, call := range msg.ToolCalls {
+			args, err := json.Marshal(call.Args)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal arguments for tool call %q: %w", call.Name, err)
+			}
+			apiMessages[i].ToolCalls = append(apiMessages[i].ToolCalls, openai.ToolCall{
+				ID:   call.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Name,
+					Arguments: string(args),
+				},
+			})
+		}
+	}
+	return apiMessages, nil
+}
+
+// contentParts converts resolved content parts; images are sent as URLs or data: URLs.
+func contentParts(parts []types.ContentPart) []openai.ChatMessagePart {
+	apiParts := make([]openai.ChatMessagePart, len(parts))
+	for i, part := range parts {
+		if part.Type == types.PartText {
+			apiParts[i] = openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: part.Text}
+			continue
+		}
+		apiParts[i] = openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: llm.DataURL(part), Detail: openai.ImageURLDetailAuto},
+		}
+	}
+	return apiParts
+}
+
+// Tools describes tools as OpenAI function definitions.
+func Tools(toolset []*tools.Tool) []openai.Tool {
+	if len(toolset) == 0 {
+		return nil
+	}
+	apiTools := make([]openai.Tool, len(toolset))
+	for i, tool := range toolset {
+		apiTools[i] = openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters(),
+			},
+		}
+	}
+	return apiTools
+}
+
+// Usage converts the token counts reported for a completion.
+func Usage(u openai.Usage) *types.Usage {
+	return &types.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+// ToolCalls decodes the JSON arguments of each returned tool call.
+func ToolCalls(calls []openai.ToolCall) ([]types.ToolCall, error) {
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	toolCalls := make([]types.ToolCall, len(calls))
+	for i, call := range calls {
+		args := map[string]any{}
+		if call.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments for tool call %q: %w", call.Function.Name, err)
+			}
+		}
+		toolCalls[i] = types.ToolCall{
+			ID:   call.ID,
+			Name: call.Function.Name,
+			Args: args,
+		}
+	}
+	return toolCalls, nil
+}
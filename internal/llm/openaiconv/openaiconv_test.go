@@ -0,0 +1,51 @@
+package openaiconv
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestMessagesParts(t *testing.T) {
+	messages := []types.ChatMessage{{
+		Role:    types.RoleUser,
+		Content: "Describe the screenshot.",
+		Parts: []types.ContentPart{
+			types.ImagePart([]byte("png"), "image/png"),
+			types.FilePart("notes.txt", []byte("see login bug"), "text/plain"),
+		},
+	}}
+	apiMessages, err := Messages(messages, llm.ContentSupport{Images: true, ImageURLs: true})
+	if err != nil {
+		t.Fatalf("Messages() error = %v", err)
+	}
+	body, _ := json.Marshal(apiMessages[0])
+	for _, want := range []string{`"type":"image_url"`, `"url":"data:image/png;base64,cG5n"`, `File notes.txt:\nsee login bug`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %s in %s", want, body)
+		}
+	}
+}
+
+func TestToolCallsRoundTrip(t *testing.T) {
+	calls := []types.ToolCall{{ID: "call_1", Name: "search", Args: map[string]any{"query": "gogurt"}}}
+	apiMessages, err := Messages([]types.ChatMessage{{Role: types.RoleAssistant, ToolCalls: calls}}, llm.ContentSupport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ToolCalls(apiMessages[0].ToolCalls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "call_1" || got[0].Name != "search" || got[0].Args["query"] != "gogurt" {
+		t.Errorf("unexpected tool calls %+v", got)
+	}
+	if _, err := ToolCalls([]openai.ToolCall{{Function: openai.FunctionCall{Name: "search", Arguments: "{"}}}); err == nil {
+		t.Error("expected an error for malformed arguments")
+	}
+}
@@ -0,0 +1,79 @@
+package llm
+
+import "gogurt/internal/config"
+
+// GenerateOptions holds per-call generation settings. Unset fields (nil or zero)
+// leave the provider's own default in place.
+type GenerateOptions struct {
+	// Model overrides the backend's configured model or deployment for this call.
+	Model       string
+	Temperature *float32
+	TopP        *float32
+	MaxTokens   int
+	Stop        []string
+	Seed        *int
+	// JSONSchema asks the provider to constrain output to JSON matching the schema,
+	// where it supports structured output. Callers should still validate the result.
+	JSONSchema     map[string]any
+	JSONSchemaName string
+}
+
+// Option mutates GenerateOptions for a single call.
+type Option func(*GenerateOptions)
+
+func WithModel(model string) Option {
+	return func(o *GenerateOptions) { o.Model = model }
+}
+
+func WithTemperature(temperature float32) Option {
+	return func(o *GenerateOptions) { o.Temperature = &temperature }
+}
+
+func WithTopP(topP float32) Option {
+	return func(o *GenerateOptions) { o.TopP = &topP }
+}
+
+func WithMaxTokens(maxTokens int) Option {
+	return func(o *GenerateOptions) { o.MaxTokens = maxTokens }
+}
+
+func WithStop(stop ...string) Option {
+	return func(o *GenerateOptions) { o.Stop = stop }
+}
+
+func WithSeed(seed int) Option {
+	return func(o *GenerateOptions) { o.Seed = &seed }
+}
+
+// WithJSONSchema requests structured output matching schema; name identifies it to providers that need one.
+func WithJSONSchema(name string, schema map[string]any) Option {
+	return func(o *GenerateOptions) {
+		o.JSONSchemaName = name
+		o.JSONSchema = schema
+	}
+}
+
+// DefaultOptions returns the generation defaults configured through config.Config.
+func DefaultOptions(cfg *config.Config) GenerateOptions {
+	if cfg == nil {
+		return GenerateOptions{}
+	}
+	return GenerateOptions{
+		Temperature: cfg.LLMTemperature,
+		TopP:        cfg.LLMTopP,
+		MaxTokens:   cfg.LLMMaxTokens,
+		Stop:        cfg.LLMStop,
+		Seed:        cfg.LLMSeed,
+	}
+}
+
+// ApplyOptions returns a copy of defaults with opts applied on top.
+func ApplyOptions(defaults GenerateOptions, opts ...Option) GenerateOptions {
+	o := defaults
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
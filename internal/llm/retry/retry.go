@@ -0,0 +1,320 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/logger"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+
+	"github.com/ollama/ollama/api"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrCircuitOpen is returned without calling the provider while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("llm circuit breaker is open")
+
+// Config controls retries and the circuit breaker.
+type Config struct {
+	// MaxRetries is the number of attempts made after the first one fails.
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry; it doubles on each attempt up to MaxDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps each wait, including delays asked for by Retry-After.
+	MaxDelay time.Duration
+	// BreakerThreshold is the number of consecutive failed calls that opens the breaker; 0 disables it.
+	BreakerThreshold int
+	// BreakerCooldown is how long the breaker stays open before letting a probe call through.
+	BreakerCooldown time.Duration
+}
+
+// RetryLLM wraps an llm.LLM, retrying transient failures with exponential backoff and
+// jitter and short-circuiting calls after repeated failures.
+type RetryLLM struct {
+	next    llm.LLM
+	cfg     Config
+	breaker *breaker
+}
+
+func New(next llm.LLM, cfg Config) *RetryLLM {
+	return &RetryLLM{
+		next:    next,
+		cfg:     cfg,
+		breaker: &breaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown},
+	}
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*breaker{}
+)
+
+// ForProvider is New with the circuit breaker shared by every RetryLLM for provider, so
+// that failures count across requests. The breaker takes its settings from the first cfg.
+func ForProvider(provider string, next llm.LLM, cfg Config) *RetryLLM {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	b, ok := breakers[provider]
+	if !ok {
+		b = &breaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown}
+		breakers[provider] = b
+	}
+	return &RetryLLM{next: next, cfg: cfg, breaker: b}
+}
+
+func (r *RetryLLM) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (*types.ChatMessage, error) {
+	var msg *types.ChatMessage
+	err := r.do(ctx, func(ctx context.Context) error {
+		var err error
+		msg, err = r.next.Generate(ctx, messages, opts...)
+		return err
+	})
+	return msg, err
+}
+
+func (r *RetryLLM) GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...llm.Option) (*types.ChatMessage, error) {
+	var msg *types.ChatMessage
+	err := r.do(ctx, func(ctx context.Context) error {
+		var err error
+		msg, err = r.next.GenerateWithTools(ctx, messages, toolset, opts...)
+		return err
+	})
+	return msg, err
+}
+
+// AGenerate provides an asynchronous Generate.
+func (r *RetryLLM) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan *types.ChatMessage, <-chan error) {
+	msgCh := make(chan *types.ChatMessage, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(msgCh)
+		defer close(errCh)
+		msg, err := r.Generate(ctx, messages, opts...)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		msgCh <- msg
+	}()
+	return msgCh, errCh
+}
+
+// Stream retries only while no token has been delivered, so callers never see a
+// partial response repeated.
+func (r *RetryLLM) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error, opts ...llm.Option) (*types.ChatMessage, error) {
+	var msg *types.ChatMessage
+	started := false
+	err := r.do(ctx, func(ctx context.Context) error {
+		var err error
+		msg, err = r.next.Stream(ctx, messages, func(token string) error {
+			started = true
+			return onToken(token)
+		}, opts...)
+		if err != nil && started {
+			return permanent{err}
+		}
+		return err
+	})
+	var p permanent
+	if errors.As(err, &p) {
+		err = p.err
+	}
+	return msg, err
+}
+
+// AStream provides an asynchronous streaming interface returning tokens.
+func (r *RetryLLM) AStream(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan string, <-chan error) {
+	tokenCh := make(chan string)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(tokenCh)
+		defer close(errCh)
+		_, err := r.Stream(ctx, messages, func(token string) error {
+			tokenCh <- token
+			return nil
+		}, opts...)
+		if err != nil {
+			errCh <- err
+		}
+	}()
+	return tokenCh, errCh
+}
+
+func (r *RetryLLM) HealthCheck(ctx context.Context) error {
+	return r.next.HealthCheck(ctx)
+}
+
+func (r *RetryLLM) Metadata() map[string]any {
+	md := make(map[string]any)
+	for k, v := range r.next.Metadata() {
+		md[k] = v
+	}
+	md["max_retries"] = r.cfg.MaxRetries
+	return md
+}
+
+// do runs call until it succeeds, fails with a non-retryable error or runs out of retries.
+func (r *RetryLLM) do(ctx context.Context, call func(ctx context.Context) error) error {
+	if err := r.breaker.allow(); err != nil {
+		return err
+	}
+	var err error
+	for attempt := 0; ; attempt++ {
+		hint := &retryAfterHint{}
+		err = call(context.WithValue(ctx, hintKey{}, hint))
+		if err == nil {
+			r.breaker.success()
+			return nil
+		}
+		if !Retryable(err) || ctx.Err() != nil || attempt >= r.cfg.MaxRetries {
+			break
+		}
+		delay := r.backoff(attempt)
+		if d, ok := retryAfter(err, hint); ok {
+			// honor the server's delay, but never wait longer than MaxDelay
+			delay = d
+			if r.cfg.MaxDelay > 0 {
+				delay = min(d, r.cfg.MaxDelay)
+			}
+		}
+		logger.WarnCtx(ctx, "LLM call failed (attempt %d/%d), retrying in %s: %v", attempt+1, r.cfg.MaxRetries+1, delay, err)
+		if err := sleep(ctx, delay); err != nil {
+			// cancelled while waiting; this says nothing about the provider either
+			r.breaker.release()
+			return err
+		}
+	}
+	if Retryable(err) {
+		r.breaker.failure()
+	} else {
+		r.breaker.release()
+	}
+	return err
+}
+
+// backoff returns the exponential delay for attempt with jitter in [delay/2, delay].
+func (r *RetryLLM) backoff(attempt int) time.Duration {
+	delay := r.cfg.BaseDelay << attempt
+	if delay <= 0 || (r.cfg.MaxDelay > 0 && delay > r.cfg.MaxDelay) {
+		delay = r.cfg.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// permanent marks an error that must not be retried regardless of its cause.
+type permanent struct{ err error }
+
+func (p permanent) Error() string { return p.err.Error() }
+
+// Retryable reports whether err is a transient failure worth retrying: rate limits,
+// server errors, timeouts and dropped connections. Cancellation, client errors and
+// an open breaker are fatal.
+func Retryable(err error) bool {
+	var p permanent
+	switch {
+	case err == nil, errors.As(err, &p), errors.Is(err, ErrCircuitOpen),
+		errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return retryableStatus(reqErr.HTTPStatusCode)
+	}
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+func retryableStatus(code int) bool {
+	return code == 408 || code == 429 || code >= 500
+}
+
+// breaker is a consecutive-failure circuit breaker. Once open it rejects calls until
+// the cooldown has passed, then lets a single probe through to decide whether to close.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *breaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	if now := time.Now(); now.Before(b.openUntil) || b.probing {
+		return fmt.Errorf("%w after %d consecutive failures", ErrCircuitOpen, b.failures)
+	}
+	b.probing = true
+	return nil
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// release ends a probe whose outcome says nothing about provider health (e.g. a bad request).
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		logger.Warn("LLM circuit breaker opened for %s after %d consecutive failures", b.cooldown, b.failures)
+	}
+}
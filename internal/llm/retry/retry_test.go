@@ -0,0 +1,169 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gogurt/internal/config"
+	"gogurt/internal/llm/ollama"
+	"gogurt/internal/llm/retry"
+	"gogurt/internal/types"
+)
+
+// fakeOllama serves /api/chat, answering the first failures requests with status.
+func fakeOllama(t *testing.T, failures int, status int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(calls.Add(1)) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error":"busy"}`))
+			return
+		}
+		w.Write([]byte(`{"model":"m","message":{"role":"assistant","content":"hello"},"done":true}` + "\n"))
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("OLLAMA_HOST", srv.URL)
+	return srv, &calls
+}
+
+func newRetryLLM(t *testing.T, cfg retry.Config) *retry.RetryLLM {
+	t.Helper()
+	model, err := ollama.New(&config.Config{OllamaModel: "m"})
+	if err != nil {
+		t.Fatalf("ollama.New: %v", err)
+	}
+	return retry.New(model, cfg)
+}
+
+var ping = []types.ChatMessage{{Role: types.RoleUser, Content: "hi"}}
+
+func TestRetriesTransientErrors(t *testing.T) {
+	_, calls := fakeOllama(t, 2, http.StatusTooManyRequests, "0")
+	// a base delay this long would time the test out unless Retry-After is honored
+	r := newRetryLLM(t, retry.Config{MaxRetries: 3, BaseDelay: time.Minute, MaxDelay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, err := r.Generate(ctx, ping)
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if msg.Content != "hello" {
+		t.Errorf("unexpected content %q", msg.Content)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 requests, got %d", got)
+	}
+}
+
+func TestRetryAfterIsCappedByMaxDelay(t *testing.T) {
+	_, calls := fakeOllama(t, 1, http.StatusServiceUnavailable, "3600")
+	r := newRetryLLM(t, retry.Config{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := r.Generate(ctx, ping); err != nil {
+		t.Fatalf("expected the hour-long Retry-After to be capped, got %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected 2 calls, got %d", got)
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	_, calls := fakeOllama(t, 1, http.StatusBadRequest, "")
+	r := newRetryLLM(t, retry.Config{MaxRetries: 3, BaseDelay: time.Millisecond})
+
+	if _, err := r.Generate(context.Background(), ping); err == nil {
+		t.Fatal("expected error for 400 response")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 request, got %d", got)
+	}
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	_, calls := fakeOllama(t, 100, http.StatusServiceUnavailable, "")
+	r := newRetryLLM(t, retry.Config{BreakerThreshold: 2, BreakerCooldown: time.Minute})
+
+	for range 2 {
+		if _, err := r.Generate(context.Background(), ping); err == nil || errors.Is(err, retry.ErrCircuitOpen) {
+			t.Fatalf("expected provider error, got %v", err)
+		}
+	}
+	if _, err := r.Generate(context.Background(), ping); !errors.Is(err, retry.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected the open breaker to skip the provider, got %d requests", got)
+	}
+}
+
+func TestCancelledProbeReleasesBreaker(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1, 2:
+			// the first call retries at once and then opens the breaker
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"busy"}`))
+		case 3:
+			// the probe fails and backs off
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"busy"}`))
+		default:
+			w.Write([]byte(`{"model":"m","message":{"role":"assistant","content":"hello"},"done":true}` + "\n"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("OLLAMA_HOST", srv.URL)
+	r := newRetryLLM(t, retry.Config{MaxRetries: 1, BaseDelay: time.Minute, MaxDelay: time.Minute, BreakerThreshold: 1, BreakerCooldown: time.Millisecond})
+
+	if _, err := r.Generate(context.Background(), ping); err == nil {
+		t.Fatal("expected the first call to fail")
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.Generate(ctx, ping); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the probe to be cancelled during backoff, got %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := r.Generate(context.Background(), ping); err != nil {
+		t.Fatalf("expected the next call to be let through, got %v", err)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("expected 4 requests, got %d", got)
+	}
+}
+
+func TestForProviderSharesBreaker(t *testing.T) {
+	_, calls := fakeOllama(t, 100, http.StatusServiceUnavailable, "")
+	model, err := ollama.New(&config.Config{OllamaModel: "m"})
+	if err != nil {
+		t.Fatalf("ollama.New: %v", err)
+	}
+	cfg := retry.Config{BreakerThreshold: 1, BreakerCooldown: time.Minute}
+
+	if _, err := retry.ForProvider("shared-breaker", model, cfg).Generate(context.Background(), ping); err == nil {
+		t.Fatal("expected provider error")
+	}
+	if _, err := retry.ForProvider("shared-breaker", model, cfg).Generate(context.Background(), ping); !errors.Is(err, retry.ErrCircuitOpen) {
+		t.Fatalf("expected the breaker opened by the first wrapper, got %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 request, got %d", got)
+	}
+}
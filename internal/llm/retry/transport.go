@@ -0,0 +1,86 @@
+package retry
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The provider SDKs drop response headers from their errors, so Retry-After is captured
+// by an HTTP transport and handed to the retry loop through the request context.
+
+type hintKey struct{}
+
+type retryAfterHint struct {
+	mu    sync.Mutex
+	delay time.Duration
+	ok    bool
+}
+
+func (h *retryAfterHint) set(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.delay, h.ok = d, true
+}
+
+func (h *retryAfterHint) get() (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.delay, h.ok
+}
+
+// retryAfter returns the server-requested delay, taken from the error itself when it
+// carries one (RetryAfter() time.Duration) or from the captured response header.
+func retryAfter(err error, hint *retryAfterHint) (time.Duration, bool) {
+	var ra interface{ RetryAfter() time.Duration }
+	if errors.As(err, &ra) {
+		return ra.RetryAfter(), true
+	}
+	return hint.get()
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport wraps base so Retry-After headers on responses are visible to RetryLLM.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+// HTTPClient returns an HTTP client for provider SDKs that reports Retry-After to RetryLLM.
+func HTTPClient() *http.Client {
+	return &http.Client{Transport: Transport(nil)}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if hint, ok := req.Context().Value(hintKey{}).(*retryAfterHint); ok {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			hint.set(d)
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter accepts both forms allowed by RFC 9110: delay-seconds and an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,151 @@
+package structured
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+	"gogurt/internal/utils"
+)
+
+// DefaultMaxAttempts is how many generations a Generator makes before giving up.
+const DefaultMaxAttempts = 3
+
+// ErrInvalidOutput is returned when the model never produced output matching the schema.
+var ErrInvalidOutput = errors.New("structured: model output did not match schema")
+
+// Generator asks an LLM for JSON matching the schema of T and decodes it. Output that
+// fails validation is sent back to the model with the problems found, up to maxAttempts.
+type Generator[T any] struct {
+	llm         llm.LLM
+	name        string
+	schema      map[string]any
+	maxAttempts int
+}
+
+// New returns a Generator whose schema is derived from T with tools.SchemaOf.
+func New[T any](model llm.LLM) *Generator[T] {
+	t := reflect.TypeFor[T]()
+	name := t.Name()
+	if name == "" {
+		name = "response"
+	}
+	return &Generator[T]{
+		llm:         model,
+		name:        name,
+		schema:      tools.SchemaOf(t),
+		maxAttempts: DefaultMaxAttempts,
+	}
+}
+
+// WithSchema replaces the derived schema, e.g. to add enums or descriptions.
+func (g *Generator[T]) WithSchema(name string, schema map[string]any) *Generator[T] {
+	g.name = name
+	g.schema = schema
+	return g
+}
+
+// WithMaxAttempts sets how many generations are made in total; values below 1 mean one.
+func (g *Generator[T]) WithMaxAttempts(n int) *Generator[T] {
+	g.maxAttempts = max(n, 1)
+	return g
+}
+
+// Schema returns the JSON Schema output is validated against.
+func (g *Generator[T]) Schema() map[string]any {
+	return g.schema
+}
+
+// Generate prompts the model and returns its output decoded into T.
+func (g *Generator[T]) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (T, error) {
+	return g.Continue(ctx, messages, nil, opts...)
+}
+
+// Continue is like Generate but starts from resp, a reply the caller already has for
+// messages; it is validated first and only repaired if needed.
+func (g *Generator[T]) Continue(ctx context.Context, messages []types.ChatMessage, resp *types.ChatMessage, opts ...llm.Option) (T, error) {
+	var zero T
+	opts = append([]llm.Option{llm.WithJSONSchema(g.name, g.schema)}, opts...)
+	history := append([]types.ChatMessage(nil), messages...)
+
+	var problems []string
+	for attempt := 0; attempt < g.maxAttempts; attempt++ {
+		if resp == nil {
+			var err error
+			resp, err = g.llm.Generate(ctx, history, opts...)
+			if err != nil {
+				return zero, err
+			}
+		}
+		var value T
+		value, problems = g.Parse(resp.Content)
+		if len(problems) == 0 {
+			return value, nil
+		}
+		history = append(history,
+			types.ChatMessage{Role: types.RoleAssistant, Content: resp.Content},
+			types.ChatMessage{Role: types.RoleUser, Content: g.repairPrompt(problems)},
+		)
+		resp = nil
+	}
+	return zero, fmt.Errorf("%w after %d attempts:\n%s", ErrInvalidOutput, g.maxAttempts, tools.FormatProblems(problems))
+}
+
+// AGenerate is an async version of Generate.
+func (g *Generator[T]) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan T, <-chan error) {
+	out := make(chan T, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		value, err := g.Generate(ctx, messages, opts...)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		out <- value
+	}()
+	return out, errCh
+}
+
+// Parse extracts JSON from content, validates it and decodes it into T. Models without a
+// structured mode often wrap JSON in prose or code fences, so the first JSON value of the
+// schema's type is used.
+func (g *Generator[T]) Parse(content string) (T, []string) {
+	var value T
+	raw := strings.TrimSpace(content)
+	if !json.Valid([]byte(raw)) {
+		open := byte('{')
+		if g.schema["type"] == "array" {
+			open = '['
+		}
+		raw = utils.ExtractJSON(content, open)
+		if raw == "" {
+			return value, []string{"no JSON found in response"}
+		}
+	}
+	if problems := tools.ValidateJSON(g.schema, []byte(raw)); len(problems) > 0 {
+		return value, problems
+	}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return value, []string{fmt.Sprintf("cannot decode response: %v", err)}
+	}
+	return value, nil
+}
+
+func (g *Generator[T]) repairPrompt(problems []string) string {
+	schema, _ := json.Marshal(g.schema)
+	return fmt.Sprintf("Your previous response was not valid:\n%s\n\nReply with only JSON matching this schema:\n%s",
+		tools.FormatProblems(problems), schema)
+}
+
+// ToolArgs asks the model for arguments to tool, validated against the tool's parameter schema.
+func ToolArgs(ctx context.Context, model llm.LLM, tool *tools.Tool, messages []types.ChatMessage, opts ...llm.Option) (map[string]any, error) {
+	return New[map[string]any](model).WithSchema(tool.Name, tool.Parameters()).Generate(ctx, messages, opts...)
+}
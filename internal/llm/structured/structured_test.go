@@ -0,0 +1,73 @@
+package structured_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gogurt/internal/llm/fake"
+	"gogurt/internal/llm/structured"
+	"gogurt/internal/types"
+)
+
+type person struct {
+	Name string   `json:"name"`
+	Age  int      `json:"age"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+var prompt = []types.ChatMessage{{Role: types.RoleUser, Content: "Extract the person."}}
+
+func TestGenerateDecodesValidOutput(t *testing.T) {
+	model := fake.Reply("Sure:\n```json\n{\"name\": \"Ada\", \"age\": 36}\n```")
+	got, err := structured.New[person](model).Generate(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Name != "Ada" || got.Age != 36 {
+		t.Errorf("unexpected value %+v", got)
+	}
+	if opts := model.LastRequest().Options; opts.JSONSchemaName != "person" || opts.JSONSchema["type"] != "object" {
+		t.Errorf("expected schema to be requested, got %+v", opts)
+	}
+}
+
+func TestGenerateRepairsInvalidOutput(t *testing.T) {
+	model := fake.Reply(`{"name": "Ada", "age": "thirty-six"}`, `{"name": "Ada", "age": 36}`)
+	got, err := structured.New[person](model).Generate(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Age != 36 || model.Calls() != 2 {
+		t.Errorf("expected repaired value after 2 calls, got %+v after %d", got, model.Calls())
+	}
+	msgs := model.LastRequest().Messages
+	repair := msgs[len(msgs)-1].Content
+	if !strings.Contains(repair, "$.age: expected integer, got string") {
+		t.Errorf("repair prompt should list the problem, got %q", repair)
+	}
+}
+
+func TestGenerateGivesUp(t *testing.T) {
+	model := fake.Reply(`{"name": "Ada"}`, `{"name": "Ada"}`)
+	_, err := structured.New[person](model).WithMaxAttempts(2).Generate(context.Background(), prompt)
+	if !errors.Is(err, structured.ErrInvalidOutput) {
+		t.Fatalf("expected ErrInvalidOutput, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `missing required property "age"`) {
+		t.Errorf("error should carry the last problems, got %v", err)
+	}
+}
+
+func TestContinueUsesExistingResponse(t *testing.T) {
+	model := fake.Reply()
+	resp := &types.ChatMessage{Role: types.RoleAssistant, Content: `[{"name": "Ada", "age": 36}]`}
+	got, err := structured.New[[]person](model).Continue(context.Background(), prompt, resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || model.Calls() != 0 {
+		t.Errorf("expected no extra calls, got %+v after %d", got, model.Calls())
+	}
+}
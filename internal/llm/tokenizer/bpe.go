@@ -0,0 +1,115 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BPE is an exact byte-pair encoder driven by a tiktoken rank file, the format of
+// cl100k_base.tiktoken and o200k_base.tiktoken. Special tokens are not handled.
+type BPE struct {
+	ranks map[string]int
+}
+
+// NewBPE returns a BPE over ranks, mapping token bytes to merge priority.
+func NewBPE(ranks map[string]int) *BPE {
+	return &BPE{ranks: ranks}
+}
+
+// LoadBPE reads a tiktoken rank file: one "<base64 token> <rank>" pair per line.
+func LoadBPE(path string) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected \"<token> <rank>\"", path, line)
+		}
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		r, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ranks[string(b)] = r
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewBPE(ranks), nil
+}
+
+// LoadDir registers every <encoding>.tiktoken file in dir, e.g. a tiktoken cache.
+func LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tiktoken"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		bpe, err := LoadBPE(path)
+		if err != nil {
+			return err
+		}
+		Register(strings.TrimSuffix(filepath.Base(path), ".tiktoken"), bpe)
+	}
+	return nil
+}
+
+func (b *BPE) Count(text string) int {
+	n := 0
+	for _, piece := range pretokenize.FindAllString(text, -1) {
+		n += b.countPiece(piece)
+	}
+	return n
+}
+
+func (b *BPE) Truncate(text string, max int) string {
+	return truncatePieces(text, max, b.countPiece)
+}
+
+func (b *BPE) countPiece(piece string) int {
+	if _, ok := b.ranks[piece]; ok {
+		return 1
+	}
+	return len(b.encode([]byte(piece)))
+}
+
+// encode merges the lowest-ranked adjacent pair until no pair is in the vocabulary.
+func (b *BPE) encode(piece []byte) []string {
+	parts := make([]string, len(piece))
+	for i := range piece {
+		parts[i] = string(piece[i : i+1])
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := b.ranks[parts[i]+parts[i+1]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best == -1 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return parts
+}
@@ -0,0 +1,196 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+)
+
+// Tokenizer counts model tokens. Counts from approximate tokenizers are estimates, so
+// callers should keep some headroom below a model's context window.
+type Tokenizer interface {
+	Count(text string) int
+	// Truncate returns the longest prefix of text that fits in max tokens.
+	Truncate(text string, max int) string
+}
+
+// pretokenize splits text the way tiktoken's cl100k/o200k patterns do before BPE:
+// contractions, letter runs with one leading non-letter, up to three digits, punctuation
+// runs and whitespace. Go's regexp has no lookahead, so trailing whitespace is not split
+// from the following word; counts differ from tiktoken by at most a token per gap.
+var pretokenize = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\pL\pN]?\pL+|\pN{1,3}| ?[^\s\pL\pN]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// Approx estimates tokens from the character count. It suits models whose vocabulary
+// is unknown, such as local Ollama models.
+type Approx struct {
+	// CharsPerToken defaults to 4, a common average for English text.
+	CharsPerToken float64
+}
+
+func (a Approx) ratio() float64 {
+	if a.CharsPerToken <= 0 {
+		return 4
+	}
+	return a.CharsPerToken
+}
+
+func (a Approx) Count(text string) int {
+	return int(math.Ceil(float64(utf8.RuneCountInString(text)) / a.ratio()))
+}
+
+func (a Approx) Truncate(text string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	limit := int(float64(max) * a.ratio())
+	for i := range text {
+		if limit == 0 {
+			return text[:i]
+		}
+		limit--
+	}
+	return text
+}
+
+// Estimate approximates OpenAI's BPE encodings without their vocabulary: text is split
+// with the tiktoken pre-tokenizer and each piece is charged by length. Common words come
+// out as one token, long or unusual words as several.
+type Estimate struct{}
+
+func (Estimate) Count(text string) int {
+	n := 0
+	for _, piece := range pretokenize.FindAllString(text, -1) {
+		n += estimatePiece(piece)
+	}
+	return n
+}
+
+func (Estimate) Truncate(text string, max int) string {
+	return truncatePieces(text, max, estimatePiece)
+}
+
+func estimatePiece(piece string) int {
+	n := len(strings.TrimLeft(piece, " "))
+	if n == 0 {
+		return 1
+	}
+	return 1 + (n-1)/6
+}
+
+// truncatePieces keeps whole pre-tokenized pieces while they fit.
+func truncatePieces(text string, max int, cost func(string) int) string {
+	used, end := 0, 0
+	for _, loc := range pretokenize.FindAllStringIndex(text, -1) {
+		used += cost(text[loc[0]:loc[1]])
+		if used > max {
+			break
+		}
+		end = loc[1]
+	}
+	return text[:end]
+}
+
+var (
+	mu        sync.RWMutex
+	encodings = map[string]Tokenizer{}
+)
+
+// Register makes tok available for models using the named encoding (e.g. "o200k_base").
+func Register(encoding string, tok Tokenizer) {
+	mu.Lock()
+	defer mu.Unlock()
+	encodings[encoding] = tok
+}
+
+// modelEncodings maps OpenAI model prefixes to tiktoken encodings; the longest prefix wins.
+var modelEncodings = map[string]string{
+	"gpt-4o":             "o200k_base",
+	"gpt-4.1":            "o200k_base",
+	"gpt-4.5":            "o200k_base",
+	"gpt-5":              "o200k_base",
+	"o1":                 "o200k_base",
+	"o3":                 "o200k_base",
+	"o4":                 "o200k_base",
+	"gpt-4":              "cl100k_base",
+	"gpt-3.5-turbo":      "cl100k_base",
+	"text-embedding-3":   "cl100k_base",
+	"text-embedding-ada": "cl100k_base",
+}
+
+// EncodingForModel returns the tiktoken encoding of an OpenAI model, or "" for other models.
+func EncodingForModel(model string) string {
+	encoding, matched := "", ""
+	for prefix, e := range modelEncodings {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			matched, encoding = prefix, e
+		}
+	}
+	return encoding
+}
+
+// ForModel returns the best available tokenizer for model: a registered BPE encoding for
+// OpenAI models, Estimate when the vocabulary is not loaded, and Approx otherwise.
+func ForModel(model string) Tokenizer {
+	encoding := EncodingForModel(model)
+	if encoding == "" {
+		return Approx{}
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if tok, ok := encodings[encoding]; ok {
+		return tok
+	}
+	return Estimate{}
+}
+
+// CountMessages counts the tokens a chat request spends on messages, including the
+// per-message framing OpenAI documents for its chat format.
+func CountMessages(tok Tokenizer, messages []types.ChatMessage) int {
+	n := 3 // every reply is primed with an assistant header
+	for _, msg := range messages {
+		n += CountMessage(tok, msg)
+	}
+	return n
+}
+
+// CountMessage counts one message including its framing.
+func CountMessage(tok Tokenizer, msg types.ChatMessage) int {
+	n := 4 + tok.Count(msg.Content)
+	if msg.Name != "" {
+		n += tok.Count(msg.Name)
+	}
+	for _, call := range msg.ToolCalls {
+		args, _ := json.Marshal(call.Args)
+		n += tok.Count(call.Name) + tok.Count(string(args))
+	}
+	for _, part := range msg.Parts {
+		switch part.Type {
+		case types.PartImage:
+			n += ImageTokens
+		case types.PartFile:
+			n += tok.Count(part.Name) + tok.Count(string(part.Data))
+		default:
+			n += tok.Count(part.Text)
+		}
+	}
+	return n
+}
+
+// ImageTokens is charged per image part: OpenAI's cost of a 1024x1024 image at high detail.
+const ImageTokens = 765
+
+// CountTools counts the tokens spent on tool definitions offered to the model.
+func CountTools(tok Tokenizer, toolset []*tools.Tool) int {
+	n := 0
+	for _, tool := range toolset {
+		params, _ := json.Marshal(tool.Parameters())
+		n += tok.Count(tool.Name) + tok.Count(tool.Description) + tok.Count(string(params))
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package tokenizer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApprox(t *testing.T) {
+	tok := Approx{}
+	if n := tok.Count("abcdefgh"); n != 2 {
+		t.Errorf("Count = %d, want 2", n)
+	}
+	if got := tok.Truncate("abcdefghij", 2); got != "abcdefgh" {
+		t.Errorf("Truncate = %q", got)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	tok := Estimate{}
+	// "Hello", ",", " world", "!" are one token each in OpenAI encodings
+	if n := tok.Count("Hello, world!"); n != 4 {
+		t.Errorf("Count = %d, want 4", n)
+	}
+	if got := tok.Truncate("Hello, world!", 3); got != "Hello, world" {
+		t.Errorf("Truncate = %q", got)
+	}
+}
+
+func TestBPE(t *testing.T) {
+	// tiktoken format: base64 token, rank
+	ranks := "aA== 0\naQ== 1\naGk= 2\nIA== 3\nIGhp 4\nIGg= 5\n"
+	path := filepath.Join(t.TempDir(), "tiny.tiktoken")
+	if err := os.WriteFile(path, []byte(ranks), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDir(filepath.Dir(path)); err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	mu.RLock()
+	tok := encodings["tiny"]
+	mu.RUnlock()
+	if tok == nil {
+		t.Fatal("encoding not registered")
+	}
+	// "hi" and " hi" merge to one token each; " ih" has no merges and stays three bytes
+	if n := tok.Count("hi hi ih"); n != 5 {
+		t.Errorf("Count = %d, want 5", n)
+	}
+}
+
+func TestForModel(t *testing.T) {
+	if EncodingForModel("gpt-4o-mini") != "o200k_base" || EncodingForModel("gpt-4-0613") != "cl100k_base" {
+		t.Error("unexpected encodings for OpenAI models")
+	}
+	if _, ok := ForModel("llama3.2:3b").(Approx); !ok {
+		t.Error("expected Approx for an Ollama model")
+	}
+	if _, ok := ForModel("gpt-4o").(Estimate); !ok {
+		t.Error("expected Estimate for an OpenAI model without a loaded encoding")
+	}
+}
@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"gogurt/internal/types"
+)
+
+// ModelPrice is the list price of a model in USD per million tokens.
+type ModelPrice struct {
+	PromptPerMillion     float64
+	CompletionPerMillion float64
+}
+
+// ModelPrices is used to estimate cost. Models are matched by longest prefix so dated
+// snapshots (e.g. "gpt-4o-2024-08-06") share their family's price. Unknown models,
+// including local Ollama models, are treated as free.
+var ModelPrices = map[string]ModelPrice{
+	"gpt-4o":        {PromptPerMillion: 2.50, CompletionPerMillion: 10.00},
+	"gpt-4o-mini":   {PromptPerMillion: 0.15, CompletionPerMillion: 0.60},
+	"gpt-4.1":       {PromptPerMillion: 2.00, CompletionPerMillion: 8.00},
+	"gpt-4.1-mini":  {PromptPerMillion: 0.40, CompletionPerMillion: 1.60},
+	"gpt-4.1-nano":  {PromptPerMillion: 0.10, CompletionPerMillion: 0.40},
+	"gpt-4-turbo":   {PromptPerMillion: 10.00, CompletionPerMillion: 30.00},
+	"gpt-3.5-turbo": {PromptPerMillion: 0.50, CompletionPerMillion: 1.50},
+	"o3-mini":       {PromptPerMillion: 1.10, CompletionPerMillion: 4.40},
+}
+
+// EstimateCost returns the estimated USD cost of usage on model.
+func EstimateCost(model string, usage types.Usage) float64 {
+	var price ModelPrice
+	matched := ""
+	for prefix, p := range ModelPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			matched, price = prefix, p
+		}
+	}
+	return float64(usage.PromptTokens)*price.PromptPerMillion/1e6 +
+		float64(usage.CompletionTokens)*price.CompletionPerMillion/1e6
+}
+
+// UsageSummary is a snapshot of accumulated usage, suitable for JSON responses.
+type UsageSummary struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+}
+
+// UsageTracker accumulates token usage and estimated cost across LLM calls.
+// It is safe for concurrent use.
+type UsageTracker struct {
+	mu      sync.Mutex
+	summary UsageSummary
+}
+
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{}
+}
+
+// Add records one call's usage on model.
+func (t *UsageTracker) Add(model string, usage types.Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.summary.Calls++
+	t.summary.PromptTokens += usage.PromptTokens
+	t.summary.CompletionTokens += usage.CompletionTokens
+	t.summary.TotalTokens += usage.PromptTokens + usage.CompletionTokens
+	t.summary.EstimatedCostUSD += EstimateCost(model, usage)
+}
+
+// Summary returns the usage accumulated so far.
+func (t *UsageTracker) Summary() UsageSummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.summary
+}
+
+type usageKey struct{}
+
+// WithUsageTracker returns a context whose LLM calls are recorded on t, in addition
+// to any trackers already attached to ctx. Nesting lets a pipe total its own calls
+// while the caller totals the whole request.
+func WithUsageTracker(ctx context.Context, t *UsageTracker) context.Context {
+	parent := usageTrackers(ctx)
+	trackers := make([]*UsageTracker, len(parent), len(parent)+1)
+	copy(trackers, parent)
+	return context.WithValue(ctx, usageKey{}, append(trackers, t))
+}
+
+func usageTrackers(ctx context.Context) []*UsageTracker {
+	if ctx == nil {
+		return nil
+	}
+	trackers, _ := ctx.Value(usageKey{}).([]*UsageTracker)
+	return trackers
+}
+
+var (
+	globalUsage  = NewUsageTracker()
+	labeledMu    sync.Mutex
+	labeledUsage = map[string]*UsageTracker{}
+)
+
+// GlobalUsage returns the process-wide usage tracker.
+func GlobalUsage() *UsageTracker {
+	return globalUsage
+}
+
+// LabeledUsage returns the process-wide tracker for label (e.g. a pipe name), creating it if needed.
+func LabeledUsage(label string) *UsageTracker {
+	labeledMu.Lock()
+	defer labeledMu.Unlock()
+	t, ok := labeledUsage[label]
+	if !ok {
+		t = NewUsageTracker()
+		labeledUsage[label] = t
+	}
+	return t
+}
+
+// UsageByLabel returns a snapshot of every labeled tracker.
+func UsageByLabel() map[string]UsageSummary {
+	labeledMu.Lock()
+	defer labeledMu.Unlock()
+	out := make(map[string]UsageSummary, len(labeledUsage))
+	for label, t := range labeledUsage {
+		out[label] = t.Summary()
+	}
+	return out
+}
+
+// RecordUsage adds a completed call's usage to the global tracker and every tracker
+// attached to ctx. Backends call it once per provider request.
+func RecordUsage(ctx context.Context, model string, usage *types.Usage) {
+	if usage == nil {
+		return
+	}
+	globalUsage.Add(model, *usage)
+	for _, t := range usageTrackers(ctx) {
+		t.Add(model, *usage)
+	}
+}
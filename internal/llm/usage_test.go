@@ -0,0 +1,39 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"gogurt/internal/types"
+)
+
+func TestRecordUsageNestedTrackers(t *testing.T) {
+	outer := NewUsageTracker()
+	inner := NewUsageTracker()
+	ctx := WithUsageTracker(context.Background(), outer)
+	innerCtx := WithUsageTracker(ctx, inner)
+
+	RecordUsage(innerCtx, "gpt-4o-2024-08-06", &types.Usage{PromptTokens: 1000, CompletionTokens: 500})
+	RecordUsage(ctx, "llama3", &types.Usage{PromptTokens: 10, CompletionTokens: 5})
+	RecordUsage(ctx, "gpt-4o", nil)
+
+	got := outer.Summary()
+	if got.Calls != 2 || got.PromptTokens != 1010 || got.CompletionTokens != 505 || got.TotalTokens != 1515 {
+		t.Errorf("unexpected outer summary: %+v", got)
+	}
+	if inner.Summary().Calls != 1 {
+		t.Errorf("expected inner tracker to see 1 call, got %d", inner.Summary().Calls)
+	}
+	// 1000 * 2.50/1M + 500 * 10/1M, llama3 is free
+	if want := 0.0075; math.Abs(got.EstimatedCostUSD-want) > 1e-9 {
+		t.Errorf("expected cost %v, got %v", want, got.EstimatedCostUSD)
+	}
+}
+
+func TestEstimateCostLongestPrefix(t *testing.T) {
+	usage := types.Usage{PromptTokens: 1_000_000}
+	if got := EstimateCost("gpt-4o-mini", usage); got != 0.15 {
+		t.Errorf("expected gpt-4o-mini price, got %v", got)
+	}
+}
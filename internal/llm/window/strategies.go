@@ -0,0 +1,191 @@
+package window
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/llm/tokenizer"
+	"gogurt/internal/types"
+)
+
+// ErrContextOverflow is returned when messages still exceed the budget after trimming.
+var ErrContextOverflow = errors.New("messages exceed the model's context window")
+
+// Strategy shortens messages towards budget tokens. It may return messages still over
+// budget; Fit then tries the next strategy.
+type Strategy func(ctx context.Context, messages []types.ChatMessage, budget int, tok tokenizer.Tokenizer) ([]types.ChatMessage, error)
+
+// Fit applies strategies in order until messages fit in budget tokens.
+func Fit(ctx context.Context, messages []types.ChatMessage, budget int, tok tokenizer.Tokenizer, strategies ...Strategy) ([]types.ChatMessage, error) {
+	for _, strategy := range strategies {
+		if tokenizer.CountMessages(tok, messages) <= budget {
+			return messages, nil
+		}
+		var err error
+		if messages, err = strategy(ctx, messages, budget, tok); err != nil {
+			return nil, err
+		}
+	}
+	if n := tokenizer.CountMessages(tok, messages); n > budget {
+		return nil, fmt.Errorf("%w: %d tokens, budget %d", ErrContextOverflow, n, budget)
+	}
+	return messages, nil
+}
+
+// DropOldest removes the oldest messages after the leading system messages, never the
+// last one. Tool results are dropped together with the call that requested them.
+func DropOldest() Strategy {
+	return func(ctx context.Context, messages []types.ChatMessage, budget int, tok tokenizer.Tokenizer) ([]types.ChatMessage, error) {
+		head := leadingSystem(messages)
+		rest := append([]types.ChatMessage(nil), messages[head:]...)
+		total := tokenizer.CountMessages(tok, messages)
+		for total > budget && len(rest) > 1 {
+			total -= tokenizer.CountMessage(tok, rest[0])
+			rest = rest[1:]
+			for len(rest) > 1 && rest[0].Role == types.RoleTool {
+				total -= tokenizer.CountMessage(tok, rest[0])
+				rest = rest[1:]
+			}
+		}
+		return append(append([]types.ChatMessage(nil), messages[:head]...), rest...), nil
+	}
+}
+
+// TruncateLongest cuts the middle out of the longest message, keeping its start and end
+// so that a question following retrieved documents survives. Messages with content parts
+// are left to the next strategy, since only their Content could be cut.
+func TruncateLongest() Strategy {
+	return func(ctx context.Context, messages []types.ChatMessage, budget int, tok tokenizer.Tokenizer) ([]types.ChatMessage, error) {
+		longest, longestCount := -1, 0
+		for i, msg := range messages {
+			if len(msg.Parts) > 0 {
+				continue
+			}
+			if n := tok.Count(msg.Content); n > longestCount {
+				longest, longestCount = i, n
+			}
+		}
+		if longest == -1 {
+			return messages, nil
+		}
+		excess := tokenizer.CountMessages(tok, messages) - budget
+		out := append([]types.ChatMessage(nil), messages...)
+		out[longest].Content = truncateMiddle(out[longest].Content, max(longestCount-excess, 0), tok)
+		return out, nil
+	}
+}
+
+const truncationMarker = "\n[... truncated ...]\n"
+
+func truncateMiddle(text string, limit int, tok tokenizer.Tokenizer) string {
+	limit -= tok.Count(truncationMarker)
+	if limit <= 0 {
+		return ""
+	}
+	tailLimit := limit / 4
+	head := tok.Truncate(text, limit-tailLimit)
+	// find the shortest suffix over the tail limit by binary search over start offsets
+	lo, hi := len(head), len(text)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if tok.Count(text[mid:]) <= tailLimit {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	for lo < len(text) && !isRuneStart(text[lo]) {
+		lo++
+	}
+	return head + truncationMarker + text[lo:]
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// SummarizeMiddle replaces the messages between the leading system messages and the
+// last keepLast messages with a summary written by model.
+func SummarizeMiddle(model llm.LLM, keepLast int) Strategy {
+	return func(ctx context.Context, messages []types.ChatMessage, budget int, tok tokenizer.Tokenizer) ([]types.ChatMessage, error) {
+		head := leadingSystem(messages)
+		tail := max(len(messages)-keepLast, head)
+		// keep tool results with the call that requested them
+		for tail > head && messages[tail].Role == types.RoleTool {
+			tail--
+		}
+		if tail-head < 2 {
+			return messages, nil
+		}
+
+		var transcript strings.Builder
+		for _, msg := range messages[head:tail] {
+			fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, msg.Content)
+		}
+		summary, err := model.Generate(ctx, []types.ChatMessage{
+			{Role: types.RoleSystem, Content: "Summarize the conversation below in a few sentences, keeping facts, decisions and open questions."},
+			{Role: types.RoleUser, Content: transcript.String()},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarize conversation: %w", err)
+		}
+
+		out := append([]types.ChatMessage(nil), messages[:head]...)
+		out = append(out, types.ChatMessage{Role: types.RoleSystem, Content: "Summary of the earlier conversation:\n" + summary.Content})
+		return append(out, messages[tail:]...), nil
+	}
+}
+
+func leadingSystem(messages []types.ChatMessage) int {
+	n := 0
+	for n < len(messages) && messages[n].Role == types.RoleSystem {
+		n++
+	}
+	return n
+}
+
+// TruncateDocuments keeps docs in order while they fit in budget tokens, truncating the
+// first one that does not and dropping the rest.
+func TruncateDocuments(docs []types.Document, budget int, tok tokenizer.Tokenizer) []types.Document {
+	var out []types.Document
+	for _, doc := range docs {
+		n := tok.Count(doc.PageContent)
+		if n <= budget {
+			out = append(out, doc)
+			budget -= n
+			continue
+		}
+		if budget >= minDocumentTokens {
+			doc.PageContent = tok.Truncate(doc.PageContent, budget)
+			out = append(out, doc)
+		}
+		break
+	}
+	return out
+}
+
+// minDocumentTokens is the smallest useful piece of a truncated document.
+const minDocumentTokens = 32
+
+// Strategies builds strategies from names as used by LLM_TRIM_STRATEGY:
+// "truncate", "drop_oldest", "summarize" and "none".
+func Strategies(names []string, model llm.LLM) ([]Strategy, error) {
+	var strategies []Strategy
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case "truncate":
+			strategies = append(strategies, TruncateLongest())
+		case "drop_oldest":
+			strategies = append(strategies, DropOldest())
+		case "summarize":
+			strategies = append(strategies, SummarizeMiddle(model, 2))
+		case "none", "":
+		default:
+			return nil, fmt.Errorf("unknown trim strategy %q", name)
+		}
+	}
+	return strategies, nil
+}
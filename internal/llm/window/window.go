@@ -0,0 +1,145 @@
+package window
+
+import (
+	"context"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/llm/tokenizer"
+	"gogurt/internal/logger"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+)
+
+// DefaultReserve is the number of tokens kept free for the completion when a call sets no MaxTokens.
+const DefaultReserve = 1024
+
+// Config controls how WindowLLM fits requests into the model's context window.
+type Config struct {
+	// ContextWindow overrides the window reported by the wrapped model's Metadata.
+	ContextWindow int
+	// Reserve is kept free for the completion; a call's MaxTokens takes precedence.
+	Reserve int
+	// Tokenizer defaults to tokenizer.ForModel for the wrapped model.
+	Tokenizer tokenizer.Tokenizer
+	// Strategies default to DropOldest then TruncateLongest.
+	Strategies []Strategy
+}
+
+// WindowLLM trims messages to fit the context window before every call to the wrapped LLM.
+type WindowLLM struct {
+	next       llm.LLM
+	window     int
+	reserve    int
+	tok        tokenizer.Tokenizer
+	strategies []Strategy
+}
+
+func New(next llm.LLM, cfg Config) *WindowLLM {
+	if cfg.ContextWindow <= 0 {
+		cfg.ContextWindow = llm.ContextWindowOf(next)
+	}
+	if cfg.Reserve <= 0 {
+		cfg.Reserve = DefaultReserve
+	}
+	if cfg.Tokenizer == nil {
+		cfg.Tokenizer = tokenizer.ForModel(llm.ModelName(next))
+	}
+	if cfg.Strategies == nil {
+		cfg.Strategies = []Strategy{DropOldest(), TruncateLongest()}
+	}
+	return &WindowLLM{
+		next:       next,
+		window:     cfg.ContextWindow,
+		reserve:    cfg.Reserve,
+		tok:        cfg.Tokenizer,
+		strategies: cfg.Strategies,
+	}
+}
+
+// fit trims messages to the budget left after the completion reserve and tool definitions.
+func (w *WindowLLM) fit(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts []llm.Option) ([]types.ChatMessage, error) {
+	reserve := w.reserve
+	if maxTokens := llm.ApplyOptions(llm.GenerateOptions{}, opts...).MaxTokens; maxTokens > 0 {
+		reserve = maxTokens
+	}
+	budget := w.window - reserve - tokenizer.CountTools(w.tok, toolset)
+	before := tokenizer.CountMessages(w.tok, messages)
+	if before <= budget {
+		return messages, nil
+	}
+	fitted, err := Fit(ctx, messages, budget, w.tok, w.strategies...)
+	if err != nil {
+		return nil, err
+	}
+	logger.WarnCtx(ctx, "Trimmed prompt from %d to %d tokens to fit a %d-token context window",
+		before, tokenizer.CountMessages(w.tok, fitted), w.window)
+	return fitted, nil
+}
+
+func (w *WindowLLM) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (*types.ChatMessage, error) {
+	messages, err := w.fit(ctx, messages, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	return w.next.Generate(ctx, messages, opts...)
+}
+
+func (w *WindowLLM) GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...llm.Option) (*types.ChatMessage, error) {
+	messages, err := w.fit(ctx, messages, toolset, opts)
+	if err != nil {
+		return nil, err
+	}
+	return w.next.GenerateWithTools(ctx, messages, toolset, opts...)
+}
+
+// AGenerate provides an asynchronous Generate.
+func (w *WindowLLM) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan *types.ChatMessage, <-chan error) {
+	msgCh := make(chan *types.ChatMessage, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(msgCh)
+		defer close(errCh)
+		msg, err := w.Generate(ctx, messages, opts...)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		msgCh <- msg
+	}()
+	return msgCh, errCh
+}
+
+func (w *WindowLLM) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error, opts ...llm.Option) (*types.ChatMessage, error) {
+	messages, err := w.fit(ctx, messages, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	return w.next.Stream(ctx, messages, onToken, opts...)
+}
+
+// AStream provides an asynchronous streaming interface returning tokens.
+func (w *WindowLLM) AStream(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan string, <-chan error) {
+	messages, err := w.fit(ctx, messages, nil, opts)
+	if err != nil {
+		tokenCh := make(chan string)
+		errCh := make(chan error, 1)
+		close(tokenCh)
+		errCh <- err
+		close(errCh)
+		return tokenCh, errCh
+	}
+	return w.next.AStream(ctx, messages, opts...)
+}
+
+func (w *WindowLLM) HealthCheck(ctx context.Context) error {
+	return w.next.HealthCheck(ctx)
+}
+
+func (w *WindowLLM) Metadata() map[string]any {
+	md := make(map[string]any)
+	for k, v := range w.next.Metadata() {
+		md[k] = v
+	}
+	md["context_window"] = w.window
+	return md
+}
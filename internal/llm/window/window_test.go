@@ -0,0 +1,118 @@
+package window_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/llm/fake"
+	"gogurt/internal/llm/tokenizer"
+	"gogurt/internal/llm/window"
+	"gogurt/internal/types"
+)
+
+// one token per 4 characters keeps the arithmetic easy to follow
+var tok = tokenizer.Approx{}
+
+func conversation() []types.ChatMessage {
+	return []types.ChatMessage{
+		{Role: types.RoleSystem, Content: "be brief"},
+		{Role: types.RoleUser, Content: strings.Repeat("old ", 40)},
+		{Role: types.RoleAssistant, Content: strings.Repeat("reply ", 40)},
+		{Role: types.RoleUser, Content: "latest question"},
+	}
+}
+
+func TestDropOldestKeepsSystemAndLast(t *testing.T) {
+	got, err := window.Fit(context.Background(), conversation(), 30, tok, window.DropOldest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].Role != types.RoleSystem || got[1].Content != "latest question" {
+		t.Errorf("unexpected messages %+v", got)
+	}
+}
+
+func TestTruncateLongestKeepsEnds(t *testing.T) {
+	content := "QUESTION-START " + strings.Repeat("document text ", 200) + " QUESTION-END"
+	msgs := []types.ChatMessage{{Role: types.RoleUser, Content: content}}
+	got, err := window.Fit(context.Background(), msgs, 100, tok, window.TruncateLongest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := got[0].Content
+	if !strings.HasPrefix(out, "QUESTION-START") || !strings.HasSuffix(out, "QUESTION-END") || !strings.Contains(out, "truncated") {
+		t.Errorf("expected start and end to survive, got %q", out)
+	}
+	if msgs[0].Content != content {
+		t.Error("input messages must not be modified")
+	}
+}
+
+func TestTruncateLongestSkipsMessagesWithParts(t *testing.T) {
+	msgs := []types.ChatMessage{
+		{Role: types.RoleUser, Content: "describe these", Parts: []types.ContentPart{types.TextPart(strings.Repeat("page text ", 200))}},
+		{Role: types.RoleUser, Content: "latest question"},
+	}
+	got, err := window.TruncateLongest()(context.Background(), msgs, 100, tok)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got[0].Content != "describe these" {
+		t.Errorf("expected the multimodal message to be left alone, got %q", got[0].Content)
+	}
+
+	got, err = window.Fit(context.Background(), msgs, 100, tok, window.TruncateLongest(), window.DropOldest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || len(got[0].Parts) != 0 {
+		t.Errorf("expected the multimodal message to be dropped, got %+v", got)
+	}
+}
+
+func TestSummarizeMiddle(t *testing.T) {
+	summarizer := fake.Reply("they talked")
+	got, err := window.Fit(context.Background(), conversation(), 60, tok, window.SummarizeMiddle(summarizer, 1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 3 || !strings.Contains(got[1].Content, "they talked") || got[2].Content != "latest question" {
+		t.Errorf("unexpected messages %+v", got)
+	}
+}
+
+func TestFitReportsOverflow(t *testing.T) {
+	_, err := window.Fit(context.Background(), conversation(), 5, tok, window.DropOldest())
+	if !errors.Is(err, window.ErrContextOverflow) {
+		t.Fatalf("expected ErrContextOverflow, got %v", err)
+	}
+}
+
+func TestWindowLLMTrimsBeforeGenerate(t *testing.T) {
+	model := fake.Reply("ok")
+	w := window.New(model, window.Config{ContextWindow: 80, Reserve: 20, Tokenizer: tok})
+	if _, err := w.Generate(context.Background(), conversation()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sent := model.LastRequest().Messages; tokenizer.CountMessages(tok, sent) > 60 {
+		t.Errorf("expected trimmed messages, got %d tokens", tokenizer.CountMessages(tok, sent))
+	}
+	if llm.ContextWindowOf(w) != 80 {
+		t.Errorf("expected context window in metadata, got %v", w.Metadata())
+	}
+}
+
+func TestTruncateDocuments(t *testing.T) {
+	docs := []types.Document{
+		{PageContent: strings.Repeat("a", 200)},
+		{PageContent: strings.Repeat("b", 400)},
+		{PageContent: "c"},
+	}
+	got := window.TruncateDocuments(docs, 100, tok)
+	if len(got) != 2 || len(got[0].PageContent) != 200 || tok.Count(got[1].PageContent) != 50 {
+		t.Errorf("unexpected documents %d", len(got))
+	}
+}
@@ -124,6 +124,10 @@ func LogWithContext(ctx context.Context, level Level, message string, fields map
 // logStructured constructs the log entry and writes it to the console and file.
 // Replace the log file writing section of logStructured
 func logStructured(l *Logger, level Level, message string, fields map[string]any, traceID string, caller string) {
+	if l == nil {
+		// no default logger configured (e.g. in tests): fall back to stdout
+		l = GetLogger()
+	}
 	if level >= ERROR {
 		log.Println(message)
 	}
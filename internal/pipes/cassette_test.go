@@ -0,0 +1,130 @@
+package pipes
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gogurt/internal/agent"
+	"gogurt/internal/cassette"
+	"gogurt/internal/config"
+	embollama "gogurt/internal/embeddings/ollama"
+	"gogurt/internal/llm"
+	"gogurt/internal/llm/ollama"
+	"gogurt/internal/state"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores/simple"
+)
+
+// These tests replay the synthetic_*.json files under testdata/cassettes. They are
+// synthetic fixtures in the cassette format, not recordings: the embeddings are made-up
+// 6-dimensional vectors and the chat replies are scripted, so they pin the pipes' request
+// flow but say nothing about what a real provider returns. Running with
+// `GOGURT_CASSETTE=record` against a live Ollama overwrites them with real traffic, whose
+// answers the assertions below may then need to follow.
+
+func openCassette(t *testing.T, name string) *cassette.Cassette {
+	t.Helper()
+	cas, err := cassette.New(filepath.Join("testdata", "cassettes", name+".json"), cassette.ModeFromEnv(), nil)
+	if err != nil {
+		t.Fatalf("open cassette: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := cas.Save(); err != nil {
+			t.Errorf("save cassette: %v", err)
+		}
+	})
+	return cas
+}
+
+func TestWorkflowPipeSyntheticReplay(t *testing.T) {
+	cas := openCassette(t, "synthetic_workflow_reverse_uppercase")
+	cfg := &config.Config{OllamaModel: "llama3.2:3b", PlannerSeed: 42}
+	model, err := ollama.NewWithHTTPClient(cfg, cas.Client())
+	if err != nil {
+		t.Fatalf("ollama: %v", err)
+	}
+
+	resultCh, errCh := newWorkflowPipe(cfg, model).Run(context.Background(), "Reverse the word 'gogurt' and then uppercase it.")
+	select {
+	case result := <-resultCh:
+		if result != "TRUGOG" {
+			t.Errorf("expected TRUGOG, got %q", result)
+		}
+	case err := <-errCh:
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// llmAgent answers each prompt with one completion from its LLM.
+type llmAgent struct {
+	llm   llm.LLM
+	state state.AgentState
+}
+
+func (a *llmAgent) Invoke(ctx context.Context, input any) (<-chan any, <-chan error) {
+	resultCh := make(chan any, 1)
+	errorCh := make(chan error, 1)
+	go func() {
+		defer close(resultCh)
+		defer close(errorCh)
+		msg, err := a.llm.Generate(ctx, []types.ChatMessage{{Role: types.RoleUser, Content: fmt.Sprint(input)}})
+		if err != nil {
+			errorCh <- err
+			return
+		}
+		resultCh <- msg.Content
+	}()
+	return resultCh, errorCh
+}
+
+func (a *llmAgent) OnMessage(ctx context.Context, msg *types.StateMessage) (<-chan *types.StateMessage, <-chan error) {
+	panic("not used")
+}
+func (a *llmAgent) State() *state.AgentState { return &a.state }
+func (a *llmAgent) Describe() *types.AgentDescription {
+	return &types.AgentDescription{Name: "llm"}
+}
+func (a *llmAgent) Init(ctx context.Context, config types.AgentConfig) error { return nil }
+
+var _ agent.Agent = (*llmAgent)(nil)
+
+func TestRAGPipeSyntheticReplay(t *testing.T) {
+	cas := openCassette(t, "synthetic_rag_retrieval")
+	cfg := &config.Config{OllamaModel: "llama3.2:3b", OllamaEmbedModel: "nomic-embed-text"}
+	embedder, err := embollama.NewWithHTTPClient(cfg, cas.Client())
+	if err != nil {
+		t.Fatalf("embedder: %v", err)
+	}
+	model, err := ollama.NewWithHTTPClient(cfg, cas.Client())
+	if err != nil {
+		t.Fatalf("ollama: %v", err)
+	}
+	store := simple.New(embedder)
+	docs := []types.Document{
+		{PageContent: "Gogurt is a Go framework for building LLM agents and pipes."},
+		{PageContent: "Bananas are rich in potassium."},
+		{PageContent: "The Eiffel Tower is in Paris."},
+		{PageContent: "Sourdough bread needs a starter culture."},
+	}
+	if err := <-store.AddDocuments(context.Background(), docs); err != nil {
+		t.Fatalf("AddDocuments: %v", err)
+	}
+
+	// the cassette answers only the chat request whose prompt holds the retrieved documents
+	pipe, err := newRAGPipe(&llmAgent{llm: model, state: state.NewMemoryState()}, store)
+	if err != nil {
+		t.Fatalf("newRAGPipe: %v", err)
+	}
+	resultCh, errCh := pipe.Run(context.Background(), "What is gogurt?")
+	select {
+	case answer := <-resultCh:
+		if !strings.Contains(answer, "Go framework") {
+			t.Errorf("expected the answer to come from the gogurt document, got %q", answer)
+		}
+	case err := <-errCh:
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
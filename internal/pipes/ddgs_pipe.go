@@ -7,6 +7,7 @@ import (
 	"gogurt/internal/agent"
 	"gogurt/internal/config"
 	"gogurt/internal/factories"
+	"gogurt/internal/llm"
 	"gogurt/internal/tools"
 	"gogurt/internal/tools/file_tools"
 	"gogurt/internal/tools/web"
@@ -21,7 +22,7 @@ type DDGSPipe struct {
 
 // NewDDGSPipe creates a new DDGSPipe.
 func NewDDGSPipe(ctx context.Context, cfg *config.Config) (*DDGSPipe, error) {
-	llm := factories.GetLLM(cfg)
+	model := factories.GetLLM(cfg)
 	registry := tools.NewRegistry()
 	errs := registry.RegisterBatch([]*tools.Tool{
 		file_tools.ReadFileTool,
@@ -36,7 +37,7 @@ func NewDDGSPipe(ctx context.Context, cfg *config.Config) (*DDGSPipe, error) {
 		}
 	}
 
-	planner := agent.NewPlannerAgent(llm)
+	planner := agent.NewPlannerAgent(model, registry.ListTools(), plannerOptions(cfg)...)
 	worker := agent.NewWorkerAgent(registry)
 
 	return &DDGSPipe{
@@ -63,6 +64,7 @@ func (p *DDGSPipe) Run(ctx context.Context, prompt string) (string, error) {
 func (p *DDGSPipe) ARun(ctx context.Context, prompt string) (<-chan string, <-chan error) {
 	resultCh := make(chan string, 1)
 	errorCh := make(chan error, 1)
+	ctx = llm.WithUsageTracker(ctx, llm.LabeledUsage("ddgs"))
 
 	go func() {
 		defer close(resultCh)
@@ -149,4 +151,4 @@ func (p *DDGSPipe) ARun(ctx context.Context, prompt string) (<-chan string, <-ch
 	}()
 
 	return resultCh, errorCh
-}
\ No newline at end of file
+}
@@ -2,11 +2,14 @@ package pipes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gogurt/internal/config"
 	"gogurt/internal/documentloaders"
 	"gogurt/internal/embeddings"
+	embcache "gogurt/internal/embeddings/cache"
 	"gogurt/internal/factories"
+	"gogurt/internal/llm"
 	"gogurt/internal/splitters"
 	"gogurt/internal/vectorstores"
 )
@@ -17,6 +20,9 @@ type IngestPipe struct {
 	splitter     splitters.Splitter
 	embedder     embeddings.Embedder
 	documentPath string
+	// vision transcribes scanned PDFs; nil when PDF_VISION_FALLBACK is off
+	vision     llm.LLM
+	visionOpts []llm.Option
 }
 
 // NewIngestPipe creates a new document ingestion pipeline.
@@ -28,12 +34,19 @@ func NewIngestPipe(ctx context.Context, cfg *config.Config, documentPath string)
 	embedder := factories.GetEmbedder(cfg)
 	vectorStore := factories.GetVectorStore(cfg, embedder)
 
-	return &IngestPipe{
+	pipe := &IngestPipe{
 		VectorStore:  vectorStore,
 		splitter:     splitter,
 		embedder:     embedder,
 		documentPath: documentPath,
-	}, nil
+	}
+	if cfg.PDFVisionFallback {
+		pipe.vision = factories.GetLLM(cfg)
+		if cfg.VisionModel != "" {
+			pipe.visionOpts = append(pipe.visionOpts, llm.WithModel(cfg.VisionModel))
+		}
+	}
+	return pipe, nil
 }
 
 // Run loads, splits, and embeds documents into the vector store asynchronously.
@@ -46,7 +59,7 @@ func (i *IngestPipe) Run(ctx context.Context) <-chan error {
 		c.Write("Starting document ingestion", "path", i.documentPath)
 
 		// 1. Load documents from the specified path.
-		docs, err := documentloaders.LoadDocuments(i.documentPath)
+		docs, err := documentloaders.LoadDocumentsWithVision(ctx, i.documentPath, i.vision, i.visionOpts...)
 		if err != nil {
 			errCh <- fmt.Errorf("failed to load documents from %s: %w", i.documentPath, err)
 			return
@@ -65,17 +78,26 @@ func (i *IngestPipe) Run(ctx context.Context) <-chan error {
 			return
 		}
 
-		// 3. Add the chunks to the vector store asynchronously.
-		addErrCh := i.VectorStore.AddDocuments(ctx, chunks)
+		// 3. Add the chunks to the vector store asynchronously, reporting embedding progress.
+		addErrCh := i.VectorStore.AddDocuments(embeddings.WithProgress(ctx, logProgress()), chunks)
 		select {
 		case err := <-addErrCh:
+			// Chunks that failed to embed are left out; the rest are stored, so a partial
+			// failure still completes the ingest. A complete failure does not.
+			var batchErr *embeddings.BatchError
+			if errors.As(err, &batchErr) {
+				if stored := vectorstores.Stored(chunks, err); len(stored) > 0 {
+					c.Warn("%d of %d chunks could not be embedded and were skipped: %v", len(chunks)-len(stored), len(chunks), err)
+					chunks, err = stored, nil
+				}
+			}
 			if err != nil {
 				errCh <- fmt.Errorf("failed to add documents to vector store: %w", err)
 				return
 			}
 			c.Write("Document ingestion completed successfully",
 				"documents_loaded", len(docs),
-				"chunks_created", len(chunks))
+				"chunks_stored", len(chunks))
 			errCh <- nil // Signal success
 		case <-ctx.Done():
 			errCh <- ctx.Err()
@@ -85,7 +107,28 @@ func (i *IngestPipe) Run(ctx context.Context) <-chan error {
 	return errCh
 }
 
+// logProgress returns an embedding progress callback that reports every 10%.
+func logProgress() func(embeddings.Progress) {
+	var reported int
+	return func(p embeddings.Progress) {
+		percent := (p.Done + p.Failed) * 100 / p.Total
+		if percent/10 > reported/10 || percent == 100 && reported < 100 {
+			reported = percent
+			c.Write("Embedding chunks", "done", p.Done, "failed", p.Failed, "total", p.Total)
+		}
+	}
+}
+
+// EmbeddingCacheStats reports the embedding cache's lookups, if caching is enabled.
+func (i *IngestPipe) EmbeddingCacheStats() (embcache.Stats, bool) {
+	cached, ok := i.embedder.(*embcache.CachedEmbedder)
+	if !ok {
+		return embcache.Stats{}, false
+	}
+	return cached.Stats(), true
+}
+
 // GetVectorStore returns the vector store instance.
 func (i *IngestPipe) GetVectorStore() vectorstores.VectorStore {
 	return i.VectorStore
-}
\ No newline at end of file
+}
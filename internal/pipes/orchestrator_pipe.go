@@ -50,4 +50,4 @@ func (p *OrchestratorPipe) Run(ctx context.Context, prompt string) (<-chan strin
 	}()
 
 	return outCh, errCh
-}
\ No newline at end of file
+}
@@ -3,7 +3,9 @@ package pipes
 import (
 	"context"
 
+	"gogurt/internal/config"
 	"gogurt/internal/console"
+	"gogurt/internal/llm"
 )
 
 // Pipe defines the interface for a non-blocking, asynchronous pipeline.
@@ -12,4 +14,12 @@ type Pipe interface {
 	Run(ctx context.Context, prompt string) (<-chan string, <-chan error)
 }
 
-var c = console.ConsoleInstance()
\ No newline at end of file
+var c = console.ConsoleInstance()
+
+// plannerOptions pins the planner's sampling so the same goal yields the same plan.
+func plannerOptions(cfg *config.Config) []llm.Option {
+	return []llm.Option{
+		llm.WithTemperature(cfg.PlannerTemperature),
+		llm.WithSeed(cfg.PlannerSeed),
+	}
+}
@@ -6,8 +6,12 @@ import (
 	"gogurt/internal/agent"
 	"gogurt/internal/config"
 	"gogurt/internal/factories"
+	"gogurt/internal/llm"
+	"gogurt/internal/llm/tokenizer"
+	"gogurt/internal/llm/window"
 	"gogurt/internal/prompts"
 	"gogurt/internal/prompts/rag"
+	"gogurt/internal/retrievers"
 	"gogurt/internal/types"
 	"gogurt/internal/vectorstores"
 	"strings"
@@ -17,6 +21,15 @@ type RAGPipe struct {
 	Agent       agent.Agent
 	prompt      *prompts.PromptTemplate
 	vectorStore vectorstores.VectorStore
+	// retriever finds the context for a query; it defaults to searching vectorStore
+	retriever retrievers.Retriever
+	// k is the number of documents retrieved per query
+	k int
+	// contextTokens caps the retrieved documents placed in the prompt
+	contextTokens int
+	tok           tokenizer.Tokenizer
+	// filter restricts retrieval by document metadata; nil searches everything
+	filter *vectorstores.Filter
 }
 
 // NewRAGPipe creates a new RAG query pipeline.
@@ -31,20 +44,49 @@ func NewRAGPipe(ctx context.Context, cfg *config.Config) (*RAGPipe, error) {
 
 	embedder := factories.GetEmbedder(cfg)
 	vectorStore := factories.GetVectorStore(cfg, embedder)
-	ragPrompt, err := prompts.NewPromptTemplate(rag.BasicRagPrompt)
+	pipe, err := newRAGPipe(aiAgent, vectorStore)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create prompt template: %w", err)
+		return nil, err
+	}
+	pipe.retriever = factories.GetRetriever(cfg, vectorStore, embedder)
+	if cfg.RetrieverK > 0 {
+		pipe.k = cfg.RetrieverK
 	}
+	model := factories.ModelName(cfg)
+	pipe.contextTokens = factories.ContextWindow(cfg) / 2
+	pipe.tok = tokenizer.ForModel(model)
 
 	c.Write("RAG query pipeline setup complete")
+	return pipe, nil
+}
 
+func newRAGPipe(aiAgent agent.Agent, vectorStore vectorstores.VectorStore) (*RAGPipe, error) {
+	ragPrompt, err := prompts.NewPromptTemplate(rag.BasicRagPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prompt template: %w", err)
+	}
 	return &RAGPipe{
-		Agent:       aiAgent,
-		prompt:      ragPrompt,
-		vectorStore: vectorStore,
+		Agent:         aiAgent,
+		prompt:        ragPrompt,
+		vectorStore:   vectorStore,
+		retriever:     retrievers.NewVector(vectorStore),
+		k:             3,
+		contextTokens: llm.DefaultContextWindow / 2,
+		tok:           tokenizer.Approx{},
 	}, nil
 }
 
+// SetFilter restricts the documents later queries retrieve, e.g. to language=go; nil
+// removes the restriction.
+func (r *RAGPipe) SetFilter(f *vectorstores.Filter) {
+	r.filter = f
+}
+
+// Filter returns the filter set with SetFilter.
+func (r *RAGPipe) Filter() *vectorstores.Filter {
+	return r.filter
+}
+
 // Run executes a RAG query asynchronously.
 func (r *RAGPipe) Run(ctx context.Context, query string) (<-chan string, <-chan error) {
 	resultCh := make(chan string, 1)
@@ -60,21 +102,33 @@ func (r *RAGPipe) Run(ctx context.Context, query string) (<-chan string, <-chan
 		}
 
 		// 1. Retrieve relevant documents asynchronously
-		docsCh, docsErrCh := r.vectorStore.SimilaritySearch(ctx, query, 3)
-		var relevantDocs []types.Document
+		docsCh, docsErrCh := r.retriever.Retrieve(ctx, query, r.k, vectorstores.WithFilter(r.filter))
+		var scored []vectorstores.ScoredDocument
 		select {
-		case relevantDocs = <-docsCh:
+		case docs, ok := <-docsCh:
+			if !ok {
+				errorCh <- fmt.Errorf("failed to retrieve documents: %w", <-docsErrCh)
+				return
+			}
+			scored = docs
 		case err := <-docsErrCh:
-			errorCh <- fmt.Errorf("failed to retrieve documents: %w", err)
-			return
+			if err != nil {
+				errorCh <- fmt.Errorf("failed to retrieve documents: %w", err)
+				return
+			}
+			scored = <-docsCh
 		case <-ctx.Done():
 			errorCh <- ctx.Err()
 			return
 		}
+		relevantDocs := make([]types.Document, len(scored))
+		for i, d := range scored {
+			relevantDocs[i] = d.Document
+		}
 
-		// 2. Build context from retrieved documents
+		// 2. Build context from retrieved documents, leaving room in the window for the answer
 		var contextBuilder strings.Builder
-		for i, doc := range relevantDocs {
+		for i, doc := range window.TruncateDocuments(relevantDocs, r.contextTokens, r.tok) {
 			if i > 0 {
 				contextBuilder.WriteString("\n---\n")
 			}
@@ -126,16 +180,21 @@ func (r *RAGPipe) HasDocuments(ctx context.Context) (<-chan bool, <-chan error)
 		defer close(resultCh)
 		defer close(errorCh)
 
-		docsCh, errCh := r.vectorStore.SimilaritySearch(ctx, "test", 1)
+		countCh, errCh := r.vectorStore.Count(ctx)
 		select {
-		case docs := <-docsCh:
-			resultCh <- len(docs) > 0
+		case n := <-countCh:
+			resultCh <- n > 0
 		case err := <-errCh:
-			errorCh <- err
+			if err != nil {
+				errorCh <- err
+				return
+			}
+			// errCh closes without an error once the count is sent
+			resultCh <- <-countCh > 0
 		case <-ctx.Done():
 			errorCh <- ctx.Err()
 		}
 	}()
 
 	return resultCh, errorCh
-}
\ No newline at end of file
+}
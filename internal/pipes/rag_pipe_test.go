@@ -0,0 +1,42 @@
+package pipes
+
+import (
+	"context"
+	"testing"
+
+	"gogurt/internal/embeddings/local"
+	"gogurt/internal/llm/fake"
+	"gogurt/internal/state"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores/simple"
+)
+
+func TestRAGPipeRunsOfflineWithLocalEmbeddings(t *testing.T) {
+	store := simple.New(local.New(0))
+	docs := []types.Document{
+		{PageContent: "Ollama serves local models over HTTP on port 11434."},
+		{PageContent: "Chroma is a vector database with a REST API."},
+		{PageContent: "The tokenizer estimates token counts for rate limiting."},
+		{PageContent: "Sourdough bread needs a starter culture."},
+	}
+	if err := <-store.AddDocuments(context.Background(), docs); err != nil {
+		t.Fatalf("AddDocuments: %v", err)
+	}
+	// the model answers only if retrieval put the Ollama document in the prompt
+	model := fake.New().OnMatch("port 11434", fake.Response{Content: "Ollama listens on port 11434."})
+
+	pipe, err := newRAGPipe(&llmAgent{llm: model, state: state.NewMemoryState()}, store)
+	if err != nil {
+		t.Fatalf("newRAGPipe: %v", err)
+	}
+	pipe.k = 1
+	resultCh, errCh := pipe.Run(context.Background(), "which port does ollama listen on")
+	select {
+	case answer := <-resultCh:
+		if answer != "Ollama listens on port 11434." {
+			t.Errorf("unexpected answer %q", answer)
+		}
+	case err := <-errCh:
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"gogurt/internal/config"
 	"gogurt/internal/factories"
 	"gogurt/internal/llm"
+	"gogurt/internal/llm/tokenizer"
 	"gogurt/internal/logger"
 	"gogurt/internal/prompts"
 	"gogurt/internal/prompts/planner"
@@ -20,14 +21,18 @@ import (
 
 // SerpApiPipe orchestrates a multi-step task by first planning, then executing, and finally synthesizing a result.
 type SerpApiPipe struct {
-	planner agent.Agent
-	worker  agent.Agent
-	llm     llm.LLM
+	planner       agent.Agent
+	worker        agent.Agent
+	llm           llm.LLM
+	synthesisOpts []llm.Option
 }
 
 // NewSerpApiPipe creates a new SerpApiPipe.
 func NewSerpApiPipe(ctx context.Context, cfg *config.Config) (*SerpApiPipe, error) {
-	llm := factories.GetLLM(cfg)
+	return newSerpApiPipe(cfg, factories.GetLLM(cfg)), nil
+}
+
+func newSerpApiPipe(cfg *config.Config, model llm.LLM) *SerpApiPipe {
 	registry := tools.NewRegistry()
 	errs := registry.RegisterBatch([]*tools.Tool{
 		stateful.ReadScratchpadTool,
@@ -40,14 +45,15 @@ func NewSerpApiPipe(ctx context.Context, cfg *config.Config) (*SerpApiPipe, erro
 		}
 	}
 
-	planner := agent.NewPlannerAgent(llm)
+	planner := agent.NewPlannerAgent(model, registry.ListTools(), plannerOptions(cfg)...)
 	worker := agent.NewWorkerAgent(registry)
 
 	return &SerpApiPipe{
-		planner: planner,
-		worker:  worker,
-		llm:     llm,
-	}, nil
+		planner:       planner,
+		worker:        worker,
+		llm:           model,
+		synthesisOpts: []llm.Option{llm.WithTemperature(cfg.SynthesisTemperature)},
+	}
 }
 
 // Run executes the full plan-and-execute workflow asynchronously.
@@ -55,9 +61,16 @@ func (p *SerpApiPipe) Run(ctx context.Context, prompt string) (<-chan string, <-
 	resultCh := make(chan string, 1)
 	errorCh := make(chan error, 1)
 
+	usage := llm.NewUsageTracker()
+	ctx = llm.WithUsageTracker(llm.WithUsageTracker(ctx, llm.LabeledUsage("serpapi")), usage)
+
 	go func() {
 		defer close(resultCh)
 		defer close(errorCh)
+		defer func() {
+			s := usage.Summary()
+			logger.Info("SerpApiPipe usage: %d calls, %d tokens, ~$%.4f", s.Calls, s.TotalTokens, s.EstimatedCostUSD)
+		}()
 
 		logger.Info("Running SerpApiPipe")
 		// 1. Set up prompt for the planner
@@ -141,11 +154,18 @@ func (p *SerpApiPipe) Run(ctx context.Context, prompt string) (<-chan string, <-
 
 		// 4. Synthesize the final answer asynchronously
 		logger.Info("Synthesizing final answer from tool results.")
+		// raw search results can be far larger than the model's window
+		tok := tokenizer.ForModel(llm.ModelName(p.llm))
+		information := fmt.Sprintf("%v", lastResult)
+		if budget := llm.ContextWindowOf(p.llm) / 2; tok.Count(information) > budget {
+			logger.Warn("Truncating %d tokens of tool results to %d", tok.Count(information), budget)
+			information = tok.Truncate(information, budget)
+		}
 		synthesisPrompt := fmt.Sprintf(
 			"Based on the following information, please provide a direct answer to the user's original question.\n\n"+
-				"Information:\n%v\n\n"+
+				"Information:\n%s\n\n"+
 				"Original Question: %s",
-			lastResult,
+			information,
 			prompt,
 		)
 
@@ -154,7 +174,7 @@ func (p *SerpApiPipe) Run(ctx context.Context, prompt string) (<-chan string, <-
 			{Role: types.RoleUser, Content: synthesisPrompt},
 		}
 
-		finalAnswerCh, synthErrCh := p.llm.AGenerate(ctx, synthesisMessages)
+		finalAnswerCh, synthErrCh := p.llm.AGenerate(ctx, synthesisMessages, p.synthesisOpts...)
 		select {
 		case finalAnswer := <-finalAnswerCh:
 			if finalAnswer == nil {
@@ -173,4 +193,4 @@ func (p *SerpApiPipe) Run(ctx context.Context, prompt string) (<-chan string, <-
 	}()
 
 	return resultCh, errorCh
-}
\ No newline at end of file
+}
@@ -0,0 +1,41 @@
+package pipes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gogurt/internal/config"
+	"gogurt/internal/llm/fake"
+	"gogurt/internal/types"
+)
+
+func TestSerpApiPipeSynthesizesFromToolResults(t *testing.T) {
+	model := fake.New(
+		// the plan: offline scratchpad tools only
+		fake.Response{ToolCalls: []types.ToolCall{
+			{Name: "save_to_scratchpad", Args: map[string]any{"key": "facts", "content": "Go 1.0 was released in March 2012."}},
+			{Name: "read_scratchpad", Args: map[string]any{"key": "facts"}},
+		}},
+	).OnMatch("Based on the following information", fake.Response{Content: "Go 1.0 came out in March 2012."})
+
+	pipe := newSerpApiPipe(&config.Config{SynthesisTemperature: 0.7}, model)
+	resultCh, errCh := pipe.Run(context.Background(), "When was Go 1.0 released?")
+	select {
+	case result := <-resultCh:
+		if result != "Go 1.0 came out in March 2012." {
+			t.Errorf("unexpected result %q", result)
+		}
+	case err := <-errCh:
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	synthesis := model.LastRequest()
+	prompt := synthesis.Messages[len(synthesis.Messages)-1].Content
+	if !strings.Contains(prompt, "Go 1.0 was released in March 2012.") || !strings.Contains(prompt, "When was Go 1.0 released?") {
+		t.Errorf("synthesis prompt missing tool result or question:\n%s", prompt)
+	}
+	if temp := synthesis.Options.Temperature; temp == nil || *temp != 0.7 {
+		t.Errorf("expected synthesis temperature 0.7, got %v", temp)
+	}
+}
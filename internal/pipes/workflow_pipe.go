@@ -7,6 +7,7 @@ import (
 	"gogurt/internal/agent"
 	"gogurt/internal/config"
 	"gogurt/internal/factories"
+	"gogurt/internal/llm"
 	"gogurt/internal/tools"
 	"gogurt/internal/tools/file_tools"
 	"strings"
@@ -20,7 +21,10 @@ type WorkflowPipe struct {
 
 // NewWorkflowPipe creates a new WorkflowPipe.
 func NewWorkflowPipe(ctx context.Context, cfg *config.Config) (*WorkflowPipe, error) {
-	llm := factories.GetLLM(cfg)
+	return newWorkflowPipe(cfg, factories.GetLLM(cfg)), nil
+}
+
+func newWorkflowPipe(cfg *config.Config, model llm.LLM) *WorkflowPipe {
 	registry := tools.NewRegistry()
 	// Register all simple tools for the workflow
 	errs := registry.RegisterBatch([]*tools.Tool{
@@ -43,19 +47,20 @@ func NewWorkflowPipe(ctx context.Context, cfg *config.Config) (*WorkflowPipe, er
 		}
 	}
 
-	planner := agent.NewPlannerAgent(llm)
+	planner := agent.NewPlannerAgent(model, registry.ListTools(), plannerOptions(cfg)...)
 	worker := agent.NewWorkerAgent(registry)
 
 	return &WorkflowPipe{
 		planner: planner,
 		worker:  worker,
-	}, nil
+	}
 }
 
 // Run executes the full plan-and-execute workflow asynchronously.
 func (p *WorkflowPipe) Run(ctx context.Context, prompt string) (<-chan string, <-chan error) {
 	resultCh := make(chan string, 1)
 	errorCh := make(chan error, 1)
+	ctx = llm.WithUsageTracker(ctx, llm.LabeledUsage("workflow"))
 
 	go func() {
 		defer close(resultCh)
@@ -146,4 +151,4 @@ func (p *WorkflowPipe) Run(ctx context.Context, prompt string) (<-chan string, <
 	}()
 
 	return resultCh, errorCh
-}
\ No newline at end of file
+}
@@ -0,0 +1,13 @@
+package rag
+
+// RerankPrompt asks a model to grade retrieved passages; passages is a numbered list.
+const RerankPrompt = `
+Rate how relevant each passage is to the question, from 0 (unrelated) to 10 (answers it directly).
+Passages that merely repeat the question's words without answering it are not relevant.
+---
+Question: {{.question}}
+---
+Passages:
+{{.passages}}
+---
+Reply with only JSON of the form {"scores": [{"passage": 1, "relevance": 7}]}, with one entry per passage.`
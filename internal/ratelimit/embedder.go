@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+
+	"gogurt/internal/embeddings"
+	"gogurt/internal/llm/tokenizer"
+	"gogurt/internal/types"
+)
+
+// LimitedEmbedder passes every embedding request through a Limiter. Token use is
+// estimated from the input text, as embedding APIs report none back.
+type LimitedEmbedder struct {
+	next    embeddings.Embedder
+	limiter *Limiter
+	tok     tokenizer.Tokenizer
+}
+
+func NewEmbedder(next embeddings.Embedder, limiter *Limiter) *LimitedEmbedder {
+	return &LimitedEmbedder{next: next, limiter: limiter, tok: tokenizer.Estimate{}}
+}
+
+func (e *LimitedEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	release, err := e.limiter.Acquire(ctx, e.tok.Count(text))
+	if err != nil {
+		return nil, err
+	}
+	defer release(-1)
+	return e.next.EmbedQuery(ctx, text)
+}
+
+func (e *LimitedEmbedder) EmbedDocuments(ctx context.Context, docs []types.Document) ([][]float32, error) {
+	tokens := 0
+	for _, doc := range docs {
+		tokens += e.tok.Count(doc.PageContent)
+	}
+	release, err := e.limiter.Acquire(ctx, tokens)
+	if err != nil {
+		return nil, err
+	}
+	defer release(-1)
+	return e.next.EmbedDocuments(ctx, docs)
+}
+
+// EmbedAll embeds docs in batches with up to workers concurrent requests, each taking a
+// slot from the limiter, so the effective concurrency is the smaller of the two.
+func (e *LimitedEmbedder) EmbedAll(ctx context.Context, docs []types.Document, workers int) ([][]float32, error) {
+	return embeddings.EmbedBatches(ctx, e, docs, embeddings.BatchOptions{Workers: workers, MaxRetries: 1})
+}
+
+// BatchSize is the wrapped embedder's batch size.
+func (e *LimitedEmbedder) BatchSize() int {
+	return embeddings.BatchSizeOf(e.next)
+}
+
+// AEmbedQuery provides an asynchronous EmbedQuery.
+func (e *LimitedEmbedder) AEmbedQuery(ctx context.Context, text string) (<-chan []float32, <-chan error) {
+	out := make(chan []float32, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		embedding, err := e.EmbedQuery(ctx, text)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		out <- embedding
+	}()
+	return out, errCh
+}
+
+// AEmbedDocuments provides an asynchronous EmbedDocuments.
+func (e *LimitedEmbedder) AEmbedDocuments(ctx context.Context, docs []types.Document) (<-chan [][]float32, <-chan error) {
+	out := make(chan [][]float32, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		embeddings, err := e.EmbedDocuments(ctx, docs)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		out <- embeddings
+	}()
+	return out, errCh
+}
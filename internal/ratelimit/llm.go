@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/llm/tokenizer"
+	"gogurt/internal/tools"
+	"gogurt/internal/types"
+)
+
+// LimitedLLM passes every call to the wrapped LLM through a Limiter. Token use is
+// estimated from the prompt and the call's MaxTokens, then corrected from the reported usage.
+type LimitedLLM struct {
+	next    llm.LLM
+	limiter *Limiter
+	tok     tokenizer.Tokenizer
+}
+
+func NewLLM(next llm.LLM, limiter *Limiter) *LimitedLLM {
+	return &LimitedLLM{next: next, limiter: limiter, tok: tokenizer.ForModel(llm.ModelName(next))}
+}
+
+func (l *LimitedLLM) do(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts []llm.Option,
+	call func() (*types.ChatMessage, error)) (*types.ChatMessage, error) {
+	estimate := tokenizer.CountMessages(l.tok, messages) + tokenizer.CountTools(l.tok, toolset) +
+		llm.ApplyOptions(llm.GenerateOptions{}, opts...).MaxTokens
+	release, err := l.limiter.Acquire(ctx, estimate)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := call()
+	used := -1
+	if resp != nil && resp.Usage != nil {
+		used = resp.Usage.TotalTokens
+	}
+	release(used)
+	return resp, err
+}
+
+func (l *LimitedLLM) Generate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (*types.ChatMessage, error) {
+	return l.do(ctx, messages, nil, opts, func() (*types.ChatMessage, error) {
+		return l.next.Generate(ctx, messages, opts...)
+	})
+}
+
+func (l *LimitedLLM) GenerateWithTools(ctx context.Context, messages []types.ChatMessage, toolset []*tools.Tool, opts ...llm.Option) (*types.ChatMessage, error) {
+	return l.do(ctx, messages, toolset, opts, func() (*types.ChatMessage, error) {
+		return l.next.GenerateWithTools(ctx, messages, toolset, opts...)
+	})
+}
+
+// AGenerate provides an asynchronous Generate.
+func (l *LimitedLLM) AGenerate(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan *types.ChatMessage, <-chan error) {
+	msgCh := make(chan *types.ChatMessage, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(msgCh)
+		defer close(errCh)
+		msg, err := l.Generate(ctx, messages, opts...)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		msgCh <- msg
+	}()
+	return msgCh, errCh
+}
+
+// Stream holds its slot until the stream ends.
+func (l *LimitedLLM) Stream(ctx context.Context, messages []types.ChatMessage, onToken func(token string) error, opts ...llm.Option) (*types.ChatMessage, error) {
+	return l.do(ctx, messages, nil, opts, func() (*types.ChatMessage, error) {
+		return l.next.Stream(ctx, messages, onToken, opts...)
+	})
+}
+
+// AStream provides an asynchronous streaming interface returning tokens.
+func (l *LimitedLLM) AStream(ctx context.Context, messages []types.ChatMessage, opts ...llm.Option) (<-chan string, <-chan error) {
+	tokenCh := make(chan string)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(tokenCh)
+		defer close(errCh)
+		_, err := l.Stream(ctx, messages, func(token string) error {
+			select {
+			case tokenCh <- token:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, opts...)
+		if err != nil {
+			errCh <- err
+		}
+	}()
+	return tokenCh, errCh
+}
+
+// HealthCheck bypasses the limiter so probes are not queued behind real work.
+func (l *LimitedLLM) HealthCheck(ctx context.Context) error {
+	return l.next.HealthCheck(ctx)
+}
+
+func (l *LimitedLLM) Metadata() map[string]any {
+	return l.next.Metadata()
+}
@@ -0,0 +1,218 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Config bounds the load sent to one provider. Zero values disable a limit.
+type Config struct {
+	MaxInFlight       int
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+func (c Config) Enabled() bool {
+	return c.MaxInFlight > 0 || c.RequestsPerMinute > 0 || c.TokensPerMinute > 0
+}
+
+// Stats is a snapshot of a limiter, for metrics.
+type Stats struct {
+	MaxInFlight       int     `json:"max_in_flight"`
+	RequestsPerMinute int     `json:"requests_per_minute"`
+	TokensPerMinute   int     `json:"tokens_per_minute"`
+	InFlight          int     `json:"in_flight"`
+	Waiting           int     `json:"waiting"`
+	Requests          int64   `json:"requests"`
+	Tokens            int64   `json:"tokens"`
+	Canceled          int64   `json:"canceled"`
+	WaitSeconds       float64 `json:"wait_seconds"`
+}
+
+// Limiter caps concurrent requests and meters requests and tokens per minute. Callers
+// queue in Acquire until every budget allows them, or their context ends. A Limiter is
+// shared by all clients of a provider, e.g. the chat model and embedder of one Ollama.
+type Limiter struct {
+	slots    chan struct{}
+	requests *bucket
+	tokens   *bucket
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+func New(cfg Config) *Limiter {
+	l := &Limiter{
+		requests: newBucket(cfg.RequestsPerMinute),
+		tokens:   newBucket(cfg.TokensPerMinute),
+		stats: Stats{
+			MaxInFlight:       cfg.MaxInFlight,
+			RequestsPerMinute: cfg.RequestsPerMinute,
+			TokensPerMinute:   cfg.TokensPerMinute,
+		},
+	}
+	if cfg.MaxInFlight > 0 {
+		l.slots = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return l
+}
+
+// Acquire waits for a slot for a request expected to use tokens. The returned release
+// must be called when the request ends, with the tokens it actually used (or -1 if
+// unknown) so the token budget can be corrected.
+func (l *Limiter) Acquire(ctx context.Context, tokens int) (release func(used int), err error) {
+	start := time.Now()
+	l.update(func(s *Stats) { s.Waiting++ })
+	defer func() {
+		l.update(func(s *Stats) {
+			s.Waiting--
+			s.WaitSeconds += time.Since(start).Seconds()
+			if err != nil {
+				s.Canceled++
+			}
+		})
+	}()
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := l.requests.wait(ctx, 1); err != nil {
+		l.releaseSlot()
+		return nil, err
+	}
+	if err := l.tokens.wait(ctx, tokens); err != nil {
+		l.releaseSlot()
+		return nil, err
+	}
+
+	l.update(func(s *Stats) {
+		s.InFlight++
+		s.Requests++
+	})
+	var once sync.Once
+	return func(used int) {
+		once.Do(func() {
+			if used < 0 {
+				used = tokens
+			}
+			l.tokens.adjust(used - tokens)
+			l.update(func(s *Stats) {
+				s.InFlight--
+				s.Tokens += int64(used)
+			})
+			l.releaseSlot()
+		})
+	}, nil
+}
+
+func (l *Limiter) releaseSlot() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+func (l *Limiter) update(fn func(*Stats)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fn(&l.stats)
+}
+
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// bucket is a token bucket refilled continuously at perMinute per minute, holding at
+// most a minute's worth. Its level may go negative when usage exceeds an estimate.
+type bucket struct {
+	mu        sync.Mutex
+	perMinute float64
+	level     float64
+	last      time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &bucket{perMinute: float64(perMinute), level: float64(perMinute), last: time.Now()}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.level = min(b.perMinute, b.level+now.Sub(b.last).Minutes()*b.perMinute)
+	b.last = now
+}
+
+// wait takes n units, sleeping until they are available. Requests larger than the whole
+// budget wait for a full bucket rather than forever.
+func (b *bucket) wait(ctx context.Context, n int) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+	need := min(float64(n), b.perMinute)
+	for {
+		b.mu.Lock()
+		b.refill(time.Now())
+		if b.level >= need {
+			b.level -= float64(n)
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - b.level) / b.perMinute * float64(time.Minute))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// adjust corrects the level once a request's actual usage is known.
+func (b *bucket) adjust(delta int) {
+	if b == nil || delta == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	b.level -= float64(delta)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Limiter{}
+)
+
+// ForProvider returns the limiter shared by everything talking to provider, creating
+// it with cfg on first use.
+func ForProvider(provider string, cfg Config) *Limiter {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	l, ok := registry[provider]
+	if !ok {
+		l = New(cfg)
+		registry[provider] = l
+	}
+	return l
+}
+
+// AllStats returns a snapshot of every provider limiter.
+func AllStats() map[string]Stats {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	out := make(map[string]Stats, len(registry))
+	for name, l := range registry {
+		out[name] = l.Stats()
+	}
+	return out
+}
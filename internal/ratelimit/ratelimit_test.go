@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gogurt/internal/embeddings"
+	"gogurt/internal/llm/fake"
+	"gogurt/internal/types"
+)
+
+func TestMaxInFlight(t *testing.T) {
+	l := New(Config{MaxInFlight: 2})
+	var current, peak atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.Acquire(context.Background(), 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := current.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			current.Add(-1)
+			release(-1)
+		}()
+	}
+	wg.Wait()
+	if peak.Load() > 2 {
+		t.Errorf("expected at most 2 in flight, saw %d", peak.Load())
+	}
+	if s := l.Stats(); s.Requests != 8 || s.InFlight != 0 || s.Waiting != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestQueuedCallerRespectsCancel(t *testing.T) {
+	l := New(Config{MaxInFlight: 1})
+	release, _ := l.Acquire(context.Background(), 0)
+	defer release(-1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if s := l.Stats(); s.Canceled != 1 || s.Waiting != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestTokensPerMinute(t *testing.T) {
+	l := New(Config{TokensPerMinute: 100})
+	release, err := l.Acquire(context.Background(), 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the request used more than estimated, leaving too little for another 60
+	release(90)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, 60); err == nil {
+		t.Fatal("expected to wait for the token budget")
+	}
+	if _, err := l.Acquire(context.Background(), 5); err != nil {
+		t.Errorf("a small request should fit: %v", err)
+	}
+}
+
+func TestLimitedLLMReconcilesUsage(t *testing.T) {
+	model := fake.New(fake.Response{Content: "ok", Usage: &types.Usage{TotalTokens: 42}})
+	l := New(Config{MaxInFlight: 1})
+	resp, err := NewLLM(model, l).Generate(context.Background(), []types.ChatMessage{{Role: types.RoleUser, Content: "hi"}})
+	if err != nil || resp.Content != "ok" {
+		t.Fatalf("unexpected result %v, %v", resp, err)
+	}
+	if s := l.Stats(); s.Tokens != 42 || s.InFlight != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+// lenEmbedder embeds a text as its length.
+type lenEmbedder struct{ embeddings.Embedder }
+
+func (lenEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return []float32{float32(len(text))}, nil
+}
+
+func (l lenEmbedder) EmbedDocuments(ctx context.Context, docs []types.Document) ([][]float32, error) {
+	var out [][]float32
+	for _, doc := range docs {
+		v, _ := l.EmbedQuery(ctx, doc.PageContent)
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func TestLimitedEmbedderKeepsOrder(t *testing.T) {
+	e := NewEmbedder(lenEmbedder{}, New(Config{MaxInFlight: 2}))
+	docs := []types.Document{{PageContent: "a"}, {PageContent: "bbb"}, {PageContent: "cc"}, {PageContent: "dddd"}}
+	got, err := e.EmbedAll(context.Background(), docs, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, doc := range docs {
+		if got[i][0] != float32(len(doc.PageContent)) {
+			t.Errorf("embedding %d out of order: %v", i, got)
+		}
+	}
+}
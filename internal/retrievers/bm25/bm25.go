@@ -0,0 +1,296 @@
+// Package bm25 is an in-process inverted index ranking documents by Okapi BM25, for
+// lexical retrieval of exact identifiers, error codes and names that embeddings blur.
+package bm25
+
+import (
+	"cmp"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores"
+)
+
+// The usual BM25 parameters: k1 saturates term frequency and b normalizes by length.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+type entry struct {
+	doc    types.Document
+	terms  map[string]int
+	length int
+}
+
+// Index ranks documents by BM25. Documents are keyed by vectorstores.DocumentID, so the
+// same chunk gets the same ID here and in the vector store. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*entry
+	postings map[string]map[string]int
+	totalLen int
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{docs: make(map[string]*entry), postings: make(map[string]map[string]int)}
+}
+
+// Len returns the number of indexed documents.
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// Add indexes docs, replacing documents with the same ID.
+func (x *Index) Add(docs []types.Document) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, doc := range docs {
+		doc.ID = vectorstores.DocumentID(doc)
+		x.remove(doc.ID)
+		e := &entry{doc: doc, terms: make(map[string]int)}
+		for _, term := range Tokenize(doc.PageContent) {
+			e.terms[term]++
+			e.length++
+		}
+		for term, tf := range e.terms {
+			if x.postings[term] == nil {
+				x.postings[term] = make(map[string]int)
+			}
+			x.postings[term][doc.ID] = tf
+		}
+		x.docs[doc.ID] = e
+		x.totalLen += e.length
+	}
+}
+
+// Delete removes the documents with the given IDs.
+func (x *Index) Delete(ids []string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, id := range ids {
+		x.remove(id)
+	}
+}
+
+// DeleteWhere removes the documents whose metadata has all the given values. An empty
+// filter fails with vectorstores.ErrNoFilter.
+func (x *Index) DeleteWhere(metadata map[string]any) error {
+	if len(metadata) == 0 {
+		return vectorstores.ErrNoFilter
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for id, e := range x.docs {
+		if vectorstores.MatchesMetadata(e.doc.Metadata, metadata) {
+			x.remove(id)
+		}
+	}
+	return nil
+}
+
+// Clear removes every document.
+func (x *Index) Clear() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.docs = make(map[string]*entry)
+	x.postings = make(map[string]map[string]int)
+	x.totalLen = 0
+}
+
+func (x *Index) remove(id string) {
+	e, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	for term := range e.terms {
+		delete(x.postings[term], id)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	x.totalLen -= e.length
+	delete(x.docs, id)
+}
+
+// Search returns up to k documents matching filter that share terms with query, best
+// first, scored by BM25.
+func (x *Index) Search(query string, k int, filter *vectorstores.Filter) []vectorstores.ScoredDocument {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if len(x.docs) == 0 || k <= 0 {
+		return nil
+	}
+	n := float64(len(x.docs))
+	avgLen := float64(x.totalLen) / n
+	scores := make(map[string]float64)
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		// repeating a word in the query does not count it twice
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		posting := x.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(len(posting))+0.5)/(float64(len(posting))+0.5))
+		for id, tf := range posting {
+			length := float64(x.docs[id].length)
+			f := float64(tf)
+			scores[id] += idf * f * (k1 + 1) / (f + k1*(1-b+b*length/avgLen))
+		}
+	}
+
+	results := make([]vectorstores.ScoredDocument, 0, len(scores))
+	for id, score := range scores {
+		doc := x.docs[id].doc
+		if filter.Matches(doc.Metadata) {
+			results = append(results, vectorstores.ScoredDocument{Document: doc, Score: score})
+		}
+	}
+	slices.SortFunc(results, func(p, q vectorstores.ScoredDocument) int {
+		if c := cmp.Compare(q.Score, p.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(p.Document.ID, q.Document.ID)
+	})
+	return results[:min(k, len(results))]
+}
+
+// stopwords are frequent English words that carry no lexical signal.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "do": true, "does": true, "for": true, "from": true, "how": true, "in": true,
+	"is": true, "it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "what": true, "when": true, "where": true,
+	"which": true, "who": true, "why": true, "with": true,
+}
+
+// Tokenize lowercases text and splits it into words of letters, digits and underscores.
+// Identifiers are also split into their parts, so ErrModelNotFound yields
+// errmodelnotfound, err, model, not and found, and max_tokens yields max_tokens, max and
+// tokens: a query can match either the whole name or its words.
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	var tokens []string
+	add := func(token string) {
+		token = strings.ToLower(strings.Trim(token, "_"))
+		if token != "" && !stopwords[token] {
+			tokens = append(tokens, token)
+		}
+	}
+	for _, word := range words {
+		parts := splitIdentifier(word)
+		add(word)
+		if len(parts) > 1 {
+			for _, part := range parts {
+				add(part)
+			}
+		}
+	}
+	return tokens
+}
+
+// splitIdentifier splits snake_case and camelCase words; acronyms stay together, so
+// HTTPServer splits into HTTP and Server.
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, piece := range strings.Split(word, "_") {
+		runes := []rune(piece)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			next := rune(0)
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+			lowerToUpper := unicode.IsLower(prev) && unicode.IsUpper(cur)
+			acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(cur) && unicode.IsLower(next)
+			if lowerToUpper || acronymEnd {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
+
+// snapshot is the saved form of an index; the postings are rebuilt on load.
+type snapshot struct {
+	Version   int
+	Documents []types.Document
+}
+
+const snapshotVersion = 1
+
+// Open loads the index saved at path, or returns an empty index if there is none.
+func Open(path string) (*Index, error) {
+	x := New()
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return x, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var s snapshot
+	if err := gob.NewDecoder(f).Decode(&s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("%s: unsupported BM25 index version %d", path, s.Version)
+	}
+	x.Add(s.Documents)
+	return x, nil
+}
+
+// Save writes the index to path atomically.
+func (x *Index) Save(path string) error {
+	x.mu.RLock()
+	s := snapshot{Version: snapshotVersion, Documents: make([]types.Document, 0, len(x.docs))}
+	for _, e := range x.docs {
+		s.Documents = append(s.Documents, e.doc)
+	}
+	x.mu.RUnlock()
+	slices.SortFunc(s.Documents, func(p, q types.Document) int { return cmp.Compare(p.ID, q.ID) })
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(s); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
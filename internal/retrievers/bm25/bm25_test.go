@@ -0,0 +1,96 @@
+package bm25
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores"
+)
+
+func TestTokenizeSplitsIdentifiers(t *testing.T) {
+	got := Tokenize("The HTTPServer returned ErrModelNotFound for max_tokens")
+	want := []string{"httpserver", "http", "server", "returned", "errmodelnotfound", "err", "model", "not", "found", "max_tokens", "max", "tokens"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Tokenize = %v, want %v", got, want)
+	}
+}
+
+var corpus = []types.Document{
+	{PageContent: "func NewIngestPipe creates the document ingestion pipeline", Metadata: map[string]any{"source": "ingest.go", "language": "go"}},
+	{PageContent: "The ingestion pipeline loads, splits and embeds documents", Metadata: map[string]any{"source": "README.md", "language": "markdown"}},
+	{PageContent: "ErrModelNotFound is returned when Ollama has not pulled the model", Metadata: map[string]any{"source": "errors.go", "language": "go"}},
+	{PageContent: "Chroma stores vectors in a collection", Metadata: map[string]any{"source": "chroma.md", "language": "markdown"}},
+}
+
+func sources(results []vectorstores.ScoredDocument) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.Document.Metadata["source"].(string))
+	}
+	return out
+}
+
+func TestSearchRanksExactIdentifiers(t *testing.T) {
+	x := New()
+	x.Add(corpus)
+	if got := sources(x.Search("ErrModelNotFound", 2, nil)); len(got) != 1 || got[0] != "errors.go" {
+		t.Errorf("expected only errors.go, got %v", got)
+	}
+	got := x.Search("NewIngestPipe pipeline", 4, nil)
+	if len(got) != 2 || got[0].Document.Metadata["source"] != "ingest.go" || got[0].Score <= got[1].Score {
+		t.Errorf("expected ingest.go ahead of README.md, got %v", sources(got))
+	}
+	if got := sources(x.Search("ingestion pipeline", 4, vectorstores.Eq("language", "markdown"))); !slices.Equal(got, []string{"README.md"}) {
+		t.Errorf("expected the filter to leave README.md, got %v", got)
+	}
+	if got := x.Search("the of and", 4, nil); len(got) != 0 {
+		t.Errorf("expected stopwords to match nothing, got %v", sources(got))
+	}
+}
+
+func TestDeleteAndReplace(t *testing.T) {
+	x := New()
+	x.Add(corpus)
+	x.Add(corpus[:1])
+	if x.Len() != 4 {
+		t.Fatalf("expected re-adding a document to replace it, got %d documents", x.Len())
+	}
+	if err := x.DeleteWhere(map[string]any{}); !errors.Is(err, vectorstores.ErrNoFilter) || x.Len() != 4 {
+		t.Fatalf("expected an empty filter to be refused, got %v", err)
+	}
+	if err := x.DeleteWhere(map[string]any{"language": "go"}); err != nil {
+		t.Fatal(err)
+	}
+	if x.Len() != 2 || len(x.Search("ErrModelNotFound", 4, nil)) != 0 {
+		t.Errorf("expected the go documents to be deleted")
+	}
+	x.Delete([]string{vectorstores.DocumentID(corpus[3])})
+	if got := sources(x.Search("chroma pipeline", 4, nil)); !slices.Equal(got, []string{"README.md"}) {
+		t.Errorf("unexpected results after delete: %v", got)
+	}
+}
+
+func TestSaveOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bm25.gob")
+	if x, err := Open(path); err != nil || x.Len() != 0 {
+		t.Fatalf("expected a missing file to open empty, got %v", err)
+	}
+	x := New()
+	x.Add(corpus)
+	if err := x.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	y, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := x.Search("model pipeline", 4, nil), y.Search("model pipeline", 4, nil)
+	if len(a) == 0 || !slices.EqualFunc(a, b, func(p, q vectorstores.ScoredDocument) bool {
+		return p.Document.ID == q.Document.ID && p.Score == q.Score
+	}) {
+		t.Errorf("expected the same results after reopening: %v vs %v", sources(a), sources(b))
+	}
+}
@@ -0,0 +1,74 @@
+package bm25
+
+import (
+	"context"
+
+	"gogurt/internal/logger"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores"
+)
+
+// Store is a vector store that applies every change to a BM25 index as well, so lexical
+// retrieval searches the same documents as vector retrieval. Changes the vector store
+// rejects are not applied.
+type Store struct {
+	vectorstores.VectorStore
+	index *Index
+	// path is where the index is saved after each change; empty keeps it in memory
+	path string
+}
+
+func NewStore(store vectorstores.VectorStore, index *Index, path string) *Store {
+	return &Store{VectorStore: store, index: index, path: path}
+}
+
+// AddDocuments adds docs to the vector store, then indexes the ones it stored.
+func (s *Store) AddDocuments(ctx context.Context, docs []types.Document) <-chan error {
+	return s.then(s.VectorStore.AddDocuments(ctx, docs), func(err error) {
+		s.index.Add(vectorstores.Stored(docs, err))
+	})
+}
+
+// Upsert upserts docs into the vector store, then indexes the ones it stored.
+func (s *Store) Upsert(ctx context.Context, docs []types.Document) <-chan error {
+	return s.then(s.VectorStore.Upsert(ctx, docs), func(err error) {
+		s.index.Add(vectorstores.Stored(docs, err))
+	})
+}
+
+// Delete removes the documents with the given IDs from the vector store and the index.
+func (s *Store) Delete(ctx context.Context, ids []string) <-chan error {
+	return s.then(s.VectorStore.Delete(ctx, ids), func(err error) {
+		if err == nil {
+			s.index.Delete(ids)
+		}
+	})
+}
+
+// DeleteWhere removes the matching documents from the vector store and the index.
+func (s *Store) DeleteWhere(ctx context.Context, metadata map[string]any) <-chan error {
+	return s.then(s.VectorStore.DeleteWhere(ctx, metadata), func(err error) {
+		if err == nil {
+			// the vector store has accepted the filter, so it is not empty
+			s.index.DeleteWhere(metadata)
+		}
+	})
+}
+
+// then waits for a vector store change, applies it to the index and saves the index. The
+// vector store already holds the change, so a failed save is only logged.
+func (s *Store) then(storeErr <-chan error, apply func(error)) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		err := <-storeErr
+		apply(err)
+		if s.path != "" {
+			if saveErr := s.index.Save(s.path); saveErr != nil {
+				logger.Warn("failed to save BM25 index to %s: %v", s.path, saveErr)
+			}
+		}
+		errCh <- err
+	}()
+	return errCh
+}
@@ -0,0 +1,48 @@
+package bm25
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gogurt/internal/embeddings/local"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores"
+	"gogurt/internal/vectorstores/simple"
+)
+
+func TestStoreKeepsIndexInStep(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "bm25.gob")
+	x := New()
+	s := NewStore(simple.New(local.New(16)), x, path)
+	if err := <-s.AddDocuments(ctx, corpus); err != nil {
+		t.Fatal(err)
+	}
+	if x.Len() != 4 {
+		t.Fatalf("expected 4 indexed documents, got %d", x.Len())
+	}
+	if err := <-s.DeleteWhere(ctx, map[string]any{"language": "go"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-s.Delete(ctx, []string{vectorstores.DocumentID(corpus[3])}); err != nil {
+		t.Fatal(err)
+	}
+	changed := types.Document{ID: vectorstores.DocumentID(corpus[1]), PageContent: "Ingestion now deduplicates chunks", Metadata: corpus[1].Metadata}
+	if err := <-s.Upsert(ctx, []types.Document{changed}); err != nil {
+		t.Fatal(err)
+	}
+	if got := x.Search("pipeline ErrModelNotFound chroma", 4, nil); len(got) != 0 {
+		t.Errorf("expected deleted and replaced text to be gone, got %v", sources(got))
+	}
+	if got := x.Search("deduplicates", 4, nil); len(got) != 1 {
+		t.Errorf("expected the upserted text to be indexed, got %v", sources(got))
+	}
+	saved, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Len() != 1 {
+		t.Errorf("expected the saved index to hold 1 document, got %d", saved.Len())
+	}
+}
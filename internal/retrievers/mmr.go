@@ -0,0 +1,118 @@
+package retrievers
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"gogurt/internal/embeddings"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores"
+)
+
+// MMRRetriever diversifies another retriever's results by maximal marginal relevance:
+// from fetchK candidates it repeatedly picks the one that best balances relevance to the
+// query against similarity to the documents already picked, so overlapping chunks of the
+// same passage do not crowd out the rest.
+type MMRRetriever struct {
+	base     Retriever
+	embedder embeddings.Embedder
+	lambda   float64
+	fetchK   int
+}
+
+// NewMMR returns an MMRRetriever. lambda weighs relevance against diversity, from 0 (only
+// diversity) to 1 (only relevance, the base order); fetchK is the number of candidates,
+// where 0 means 4k. Candidates are embedded to compare them, which an embedding cache
+// usually answers for ingested chunks.
+func NewMMR(base Retriever, embedder embeddings.Embedder, lambda float64, fetchK int) (*MMRRetriever, error) {
+	if lambda < 0 || lambda > 1 {
+		return nil, fmt.Errorf("MMR lambda %v is not between 0 and 1", lambda)
+	}
+	return &MMRRetriever{base: base, embedder: embedder, lambda: lambda, fetchK: fetchK}, nil
+}
+
+func (r *MMRRetriever) Retrieve(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []vectorstores.ScoredDocument, <-chan error) {
+	out := make(chan []vectorstores.ScoredDocument, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		fetchK := r.fetchK
+		if fetchK <= 0 {
+			fetchK = 4 * k
+		}
+		candidatesCh, candidatesErrCh := r.base.Retrieve(ctx, query, max(fetchK, k), opts...)
+		candidates, err := wait(ctx, candidatesCh, candidatesErrCh)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		if len(candidates) <= 1 {
+			out <- candidates
+			return
+		}
+		docs := make([]types.Document, len(candidates))
+		for i, c := range candidates {
+			docs[i] = c.Document
+		}
+		vectors, err := r.embedder.EmbedDocuments(ctx, docs)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to embed MMR candidates: %w", err)
+			return
+		}
+		out <- mmr(candidates, vectors, r.lambda, k)
+	}()
+	return out, errCh
+}
+
+// mmr selects k of candidates, ranked best first, with vectors[i] embedding candidates[i].
+// Relevance is the candidates' scores relative to the best one, so any retriever's scores
+// work; scaling by the best rather than the range keeps the weakest candidate eligible.
+func mmr(candidates []vectorstores.ScoredDocument, vectors [][]float32, lambda float64, k int) []vectorstores.ScoredDocument {
+	_, hi := scoreRange(candidates)
+	relevance := make([]float64, len(candidates))
+	for i, c := range candidates {
+		relevance[i] = 1
+		if hi > 0 {
+			relevance[i] = max(c.Score, 0) / hi
+		}
+	}
+	// redundancy[i] is the highest similarity of candidate i to a selected document
+	redundancy := make([]float64, len(candidates))
+	selected := make([]bool, len(candidates))
+	results := make([]vectorstores.ScoredDocument, 0, min(k, len(candidates)))
+	for len(results) < cap(results) {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range candidates {
+			if selected[i] {
+				continue
+			}
+			score := lambda*relevance[i] - (1-lambda)*redundancy[i]
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		selected[best] = true
+		results = append(results, candidates[best])
+		for i := range candidates {
+			if !selected[i] {
+				redundancy[i] = max(redundancy[i], cosine(vectors[i], vectors[best]))
+			}
+		}
+	}
+	return results
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range min(len(a), len(b)) {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
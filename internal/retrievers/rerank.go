@@ -0,0 +1,134 @@
+package retrievers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gogurt/internal/llm"
+	"gogurt/internal/llm/structured"
+	"gogurt/internal/logger"
+	"gogurt/internal/prompts"
+	"gogurt/internal/prompts/rag"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores"
+)
+
+// Reranker rescores retrieved documents for a query, typically with a model that reads
+// the query and each document together, which is slower but sharper than the first stage.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, docs []vectorstores.ScoredDocument) ([]vectorstores.ScoredDocument, error)
+}
+
+// RerankingRetriever reranks fetchK candidates from another retriever and keeps the best k.
+type RerankingRetriever struct {
+	base     Retriever
+	reranker Reranker
+	fetchK   int
+}
+
+// NewReranking returns a RerankingRetriever; fetchK is the number of candidates reranked,
+// where 0 means 4k.
+func NewReranking(base Retriever, reranker Reranker, fetchK int) *RerankingRetriever {
+	return &RerankingRetriever{base: base, reranker: reranker, fetchK: fetchK}
+}
+
+// Retrieve reranks the candidates. If the reranker fails, the candidates keep their
+// original order, so an unavailable model degrades answers rather than breaking them.
+func (r *RerankingRetriever) Retrieve(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []vectorstores.ScoredDocument, <-chan error) {
+	out := make(chan []vectorstores.ScoredDocument, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		fetchK := r.fetchK
+		if fetchK <= 0 {
+			fetchK = 4 * k
+		}
+		candidatesCh, candidatesErrCh := r.base.Retrieve(ctx, query, max(fetchK, k), opts...)
+		candidates, err := wait(ctx, candidatesCh, candidatesErrCh)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		if len(candidates) > 1 {
+			reranked, err := r.reranker.Rerank(ctx, query, candidates)
+			switch {
+			case err == nil:
+				candidates = reranked
+			case ctx.Err() != nil:
+				errCh <- ctx.Err()
+				return
+			default:
+				logger.WarnCtx(ctx, "Reranking failed, keeping the retrieval order: %v", err)
+			}
+		}
+		out <- candidates[:min(k, len(candidates))]
+	}()
+	return out, errCh
+}
+
+// passageRelevance is one grade in the LLMReranker's reply.
+type passageRelevance struct {
+	Passage   int     `json:"passage" description:"the passage number"`
+	Relevance float64 `json:"relevance" description:"0 (unrelated) to 10 (answers the question)"`
+}
+
+type relevanceScores struct {
+	Scores []passageRelevance `json:"scores"`
+}
+
+// maxPassageChars bounds each passage shown to the LLMReranker, keeping the prompt small.
+const maxPassageChars = 1500
+
+// LLMReranker grades documents with a chat model in a single call. Scores are relevance
+// from 0 to 1; documents the model leaves ungraded score 0.
+type LLMReranker struct {
+	llm    llm.LLM
+	prompt *prompts.PromptTemplate
+	opts   []llm.Option
+}
+
+// NewLLMReranker returns an LLMReranker. Grading runs at temperature 0 unless opts say
+// otherwise.
+func NewLLMReranker(model llm.LLM, opts ...llm.Option) (*LLMReranker, error) {
+	prompt, err := prompts.NewPromptTemplate(rag.RerankPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rerank prompt: %w", err)
+	}
+	return &LLMReranker{llm: model, prompt: prompt, opts: append([]llm.Option{llm.WithTemperature(0)}, opts...)}, nil
+}
+
+func (r *LLMReranker) Rerank(ctx context.Context, query string, docs []vectorstores.ScoredDocument) ([]vectorstores.ScoredDocument, error) {
+	var passages strings.Builder
+	for i, d := range docs {
+		content := d.Document.PageContent
+		if len(content) > maxPassageChars {
+			content = strings.ToValidUTF8(content[:maxPassageChars], "") + "…"
+		}
+		fmt.Fprintf(&passages, "[%d] %s\n\n", i+1, strings.TrimSpace(content))
+	}
+	prompt, err := r.prompt.Format(map[string]string{"question": query, "passages": passages.String()})
+	if err != nil {
+		return nil, err
+	}
+	grades, err := structured.New[relevanceScores](r.llm).Generate(ctx, []types.ChatMessage{{Role: types.RoleUser, Content: prompt}}, r.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	reranked := make([]vectorstores.ScoredDocument, len(docs))
+	for i, d := range docs {
+		reranked[i] = vectorstores.ScoredDocument{Document: d.Document}
+	}
+	for _, g := range grades.Scores {
+		if g.Passage >= 1 && g.Passage <= len(docs) {
+			reranked[g.Passage-1].Score = min(max(g.Relevance, 0), 10) / 10
+		}
+	}
+	// a stable sort keeps the retrieval order among equal grades
+	slices.SortStableFunc(reranked, func(p, q vectorstores.ScoredDocument) int { return cmp.Compare(q.Score, p.Score) })
+	return reranked, nil
+}
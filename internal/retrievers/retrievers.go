@@ -0,0 +1,235 @@
+// Package retrievers finds the documents relevant to a query, by vector similarity, by
+// BM25 over their words, or by fusing both rankings.
+package retrievers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"gogurt/internal/retrievers/bm25"
+	"gogurt/internal/vectorstores"
+)
+
+// Retriever returns the k documents most relevant to a query, best first, asynchronously.
+// Scores are comparable only within one retriever.
+type Retriever interface {
+	Retrieve(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []vectorstores.ScoredDocument, <-chan error)
+}
+
+// VectorRetriever retrieves by embedding similarity from a vector store.
+type VectorRetriever struct {
+	store vectorstores.VectorStore
+}
+
+func NewVector(store vectorstores.VectorStore) *VectorRetriever {
+	return &VectorRetriever{store: store}
+}
+
+func (r *VectorRetriever) Retrieve(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []vectorstores.ScoredDocument, <-chan error) {
+	return r.store.SimilaritySearchWithScore(ctx, query, k, opts...)
+}
+
+// ThresholdRetriever drops another retriever's results scoring below a minimum, so weak
+// matches are left out of the context rather than padding it to k.
+type ThresholdRetriever struct {
+	base     Retriever
+	minScore float64
+}
+
+func NewThreshold(base Retriever, minScore float64) *ThresholdRetriever {
+	return &ThresholdRetriever{base: base, minScore: minScore}
+}
+
+func (r *ThresholdRetriever) Retrieve(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []vectorstores.ScoredDocument, <-chan error) {
+	out := make(chan []vectorstores.ScoredDocument, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		resultsCh, resultsErrCh := r.base.Retrieve(ctx, query, k, opts...)
+		results, err := wait(ctx, resultsCh, resultsErrCh)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		out <- slices.DeleteFunc(results, func(d vectorstores.ScoredDocument) bool { return d.Score < r.minScore })
+	}()
+	return out, errCh
+}
+
+// LexicalRetriever retrieves by BM25.
+type LexicalRetriever struct {
+	index *bm25.Index
+}
+
+func NewLexical(index *bm25.Index) *LexicalRetriever {
+	return &LexicalRetriever{index: index}
+}
+
+func (r *LexicalRetriever) Retrieve(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []vectorstores.ScoredDocument, <-chan error) {
+	out := make(chan []vectorstores.ScoredDocument, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		out <- r.index.Search(query, k, vectorstores.NewSearchOptions(opts...).Filter)
+	}()
+	return out, errCh
+}
+
+// Fusion is how a HybridRetriever combines rankings.
+type Fusion string
+
+const (
+	// FusionRRF scores a document by reciprocal rank fusion, the sum of 1/(60+rank) over
+	// the rankings it appears in. It ignores the scores, which are not comparable between
+	// BM25 and cosine similarity.
+	FusionRRF Fusion = "rrf"
+	// FusionWeighted scales each ranking's scores to [0, 1] and adds them, weighting
+	// vector scores by VectorWeight and BM25 scores by 1-VectorWeight.
+	FusionWeighted Fusion = "weighted"
+)
+
+// rrfK dampens the advantage of the very top ranks, as in Cormack et al.
+const rrfK = 60
+
+// HybridConfig tunes a HybridRetriever.
+type HybridConfig struct {
+	Fusion Fusion
+	// VectorWeight is the share of the vector ranking in FusionWeighted, from 0 to 1.
+	VectorWeight float64
+	// FetchK is how many documents to take from each ranking before fusing; 0 means 4k.
+	FetchK int
+}
+
+// HybridRetriever fuses a vector and a lexical ranking, so a query finds both documents
+// that mean the same and documents that name the same identifiers.
+type HybridRetriever struct {
+	vector, lexical Retriever
+	cfg             HybridConfig
+}
+
+func NewHybrid(vector, lexical Retriever, cfg HybridConfig) (*HybridRetriever, error) {
+	switch cfg.Fusion {
+	case "":
+		cfg.Fusion = FusionRRF
+	case FusionRRF, FusionWeighted:
+	default:
+		return nil, fmt.Errorf("unknown fusion %q, expected %q or %q", cfg.Fusion, FusionRRF, FusionWeighted)
+	}
+	if cfg.VectorWeight < 0 || cfg.VectorWeight > 1 {
+		return nil, fmt.Errorf("vector weight %v is not between 0 and 1", cfg.VectorWeight)
+	}
+	return &HybridRetriever{vector: vector, lexical: lexical, cfg: cfg}, nil
+}
+
+// Retrieve queries both retrievers concurrently and fuses their rankings.
+func (r *HybridRetriever) Retrieve(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []vectorstores.ScoredDocument, <-chan error) {
+	out := make(chan []vectorstores.ScoredDocument, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		fetchK := r.cfg.FetchK
+		if fetchK <= 0 {
+			fetchK = 4 * k
+		}
+		vecCh, vecErrCh := r.vector.Retrieve(ctx, query, fetchK, opts...)
+		lexCh, lexErrCh := r.lexical.Retrieve(ctx, query, fetchK, opts...)
+		vector, err := wait(ctx, vecCh, vecErrCh)
+		if err != nil {
+			errCh <- fmt.Errorf("vector retrieval: %w", err)
+			return
+		}
+		lexical, err := wait(ctx, lexCh, lexErrCh)
+		if err != nil {
+			errCh <- fmt.Errorf("lexical retrieval: %w", err)
+			return
+		}
+		out <- r.fuse(vector, lexical, k)
+	}()
+	return out, errCh
+}
+
+func (r *HybridRetriever) fuse(vector, lexical []vectorstores.ScoredDocument, k int) []vectorstores.ScoredDocument {
+	type fused struct {
+		doc   vectorstores.ScoredDocument
+		score float64
+		// order breaks ties by first appearance, vector results first
+		order int
+	}
+	byID := make(map[string]*fused)
+	add := func(ranking []vectorstores.ScoredDocument, weight float64) {
+		lo, hi := scoreRange(ranking)
+		for rank, d := range ranking {
+			id := vectorstores.DocumentID(d.Document)
+			f, ok := byID[id]
+			if !ok {
+				f = &fused{doc: d, order: len(byID)}
+				byID[id] = f
+			}
+			switch r.cfg.Fusion {
+			case FusionWeighted:
+				normalized := 1.0
+				if hi > lo {
+					normalized = (d.Score - lo) / (hi - lo)
+				}
+				f.score += weight * normalized
+			default:
+				f.score += 1 / float64(rrfK+rank+1)
+			}
+		}
+	}
+	add(vector, r.cfg.VectorWeight)
+	add(lexical, 1-r.cfg.VectorWeight)
+
+	all := make([]*fused, 0, len(byID))
+	for _, f := range byID {
+		all = append(all, f)
+	}
+	slices.SortFunc(all, func(a, b *fused) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.order, b.order)
+	})
+	results := make([]vectorstores.ScoredDocument, 0, min(k, len(all)))
+	for _, f := range all[:min(k, len(all))] {
+		results = append(results, vectorstores.ScoredDocument{Document: f.doc.Document, Score: f.score})
+	}
+	return results
+}
+
+func scoreRange(ranking []vectorstores.ScoredDocument) (lo, hi float64) {
+	for i, d := range ranking {
+		if i == 0 || d.Score < lo {
+			lo = d.Score
+		}
+		if i == 0 || d.Score > hi {
+			hi = d.Score
+		}
+	}
+	return lo, hi
+}
+
+// wait receives the result of an asynchronous retrieval. Retrievers close both channels
+// when done, so a closed result channel means the error holds the outcome, and a nil error
+// means the result is ready.
+func wait(ctx context.Context, out <-chan []vectorstores.ScoredDocument, errCh <-chan error) ([]vectorstores.ScoredDocument, error) {
+	select {
+	case docs, ok := <-out:
+		if !ok {
+			return nil, <-errCh
+		}
+		return docs, nil
+	case err := <-errCh:
+		if err != nil {
+			return nil, err
+		}
+		return <-out, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,196 @@
+package retrievers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"gogurt/internal/embeddings/local"
+	"gogurt/internal/llm/fake"
+	"gogurt/internal/retrievers/bm25"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores"
+	"gogurt/internal/vectorstores/simple"
+)
+
+// stub returns a fixed ranking, or err.
+type stub struct {
+	ranking []vectorstores.ScoredDocument
+	err     error
+}
+
+func (s stub) Retrieve(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []vectorstores.ScoredDocument, <-chan error) {
+	out := make(chan []vectorstores.ScoredDocument, 1)
+	errCh := make(chan error, 1)
+	if s.err != nil {
+		errCh <- s.err
+	} else {
+		out <- s.ranking[:min(k, len(s.ranking))]
+	}
+	close(out)
+	close(errCh)
+	return out, errCh
+}
+
+func ranking(scores map[string]float64, order ...string) stub {
+	var s stub
+	for _, id := range order {
+		s.ranking = append(s.ranking, vectorstores.ScoredDocument{Document: types.Document{ID: id}, Score: scores[id]})
+	}
+	return s
+}
+
+func retrieve(t *testing.T, r Retriever, query string, k int, opts ...vectorstores.SearchOption) []vectorstores.ScoredDocument {
+	t.Helper()
+	out, errCh := r.Retrieve(context.Background(), query, k, opts...)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	return <-out
+}
+
+func ids(results []vectorstores.ScoredDocument) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.Document.ID)
+	}
+	return out
+}
+
+func TestReciprocalRankFusion(t *testing.T) {
+	vector := ranking(nil, "a", "b", "c")
+	lexical := ranking(nil, "c", "d", "b")
+	r, err := NewHybrid(vector, lexical, HybridConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b and c appear in both rankings; c ranks higher on average
+	got := retrieve(t, r, "q", 3)
+	if !slices.Equal(ids(got), []string{"c", "b", "a"}) {
+		t.Errorf("unexpected fused order %v", ids(got))
+	}
+	if want := 1.0/61 + 1.0/63; got[0].Score != want {
+		t.Errorf("expected c to score %v, got %v", want, got[0].Score)
+	}
+}
+
+func TestWeightedFusion(t *testing.T) {
+	vector := ranking(map[string]float64{"a": 0.9, "b": 0.8, "c": 0.5}, "a", "b", "c")
+	lexical := ranking(map[string]float64{"c": 12, "b": 2, "d": 1}, "c", "b", "d")
+	mostlyVector, _ := NewHybrid(vector, lexical, HybridConfig{Fusion: FusionWeighted, VectorWeight: 0.9})
+	if got := ids(retrieve(t, mostlyVector, "q", 2)); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("expected the vector ranking to dominate, got %v", got)
+	}
+	mostlyLexical, _ := NewHybrid(vector, lexical, HybridConfig{Fusion: FusionWeighted, VectorWeight: 0.1})
+	if got := ids(retrieve(t, mostlyLexical, "q", 2)); !slices.Equal(got, []string{"c", "b"}) {
+		t.Errorf("expected the lexical ranking to dominate, got %v", got)
+	}
+}
+
+func TestHybridConfigAndErrors(t *testing.T) {
+	if _, err := NewHybrid(stub{}, stub{}, HybridConfig{Fusion: "max"}); err == nil {
+		t.Error("expected an unknown fusion to be rejected")
+	}
+	if _, err := NewHybrid(stub{}, stub{}, HybridConfig{VectorWeight: 1.5}); err == nil {
+		t.Error("expected a weight above 1 to be rejected")
+	}
+	boom := errors.New("boom")
+	r, _ := NewHybrid(stub{}, stub{err: boom}, HybridConfig{})
+	_, errCh := r.Retrieve(context.Background(), "q", 3)
+	if err := <-errCh; !errors.Is(err, boom) {
+		t.Errorf("expected the lexical error, got %v", err)
+	}
+}
+
+func TestHybridFindsExactIdentifier(t *testing.T) {
+	ctx := context.Background()
+	docs := []types.Document{
+		{PageContent: "ErrModelNotFound is returned when Ollama has not pulled the model", Metadata: map[string]any{"language": "go"}},
+		{PageContent: "Pull a model with ollama pull before asking it questions", Metadata: map[string]any{"language": "markdown"}},
+		{PageContent: "Models that are missing are downloaded when auto pull is enabled", Metadata: map[string]any{"language": "markdown"}},
+		{PageContent: "Chroma stores vectors in a collection", Metadata: map[string]any{"language": "markdown"}},
+	}
+	store := simple.New(local.New(64))
+	if err := <-store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	index := bm25.New()
+	index.Add(docs)
+
+	r, err := NewHybrid(NewVector(store), NewLexical(index), HybridConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := retrieve(t, r, "what does ErrModelNotFound mean", 2)
+	if len(got) != 2 || got[0].Document.PageContent != docs[0].PageContent {
+		t.Errorf("expected the ErrModelNotFound chunk first, got %v", got)
+	}
+	// filters reach both retrievers
+	for _, d := range retrieve(t, r, "ErrModelNotFound model", 4, vectorstores.WithFilter(vectorstores.Eq("language", "markdown"))) {
+		if d.Document.Metadata["language"] != "markdown" {
+			t.Errorf("unexpected document %v", d.Document)
+		}
+	}
+}
+
+func TestThresholdDropsWeakMatches(t *testing.T) {
+	base := ranking(map[string]float64{"a": 0.8, "b": 0.42, "c": 0.1}, "a", "b", "c")
+	if got := ids(retrieve(t, NewThreshold(base, 0.4), "q", 3)); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("expected a and b above 0.4, got %v", got)
+	}
+}
+
+func TestMMRSkipsNearDuplicates(t *testing.T) {
+	texts := map[string]string{
+		"a":  "ollama pulls models before running them",
+		"a2": "ollama pulls models before running them locally",
+		"b":  "chroma stores vectors in a collection",
+	}
+	base := ranking(map[string]float64{"a": 0.9, "a2": 0.88, "b": 0.6}, "a", "a2", "b")
+	for i := range base.ranking {
+		base.ranking[i].Document.PageContent = texts[base.ranking[i].Document.ID]
+	}
+	diverse, err := NewMMR(base, local.New(64), 0.5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(retrieve(t, diverse, "q", 2)); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("expected the near-duplicate to be skipped, got %v", got)
+	}
+	relevant, _ := NewMMR(base, local.New(64), 1, 0)
+	if got := ids(retrieve(t, relevant, "q", 2)); !slices.Equal(got, []string{"a", "a2"}) {
+		t.Errorf("expected lambda 1 to keep the base order, got %v", got)
+	}
+	if _, err := NewMMR(base, local.New(64), 2, 0); err == nil {
+		t.Error("expected a lambda above 1 to be rejected")
+	}
+}
+
+func TestLLMReranker(t *testing.T) {
+	base := ranking(nil, "a", "b", "c")
+	for i := range base.ranking {
+		base.ranking[i].Document.PageContent = "passage " + base.ranking[i].Document.ID
+	}
+	model := fake.Reply(`{"scores": [{"passage": 2, "relevance": 9}, {"passage": 1, "relevance": 3}]}`)
+	reranker, err := NewLLMReranker(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := retrieve(t, NewReranking(base, reranker, 0), "which passage?", 3)
+	// c was not graded and sinks to the bottom
+	if !slices.Equal(ids(got), []string{"b", "a", "c"}) || got[0].Score != 0.9 || got[2].Score != 0 {
+		t.Errorf("unexpected reranking %v", got)
+	}
+	prompt := model.LastRequest().Messages[0].Content
+	if !strings.Contains(prompt, "which passage?") || !strings.Contains(prompt, "[3] passage c") {
+		t.Errorf("expected the question and numbered passages in the prompt, got %q", prompt)
+	}
+
+	// a failing model leaves the retrieval order
+	down, _ := NewLLMReranker(fake.New(fake.Response{Err: errors.New("unavailable")}))
+	if got := ids(retrieve(t, NewReranking(base, down, 0), "q", 2)); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("expected the original order, got %v", got)
+	}
+}
@@ -3,12 +3,11 @@ package tools
 import (
 	"context"
 	"reflect"
+	"strings"
 )
 
 // GenInputSchema generates a JSON Schema for a struct type.
 func GenInputSchema(t reflect.Type) map[string]any {
-	properties := make(map[string]any)
-	required := []string{}
 	// Dereference pointer types if necessary
 	if t.Kind() == reflect.Pointer {
 		t = t.Elem()
@@ -16,36 +15,81 @@ func GenInputSchema(t reflect.Type) map[string]any {
 	if t.Kind() != reflect.Struct {
 		return nil
 	}
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		jsonTag := field.Tag.Get("json")
-		if jsonTag == "" || jsonTag == "-" {
-			continue
+	return SchemaOf(t)
+}
+
+// SchemaOf generates a JSON Schema for any Go type. Structs become objects whose
+// json-tagged fields are required unless marked omitempty, slices and arrays become
+// arrays and maps become objects. A `description` struct tag is copied into the schema.
+func SchemaOf(t reflect.Type) map[string]any {
+	return schemaOf(t, map[reflect.Type]bool{})
+}
+
+func schemaOf(t reflect.Type, seen map[reflect.Type]bool) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), seen)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Interface:
+		// any value
+		return map[string]any{}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string
+			return map[string]any{"type": "string"}
 		}
-		name := jsonTag
-		if commaIdx := findComma(jsonTag); commaIdx != -1 {
-			name = jsonTag[:commaIdx]
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), seen)}
+	case reflect.Map:
+		schema := map[string]any{"type": "object"}
+		if items := schemaOf(t.Elem(), seen); len(items) > 0 {
+			schema["additionalProperties"] = items
 		}
-		typemap := map[string]any{}
-		switch field.Type.Kind() {
-		case reflect.String:
-			typemap["type"] = "string"
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			typemap["type"] = "integer"
-		case reflect.Float32, reflect.Float64:
-			typemap["type"] = "number"
-		case reflect.Bool:
-			typemap["type"] = "boolean"
-		default:
-			typemap["type"] = "string"
+		return schema
+	case reflect.Struct:
+		if seen[t] {
+			// recursive type: stop descending
+			return map[string]any{"type": "object"}
 		}
-		properties[name] = typemap
-		required = append(required, name)
-	}
-	return map[string]any{
-		"type":       "object",
-		"properties": properties,
-		"required":   required,
+		seen[t] = true
+		defer delete(seen, t)
+		properties := make(map[string]any)
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			jsonTag := field.Tag.Get("json")
+			if jsonTag == "" || jsonTag == "-" {
+				continue
+			}
+			name := jsonTag
+			omitempty := false
+			if commaIdx := findComma(jsonTag); commaIdx != -1 {
+				name = jsonTag[:commaIdx]
+				omitempty = strings.Contains(jsonTag[commaIdx:], "omitempty")
+			}
+			typemap := schemaOf(field.Type, seen)
+			if desc := field.Tag.Get("description"); desc != "" {
+				typemap["description"] = desc
+			}
+			properties[name] = typemap
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+	default:
+		return map[string]any{"type": "string"}
 	}
 }
 
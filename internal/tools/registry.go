@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -151,11 +152,13 @@ func (r *Registry) AListTools(ctx context.Context) <-chan []*Tool {
 	return out
 }
 
+// toolPointers lists tools sorted by name, so prompts built from them are deterministic.
 func toolPointers(m map[string]*Tool) []*Tool {
 	list := make([]*Tool, 0, len(m))
 	for _, t := range m {
 		list = append(list, t)
 	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
 	return list
 }
 
@@ -40,6 +40,19 @@ func (t *Tool) HasDeprecated() bool {
 
 func (t *Tool) HasMetadata() bool { return t.Metadata != nil }
 
+// Parameters returns the tool's input schema for use in native function-calling APIs.
+// Tools without a schema are described as taking an empty object.
+func (t *Tool) Parameters() map[string]any {
+	if t.InputSchema != nil {
+		return t.InputSchema
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{},
+		"required":   []string{},
+	}
+}
+
 // New creates a new Tool from a Go function with a required name argument
 func New(name string, f any, description string) (*Tool, error) {
 	val := reflect.ValueOf(f)
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ValidateSchema checks a value decoded by encoding/json against a JSON Schema. It
+// supports the keywords SchemaOf produces (type, properties, required, items,
+// additionalProperties) plus enum, and returns one message per problem found.
+func ValidateSchema(schema map[string]any, value any) []string {
+	var problems []string
+	validate(schema, value, "$", &problems)
+	return problems
+}
+
+// ValidateJSON decodes data and validates it against schema.
+func ValidateJSON(schema map[string]any, data []byte) []string {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	return ValidateSchema(schema, value)
+}
+
+func validate(schema map[string]any, value any, path string, problems *[]string) {
+	if len(schema) == 0 {
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok && !inEnum(enum, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		return
+	}
+	typ, _ := schema["type"].(string)
+	if typ != "" && !hasType(typ, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, typ, jsonType(value)))
+		return
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range stringList(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop, ok := properties[k].(map[string]any); ok {
+				validate(prop, v[k], path+"."+k, problems)
+			} else if extra, ok := schema["additionalProperties"].(map[string]any); ok {
+				validate(extra, v[k], path+"."+k, problems)
+			} else if schema["additionalProperties"] == false {
+				*problems = append(*problems, fmt.Sprintf("%s: unexpected property %q", path, k))
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validate(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+func hasType(typ string, value any) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList accepts both []string (schemas built in Go) and []any (schemas decoded from JSON).
+func stringList(v any) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []any:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// FormatProblems joins validation problems into a bulleted list.
+func FormatProblems(problems []string) string {
+	return "- " + strings.Join(problems, "\n- ")
+}
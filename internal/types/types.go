@@ -13,16 +13,75 @@ const (
 	RoleUser      Role = "user"
 	RoleSystem    Role = "system"
 	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
 )
 
 // represents a single message in a conversation
 type ChatMessage struct {
 	Role    Role
 	Content string
+	// multimodal content sent after Content, e.g. images for vision models
+	Parts []ContentPart
+	// tool invocations requested by the model on an assistant message
+	ToolCalls []ToolCall
+	// for RoleTool messages: the call being answered and the tool's name
+	ToolCallID string
+	Name       string
+	// token usage reported by the provider for the call that produced this message
+	Usage *Usage
+	// extra details about how the message was produced, e.g. "provider"
+	Metadata map[string]any
+}
+
+// kind of a multimodal content part
+type PartType string
+
+const (
+	PartText  PartType = "text"
+	PartImage PartType = "image"
+	PartFile  PartType = "file"
+)
+
+// a piece of multimodal message content. Images and files carry either Data with its
+// MIMEType, or a URL (http(s) or data:).
+type ContentPart struct {
+	Type     PartType
+	Text     string
+	Data     []byte
+	URL      string
+	MIMEType string
+	// file name shown to the model for file parts
+	Name string
+}
+
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: PartText, Text: text}
+}
+
+func ImagePart(data []byte, mimeType string) ContentPart {
+	return ContentPart{Type: PartImage, Data: data, MIMEType: mimeType}
+}
+
+func ImageURLPart(url string) ContentPart {
+	return ContentPart{Type: PartImage, URL: url}
+}
+
+func FilePart(name string, data []byte, mimeType string) ContentPart {
+	return ContentPart{Type: PartFile, Name: name, Data: data, MIMEType: mimeType}
+}
+
+// token counts reported by a provider for one call
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
 }
 
 // represents a chunk of text from a source.
 type Document struct {
+	// ID identifies the document in a vector store; when empty, the store derives one
+	// from the source and content.
+	ID          string
 	PageContent string
 	Metadata    map[string]any
 }
@@ -57,40 +116,39 @@ type AgentCallResult struct {
 }
 
 type StateMessageMeta struct {
-	Current *StateMessage
-	Next *StateMessage
-	Previous *StateMessage
+	Current      *StateMessage
+	Next         *StateMessage
+	Previous     *StateMessage
 	CurrentState *state.AgentState
 }
 
 type StateMessage struct {
-	Id      string
-	Sender  Role
-	Message string
+	Id        string
+	Sender    Role
+	Message   string
 	Timestamp time.Time
-	Meta *StateMessageMeta
+	Meta      *StateMessageMeta
 }
 
+// a structured tool invocation returned by a model
 type ToolCall struct {
+	ID   string
 	Name string
 	Args map[string]any
 }
 
-
-
 type PipelineStep func(context.Context, any) (any, error)
 
 type NextStep func(context.Context, any) (*AgentCallResult, error)
 
 type EndStep func(context.Context, any) (*AgentCallResult, error)
 
-
 // Logging
 type LogLevel int
 
 const (
 	DEBUG LogLevel = -1
-	INFO LogLevel = iota
+	INFO  LogLevel = iota
 	WARNING
 	ERROR
 	FATAL
@@ -120,4 +178,4 @@ const (
 
 type TimeStamp struct {
 	time.Time
-}
\ No newline at end of file
+}
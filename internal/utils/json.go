@@ -1,6 +1,9 @@
 package utils
 
-import "strings"
+import (
+	"encoding/json"
+	"strings"
+)
 
 // extractJSONArray finds and returns the first valid JSON array from a string.
 func ExtractJSONArray(s string) string {
@@ -24,3 +27,49 @@ func ExtractJSONArray(s string) string {
 
 	return ""
 }
+
+// ExtractJSON returns the first valid JSON value in s that starts with open ('{' or '['),
+// skipping bracketed prose and ignoring brackets inside strings. It returns "" if none is found.
+func ExtractJSON(s string, open byte) string {
+	for start := strings.IndexByte(s, open); start != -1; {
+		if end := matchingBracket(s, start); end != -1 && json.Valid([]byte(s[start:end+1])) {
+			return s[start : end+1]
+		}
+		next := strings.IndexByte(s[start+1:], open)
+		if next == -1 {
+			break
+		}
+		start += next + 1
+	}
+	return ""
+}
+
+// matchingBracket returns the index of the bracket closing the one at start, or -1.
+func matchingBracket(s string, start int) int {
+	depth := 0
+	inString := false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
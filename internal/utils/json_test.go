@@ -43,4 +43,27 @@ func TestExtractJSONArray(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestExtractJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		open     byte
+		expected string
+	}{
+		{"Object in code fence", "```json\n{\"a\": [1, 2]}\n```", '{', `{"a": [1, 2]}`},
+		{"Skips bracketed prose", `[note] the plan is [{"tool":"x"}]`, '[', `[{"tool":"x"}]`},
+		{"Brackets inside strings", `{"text": "a } b ]"}`, '{', `{"text": "a } b ]"}`},
+		{"Escaped quote in string", `{"text": "say \"hi\" }"} trailing`, '{', `{"text": "say \"hi\" }"}`},
+		{"Unterminated", `{"a": 1`, '{', ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := ExtractJSON(tc.input, tc.open); result != tc.expected {
+				t.Errorf("ExtractJSON() = %q, want %q", result, tc.expected)
+			}
+		})
+	}
+}
@@ -2,22 +2,46 @@ package chroma
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gogurt/internal/config"
+	"gogurt/internal/embeddings"
 	ggtypes "gogurt/internal/types"
+	"gogurt/internal/vectorstores"
 	"strings"
+	"sync"
 
 	chromadb "github.com/amikos-tech/chroma-go/pkg/api/v2"
+	chromaemb "github.com/amikos-tech/chroma-go/pkg/embeddings"
 )
 
 type Store struct {
 	Client chromadb.Client
 	Col    chromadb.Collection
+	// space is the collection's distance function, used to turn distances into scores
+	space    string
+	cfg      *config.Config
+	embedder embeddings.Embedder
+	// model identifies the embedding model, as in factories.EmbeddingsModel
+	model string
+
+	mu sync.Mutex
+	// dimensions is the length of the collection's vectors, 0 until the first are added
+	dimensions int
 }
 
-// New creates a new ChromaDB client and gets or creates a collection.
-func New(cfg *config.Config) (*Store, error) {
-	store := &Store{}
+// Collection metadata recording which model embedded the stored vectors, so a collection
+// is never searched or extended with vectors from another model.
+const (
+	modelKey      = "embedding_model"
+	dimensionsKey = "embedding_dimensions"
+)
+
+// New creates a new ChromaDB client and gets or creates a collection. Documents and
+// queries are embedded with embedder and sent to Chroma as vectors; model identifies it,
+// and a collection embedded with another model, or by Chroma itself, is refused.
+func New(cfg *config.Config, embedder embeddings.Embedder, model string) (*Store, error) {
+	store := &Store{space: cfg.ChromaSpace, cfg: cfg, embedder: embedder, model: model}
 	client, err := chromadb.NewHTTPClient(
 		chromadb.WithBaseURL(cfg.ChromaURL),
 	)
@@ -26,25 +50,131 @@ func New(cfg *config.Config) (*Store, error) {
 	}
 	store.Client = client
 
-	col, err := client.GetOrCreateCollection(context.Background(), cfg.ChromaCollection,
-		chromadb.WithCollectionMetadataCreate(
-			chromadb.NewMetadata(
-				chromadb.NewStringAttribute("space", cfg.ChromaSpace),
-				chromadb.NewIntAttribute("ef_construction", int64(cfg.ChromaEFConstruction)),
-				chromadb.NewIntAttribute("ef_search", int64(cfg.ChromaEFSearch)),
-				chromadb.NewIntAttribute("max_neighbors", int64(cfg.ChromaMaxNeighbors)),
-			),
-		),
-	)
+	ctx := context.Background()
+	col, err := client.GetCollection(ctx, cfg.ChromaCollection, chromadb.WithEmbeddingFunctionGet(embeddingFunction{embedder}))
+	if err != nil {
+		col, err = store.CreateCollection(ctx, cfg.ChromaCollection, chromadb.WithIfNotExistsCreate())
+	}
 	if err != nil {
 		return nil, err
 	}
 	store.Col = col
+	if err := store.checkModel(ctx); err != nil {
+		return nil, err
+	}
 	return store, nil
 }
 
-// AddDocuments adds documents to the collection asynchronously.
+// CreateCollection creates a collection configured like the store's own, recording the
+// store's embedding model.
+func (s *Store) CreateCollection(ctx context.Context, name string, opts ...chromadb.CreateCollectionOption) (chromadb.Collection, error) {
+	opts = append([]chromadb.CreateCollectionOption{
+		chromadb.WithEmbeddingFunctionCreate(embeddingFunction{s.embedder}),
+		chromadb.WithCollectionMetadataCreate(
+			chromadb.NewMetadata(
+				chromadb.NewStringAttribute("space", s.cfg.ChromaSpace),
+				chromadb.NewIntAttribute("ef_construction", int64(s.cfg.ChromaEFConstruction)),
+				chromadb.NewIntAttribute("ef_search", int64(s.cfg.ChromaEFSearch)),
+				chromadb.NewIntAttribute("max_neighbors", int64(s.cfg.ChromaMaxNeighbors)),
+				chromadb.NewStringAttribute(modelKey, s.model),
+			),
+		),
+	}, opts...)
+	return s.Client.CreateCollection(ctx, name, opts...)
+}
+
+// checkModel refuses a collection holding vectors from another embedding model, and
+// loads the dimensions recorded for it.
+func (s *Store) checkModel(ctx context.Context) error {
+	if s.model == "" {
+		return nil
+	}
+	recorded, dimensions := embeddingModel(s.Col.Metadata())
+	switch {
+	case recorded == "":
+		n, err := s.Col.Count(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("collection %s holds %d documents embedded by Chroma's default embedding function, not %s; delete it or set CHROMA_COLLECTION to re-ingest", s.Col.Name(), n, s.model)
+		}
+	case recorded != s.model:
+		return fmt.Errorf("collection %s was embedded with %s, not %s; delete it or set CHROMA_COLLECTION to re-ingest", s.Col.Name(), recorded, s.model)
+	}
+	s.dimensions = dimensions
+	return nil
+}
+
+// embeddingModel returns the model and dimensions recorded in collection metadata.
+func embeddingModel(metadata chromadb.CollectionMetadata) (string, int) {
+	if metadata == nil {
+		return "", 0
+	}
+	model, _ := metadata.GetString(modelKey)
+	dimensions, _ := metadata.GetInt(dimensionsKey)
+	return model, int(dimensions)
+}
+
+// checkDimensions refuses vectors whose length differs from the collection's. The first
+// vectors added set the length, which is recorded in the collection metadata with the model.
+func (s *Store) checkDimensions(ctx context.Context, dimensions int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dimensions != 0 {
+		if dimensions != s.dimensions {
+			return fmt.Errorf("%s returned %d-dimensional vectors, but collection %s holds %d-dimensional ones", s.model, dimensions, s.Col.Name(), s.dimensions)
+		}
+		return nil
+	}
+	metadata := copyMetadata(s.Col.Metadata())
+	metadata.SetString(modelKey, s.model)
+	metadata.SetInt(dimensionsKey, int64(dimensions))
+	if err := s.Col.ModifyMetadata(ctx, metadata); err != nil {
+		return fmt.Errorf("failed to record the embedding model of collection %s: %w", s.Col.Name(), err)
+	}
+	s.dimensions = dimensions
+	return nil
+}
+
+// copyMetadata copies collection metadata, leaving out the index settings, which Chroma
+// does not allow to change once a collection exists.
+func copyMetadata(metadata chromadb.CollectionMetadata) chromadb.CollectionMetadata {
+	out := chromadb.NewMetadata()
+	if metadata == nil {
+		return out
+	}
+	for _, k := range metadata.Keys() {
+		if strings.HasPrefix(k, "hnsw:") {
+			continue
+		}
+		if v, ok := metadata.GetString(k); ok {
+			out.SetString(k, v)
+		} else if v, ok := metadata.GetInt(k); ok {
+			out.SetInt(k, v)
+		} else if v, ok := metadata.GetFloat(k); ok {
+			out.SetFloat(k, v)
+		} else if v, ok := metadata.GetBool(k); ok {
+			out.SetBool(k, v)
+		}
+	}
+	return out
+}
+
+// AddDocuments embeds documents and adds them to the collection asynchronously. Documents
+// whose ID is already in the collection are left unchanged, without being embedded. If
+// some documents fail to embed, the others are still added and the returned error is an
+// *embeddings.BatchError naming the failed ones.
 func (s *Store) AddDocuments(ctx context.Context, docs []ggtypes.Document) <-chan error {
+	return s.add(ctx, docs, false)
+}
+
+// Upsert embeds documents and adds them asynchronously, replacing documents with the same ID.
+func (s *Store) Upsert(ctx context.Context, docs []ggtypes.Document) <-chan error {
+	return s.add(ctx, docs, true)
+}
+
+func (s *Store) add(ctx context.Context, docs []ggtypes.Document, replace bool) <-chan error {
 	errCh := make(chan error, 1)
 	go func() {
 		defer close(errCh)
@@ -52,100 +182,446 @@ func (s *Store) AddDocuments(ctx context.Context, docs []ggtypes.Document) <-cha
 			errCh <- fmt.Errorf("collection not initialized")
 			return
 		}
+		docs = unique(docs)
+		if !replace && len(docs) > 0 {
+			var err error
+			if docs, err = s.missing(ctx, docs); err != nil {
+				errCh <- err
+				return
+			}
+		}
+		if len(docs) == 0 {
+			errCh <- nil
+			return
+		}
 
-		ids := make([]string, len(docs))
-		texts := make([]string, len(docs))
-		metadatas := make([]chromadb.DocumentMetadata, len(docs))
-
-		for i, d := range docs {
-			ids[i] = fmt.Sprintf("doc-%d", i)
-			texts[i] = d.PageContent
-			if d.Metadata != nil {
-				var attrs []*chromadb.MetaAttribute
-				var keys []string
-				for k, v := range d.Metadata {
-					keys = append(keys, k)
-					switch val := v.(type) {
-					case string:
-						attrs = append(attrs, chromadb.NewStringAttribute(k, val))
-					case int:
-						attrs = append(attrs, chromadb.NewIntAttribute(k, int64(val)))
-					case float64:
-						attrs = append(attrs, chromadb.NewFloatAttribute(k, val))
-					default:
-						attrs = append(attrs, chromadb.NewStringAttribute(k, fmt.Sprintf("%v", val)))
-					}
-				}
-				attrs = append(attrs, chromadb.NewStringAttribute("__keys__", strings.Join(keys, ",")))
-				metadatas[i] = chromadb.NewDocumentMetadata(attrs...)
-			} else {
-				metadatas[i] = chromadb.NewDocumentMetadata()
+		vectors, embedErr := s.embedder.EmbedAll(ctx, docs, embeddings.DefaultWorkers)
+		var batchErr *embeddings.BatchError
+		if embedErr != nil && !errors.As(embedErr, &batchErr) {
+			errCh <- embedErr
+			return
+		}
+		var embedded []ggtypes.Document
+		var vecs []chromaemb.Embedding
+		for i, vector := range vectors {
+			if vector != nil {
+				embedded = append(embedded, docs[i])
+				vecs = append(vecs, chromaemb.NewEmbeddingFromFloat32(vector))
 			}
 		}
+		if len(embedded) == 0 {
+			errCh <- embedErr
+			return
+		}
+		if err := s.checkDimensions(ctx, vecs[0].Len()); err != nil {
+			errCh <- err
+			return
+		}
 
-		err := s.Col.Add(ctx,
-			chromadb.WithIDGenerator(chromadb.NewULIDGenerator()),
+		ids, texts, metadatas := toRecords(embedded)
+		opts := []chromadb.CollectionAddOption{
+			chromadb.WithIDs(ids...),
 			chromadb.WithTexts(texts...),
 			chromadb.WithMetadatas(metadatas...),
-		)
-		errCh <- err
+			chromadb.WithEmbeddings(vecs...),
+		}
+		var err error
+		if replace {
+			err = s.Col.Upsert(ctx, opts...)
+		} else {
+			err = s.Col.Add(ctx, opts...)
+		}
+		if err != nil {
+			errCh <- err
+			return
+		}
+		errCh <- embedErr
 	}()
 	return errCh
 }
 
-// SimilaritySearch performs a query asynchronously.
-func (s *Store) SimilaritySearch(ctx context.Context, query string, k int) (<-chan []ggtypes.Document, <-chan error) {
-	out := make(chan []ggtypes.Document, 1)
+// missing returns the docs whose IDs are not in the collection yet.
+func (s *Store) missing(ctx context.Context, docs []ggtypes.Document) ([]ggtypes.Document, error) {
+	ids := make([]chromadb.DocumentID, len(docs))
+	for i, d := range docs {
+		ids[i] = chromadb.DocumentID(d.ID)
+	}
+	existing, err := s.Col.Get(ctx, chromadb.WithIDsGet(ids...))
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[chromadb.DocumentID]bool)
+	for _, id := range existing.GetIDs() {
+		stored[id] = true
+	}
+	var out []ggtypes.Document
+	for i, d := range docs {
+		if !stored[ids[i]] {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// Delete removes the documents with the given IDs asynchronously.
+func (s *Store) Delete(ctx context.Context, ids []string) <-chan error {
 	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		if s.Col == nil {
+			errCh <- fmt.Errorf("collection not initialized")
+			return
+		}
+		if len(ids) == 0 {
+			errCh <- nil
+			return
+		}
+		docIDs := make([]chromadb.DocumentID, len(ids))
+		for i, id := range ids {
+			docIDs[i] = chromadb.DocumentID(id)
+		}
+		errCh <- s.Col.Delete(ctx, chromadb.WithIDsDelete(docIDs...))
+	}()
+	return errCh
+}
 
+// DeleteWhere removes the documents whose metadata has all the given values asynchronously.
+func (s *Store) DeleteWhere(ctx context.Context, metadata map[string]any) <-chan error {
+	errCh := make(chan error, 1)
 	go func() {
-		defer close(out)
 		defer close(errCh)
 		if s.Col == nil {
 			errCh <- fmt.Errorf("collection not initialized")
 			return
 		}
+		if len(metadata) == 0 {
+			errCh <- vectorstores.ErrNoFilter
+			return
+		}
+		errCh <- s.Col.Delete(ctx, chromadb.WithWhereDelete(whereEqual(metadata)))
+	}()
+	return errCh
+}
+
+// whereEqual matches documents whose metadata has all the given values.
+func whereEqual(metadata map[string]any) chromadb.WhereClause {
+	var clauses []chromadb.WhereClause
+	for k, v := range metadata {
+		clauses = append(clauses, eq(k, v))
+	}
+	if len(clauses) == 1 {
+		return clauses[0]
+	}
+	return chromadb.And(clauses...)
+}
 
-		resp, err := s.Col.Query(ctx,
-			chromadb.WithQueryTexts(query),
-			chromadb.WithNResults(k))
+func eq(field string, value any) chromadb.WhereClause {
+	switch val := value.(type) {
+	case string:
+		return chromadb.EqString(field, val)
+	case int:
+		return chromadb.EqInt(field, val)
+	case float64:
+		return chromadb.EqFloat(field, float32(val))
+	case bool:
+		return chromadb.EqBool(field, val)
+	default:
+		return chromadb.EqString(field, fmt.Sprintf("%v", val))
+	}
+}
+
+// toWhere translates a filter to a Chroma where clause.
+func toWhere(f *vectorstores.Filter) (chromadb.WhereClause, error) {
+	switch f.Op {
+	case vectorstores.OpEq:
+		return eq(f.Field, f.Values[0]), nil
+	case vectorstores.OpIn:
+		return in(f.Field, f.Values), nil
+	case vectorstores.OpRange:
+		var clauses []chromadb.WhereClause
+		if f.Min != nil {
+			c, err := bound(f.Field, f.Min, chromadb.GteInt, chromadb.GteFloat)
+			if err != nil {
+				return nil, err
+			}
+			clauses = append(clauses, c)
+		}
+		if f.Max != nil {
+			c, err := bound(f.Field, f.Max, chromadb.LteInt, chromadb.LteFloat)
+			if err != nil {
+				return nil, err
+			}
+			clauses = append(clauses, c)
+		}
+		return combine(chromadb.And, clauses)
+	case vectorstores.OpAnd, vectorstores.OpOr:
+		var clauses []chromadb.WhereClause
+		for _, sub := range f.Filters {
+			c, err := toWhere(sub)
+			if err != nil {
+				return nil, err
+			}
+			clauses = append(clauses, c)
+		}
+		if f.Op == vectorstores.OpAnd {
+			return combine(chromadb.And, clauses)
+		}
+		return combine(chromadb.Or, clauses)
+	}
+	return nil, fmt.Errorf("unsupported filter operation %q", f.Op)
+}
+
+// combine joins clauses, which Chroma requires to be at least two for $and and $or.
+func combine(join func(...chromadb.WhereClause) chromadb.WhereClause, clauses []chromadb.WhereClause) (chromadb.WhereClause, error) {
+	switch len(clauses) {
+	case 0:
+		return nil, fmt.Errorf("empty filter")
+	case 1:
+		return clauses[0], nil
+	}
+	return join(clauses...), nil
+}
+
+// in uses Chroma's typed $in when all values share a type, and an $or of equalities otherwise.
+func in(field string, values []any) chromadb.WhereClause {
+	var strs []string
+	var ints []int
+	for _, v := range values {
+		switch val := v.(type) {
+		case string:
+			strs = append(strs, val)
+		case int:
+			ints = append(ints, val)
+		}
+	}
+	switch {
+	case len(strs) == len(values):
+		return chromadb.InString(field, strs...)
+	case len(ints) == len(values):
+		return chromadb.InInt(field, ints...)
+	}
+	clauses := make([]chromadb.WhereClause, len(values))
+	for i, v := range values {
+		clauses[i] = eq(field, v)
+	}
+	if len(clauses) == 1 {
+		return clauses[0]
+	}
+	return chromadb.Or(clauses...)
+}
+
+func bound(field string, value any, withInt func(string, int) chromadb.WhereClause, withFloat func(string, float32) chromadb.WhereClause) (chromadb.WhereClause, error) {
+	switch val := value.(type) {
+	case int:
+		return withInt(field, val), nil
+	case int64:
+		return withInt(field, int(val)), nil
+	case float64:
+		return withFloat(field, float32(val)), nil
+	case float32:
+		return withFloat(field, val), nil
+	}
+	return nil, fmt.Errorf("filter on %s: range bound %v is not a number", field, value)
+}
+
+// Count returns the number of documents in the collection asynchronously.
+func (s *Store) Count(ctx context.Context) (<-chan int, <-chan error) {
+	out := make(chan int, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		if s.Col == nil {
+			errCh <- fmt.Errorf("collection not initialized")
+			return
+		}
+		n, err := s.Col.Count(ctx)
 		if err != nil {
 			errCh <- err
 			return
 		}
+		out <- n
+	}()
+	return out, errCh
+}
 
-		textsGroups := resp.GetDocumentsGroups()
-		metadatasGroups := resp.GetMetadatasGroups()
-		var docs []ggtypes.Document
+// SimilaritySearch performs a query asynchronously.
+func (s *Store) SimilaritySearch(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []ggtypes.Document, <-chan error) {
+	out := make(chan []ggtypes.Document, 1)
+	errCh := make(chan error, 1)
 
-		for groupIdx, documents := range textsGroups {
-			var metadatas []chromadb.DocumentMetadata
-			if groupIdx < len(metadatasGroups) {
-				metadatas = metadatasGroups[groupIdx]
-			}
-			for idx, doc := range documents {
-				var metadata map[string]any
-				if metadatas != nil && idx < len(metadatas) {
-					md := make(map[string]any)
-					keysStr, ok := metadatas[idx].GetString("__keys__")
-					if ok {
-						keys := strings.Split(keysStr, ",")
-						for _, key := range keys {
-							if val, ok := metadatas[idx].GetRaw(key); ok {
-								md[key] = val
-							}
-						}
-					}
-					metadata = md
-				}
-				docs = append(docs, ggtypes.Document{
-					PageContent: doc.ContentString(),
-					Metadata:    metadata,
-				})
-			}
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		scored, err := s.query(ctx, query, k, vectorstores.NewSearchOptions(opts...))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		var docs []ggtypes.Document
+		for _, d := range scored {
+			docs = append(docs, d.Document)
 		}
 		out <- docs
 	}()
 
 	return out, errCh
-}
\ No newline at end of file
+}
+
+// SimilaritySearchWithScore performs a query asynchronously, converting Chroma's distances
+// to similarities: 1-d for cosine and inner product, and 1-d/2 for squared L2, which is
+// the cosine similarity of normalized vectors.
+func (s *Store) SimilaritySearchWithScore(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []vectorstores.ScoredDocument, <-chan error) {
+	out := make(chan []vectorstores.ScoredDocument, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		scored, err := s.query(ctx, query, k, vectorstores.NewSearchOptions(opts...))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		out <- scored
+	}()
+	return out, errCh
+}
+
+func (s *Store) query(ctx context.Context, query string, k int, o vectorstores.SearchOptions) ([]vectorstores.ScoredDocument, error) {
+	if s.Col == nil {
+		return nil, fmt.Errorf("collection not initialized")
+	}
+	vector, err := s.embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	s.mu.Lock()
+	dimensions := s.dimensions
+	s.mu.Unlock()
+	if dimensions != 0 && len(vector) != dimensions {
+		return nil, fmt.Errorf("%s returned a %d-dimensional query vector, but collection %s holds %d-dimensional ones", s.model, len(vector), s.Col.Name(), dimensions)
+	}
+	queryOpts := []chromadb.CollectionQueryOption{
+		chromadb.WithQueryEmbeddings(chromaemb.NewEmbeddingFromFloat32(vector)),
+		chromadb.WithNResults(k),
+	}
+	if o.Filter != nil {
+		where, err := toWhere(o.Filter)
+		if err != nil {
+			return nil, err
+		}
+		queryOpts = append(queryOpts, chromadb.WithWhereQuery(where))
+	}
+	resp, err := s.Col.Query(ctx, queryOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	idGroups := resp.GetIDGroups()
+	textsGroups := resp.GetDocumentsGroups()
+	metadatasGroups := resp.GetMetadatasGroups()
+	distancesGroups := resp.GetDistancesGroups()
+	var docs []vectorstores.ScoredDocument
+
+	for groupIdx, documents := range textsGroups {
+		var metadatas []chromadb.DocumentMetadata
+		if groupIdx < len(metadatasGroups) {
+			metadatas = metadatasGroups[groupIdx]
+		}
+		for idx, doc := range documents {
+			d := ggtypes.Document{PageContent: doc.ContentString()}
+			if groupIdx < len(idGroups) && idx < len(idGroups[groupIdx]) {
+				d.ID = string(idGroups[groupIdx][idx])
+			}
+			if metadatas != nil && idx < len(metadatas) {
+				d.Metadata = fromMetadata(metadatas[idx])
+			}
+			var score float64
+			if groupIdx < len(distancesGroups) && idx < len(distancesGroups[groupIdx]) {
+				score = s.similarity(float64(distancesGroups[groupIdx][idx]))
+			}
+			docs = append(docs, vectorstores.ScoredDocument{Document: d, Score: score})
+		}
+	}
+	return docs, nil
+}
+
+func (s *Store) similarity(distance float64) float64 {
+	if s.space == "l2" {
+		return 1 - distance/2
+	}
+	return 1 - distance
+}
+
+// unique returns docs with their IDs set, dropping repeated IDs, which Chroma rejects
+// within a request.
+func unique(docs []ggtypes.Document) []ggtypes.Document {
+	out := make([]ggtypes.Document, 0, len(docs))
+	seen := make(map[string]bool, len(docs))
+	for _, d := range docs {
+		d.ID = vectorstores.DocumentID(d)
+		if seen[d.ID] {
+			continue
+		}
+		seen[d.ID] = true
+		out = append(out, d)
+	}
+	return out
+}
+
+// toRecords converts docs, whose IDs are set, to Chroma IDs, texts and metadatas.
+func toRecords(docs []ggtypes.Document) ([]chromadb.DocumentID, []string, []chromadb.DocumentMetadata) {
+	ids := make([]chromadb.DocumentID, len(docs))
+	texts := make([]string, len(docs))
+	metadatas := make([]chromadb.DocumentMetadata, len(docs))
+	for i, d := range docs {
+		ids[i] = chromadb.DocumentID(d.ID)
+		texts[i] = d.PageContent
+		metadatas[i] = toMetadata(d.Metadata)
+	}
+	return ids, texts, metadatas
+}
+
+// toMetadata converts metadata to Chroma attributes, recording the keys under __keys__.
+func toMetadata(metadata map[string]any) chromadb.DocumentMetadata {
+	if metadata == nil {
+		return chromadb.NewDocumentMetadata()
+	}
+	var attrs []*chromadb.MetaAttribute
+	var keys []string
+	for k, v := range metadata {
+		keys = append(keys, k)
+		switch val := v.(type) {
+		case string:
+			attrs = append(attrs, chromadb.NewStringAttribute(k, val))
+		case int:
+			attrs = append(attrs, chromadb.NewIntAttribute(k, int64(val)))
+		case int64:
+			attrs = append(attrs, chromadb.NewIntAttribute(k, val))
+		case float64:
+			attrs = append(attrs, chromadb.NewFloatAttribute(k, val))
+		case float32:
+			attrs = append(attrs, chromadb.NewFloatAttribute(k, float64(val)))
+		case bool:
+			attrs = append(attrs, chromadb.NewBoolAttribute(k, val))
+		default:
+			attrs = append(attrs, chromadb.NewStringAttribute(k, fmt.Sprintf("%v", val)))
+		}
+	}
+	attrs = append(attrs, chromadb.NewStringAttribute("__keys__", strings.Join(keys, ",")))
+	return chromadb.NewDocumentMetadata(attrs...)
+}
+
+func fromMetadata(metadata chromadb.DocumentMetadata) map[string]any {
+	md := make(map[string]any)
+	keysStr, ok := metadata.GetString("__keys__")
+	if ok {
+		keys := strings.Split(keysStr, ",")
+		for _, key := range keys {
+			if val, ok := metadata.GetRaw(key); ok {
+				md[key] = val
+			}
+		}
+	}
+	return md
+}
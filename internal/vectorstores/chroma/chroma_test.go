@@ -0,0 +1,154 @@
+package chroma
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gogurt/internal/embeddings/local"
+	ggtypes "gogurt/internal/types"
+	"gogurt/internal/vectorstores"
+
+	chromadb "github.com/amikos-tech/chroma-go/pkg/api/v2"
+)
+
+// fakeCollection records what the store sends; methods the store does not call panic.
+type fakeCollection struct {
+	chromadb.Collection
+	metadata chromadb.CollectionMetadata
+	count    int
+	stored   []chromadb.DocumentID
+	added    *chromadb.CollectionAddOp
+}
+
+func (f *fakeCollection) Name() string                          { return "test" }
+func (f *fakeCollection) Metadata() chromadb.CollectionMetadata { return f.metadata }
+func (f *fakeCollection) Count(ctx context.Context) (int, error) {
+	return f.count, nil
+}
+
+func (f *fakeCollection) ModifyMetadata(ctx context.Context, metadata chromadb.CollectionMetadata) error {
+	f.metadata = metadata
+	return nil
+}
+
+func (f *fakeCollection) Get(ctx context.Context, opts ...chromadb.CollectionGetOption) (chromadb.GetResult, error) {
+	return &chromadb.GetResultImpl{Ids: f.stored}, nil
+}
+
+func (f *fakeCollection) Add(ctx context.Context, opts ...chromadb.CollectionAddOption) error {
+	op, err := chromadb.NewCollectionAddOp(opts...)
+	f.added = op
+	return err
+}
+
+func newStore(col *fakeCollection, model string) *Store {
+	return &Store{Col: col, embedder: local.New(16), model: model}
+}
+
+func TestAddSendsVectorsAndRecordsModel(t *testing.T) {
+	col := &fakeCollection{metadata: chromadb.NewMetadata(chromadb.NewStringAttribute("space", "cosine"), chromadb.NewStringAttribute(modelKey, "local:hashing"))}
+	s := newStore(col, "local:hashing")
+	if err := s.checkModel(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	docs := []ggtypes.Document{{PageContent: "ollama runs models"}, {PageContent: "chroma stores vectors"}, {PageContent: "ollama runs models"}}
+	if err := <-s.AddDocuments(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+	if col.added == nil || len(col.added.Ids) != 2 || len(col.added.Embeddings) != 2 {
+		t.Fatalf("expected two deduplicated documents sent with vectors, got %+v", col.added)
+	}
+	model, dimensions := embeddingModel(col.metadata)
+	if model != "local:hashing" || dimensions != 16 {
+		t.Errorf("expected the model and dimensions to be recorded, got %s and %d", model, dimensions)
+	}
+	if space, _ := col.metadata.GetString("space"); space != "cosine" {
+		t.Errorf("expected other metadata to be kept, got %v", col.metadata.Keys())
+	}
+
+	// vectors of another length are refused
+	s.embedder = local.New(8)
+	if err := <-s.Upsert(context.Background(), docs); err == nil || !strings.Contains(err.Error(), "16-dimensional") {
+		t.Errorf("expected a dimension mismatch, got %v", err)
+	}
+}
+
+func TestAddSkipsStoredDocuments(t *testing.T) {
+	col := &fakeCollection{}
+	s := newStore(col, "local:hashing")
+	docs := []ggtypes.Document{{ID: "a", PageContent: "one"}, {ID: "b", PageContent: "two"}}
+	col.stored = []chromadb.DocumentID{"a", "b"}
+	if err := <-s.AddDocuments(context.Background(), docs); err != nil || col.added != nil {
+		t.Fatalf("expected nothing to be embedded or added, got %v, %+v", err, col.added)
+	}
+}
+
+func TestRefusesOtherModels(t *testing.T) {
+	ctx := context.Background()
+	other := &fakeCollection{metadata: chromadb.NewMetadata(chromadb.NewStringAttribute(modelKey, "openai:text-embedding-3-small"))}
+	if err := newStore(other, "ollama:nomic-embed-text").checkModel(ctx); err == nil || !strings.Contains(err.Error(), "openai:text-embedding-3-small") {
+		t.Errorf("expected a model mismatch, got %v", err)
+	}
+	// a collection Chroma embedded itself can only be adopted while empty
+	legacy := &fakeCollection{metadata: chromadb.NewMetadata(), count: 3}
+	if err := newStore(legacy, "ollama:nomic-embed-text").checkModel(ctx); err == nil || !strings.Contains(err.Error(), "default embedding function") {
+		t.Errorf("expected a populated legacy collection to be refused, got %v", err)
+	}
+	legacy.count = 0
+	if err := newStore(legacy, "ollama:nomic-embed-text").checkModel(ctx); err != nil {
+		t.Errorf("expected an empty collection to be adopted, got %v", err)
+	}
+}
+
+func TestToWhere(t *testing.T) {
+	tests := []struct {
+		filter *vectorstores.Filter
+		want   string
+	}{
+		{vectorstores.Eq("draft", true), `{"draft":{"$eq":true}}`},
+		{vectorstores.In("lang", "go", "rust"), `{"lang":{"$in":["go","rust"]}}`},
+		{vectorstores.In("page", 1, 2), `{"page":{"$in":[1,2]}}`},
+		{vectorstores.In("tag", "go", 2), `{"$or":[{"tag":{"$eq":"go"}},{"tag":{"$eq":2}}]}`},
+		{vectorstores.Range("page", 1, 2.5), `{"$and":[{"page":{"$gte":1}},{"page":{"$lte":2.5}}]}`},
+		{vectorstores.Range("page", nil, 3), `{"page":{"$lte":3}}`},
+		{
+			vectorstores.And(vectorstores.Eq("lang", "go"), vectorstores.Or(vectorstores.Eq("page", 1), vectorstores.Eq("draft", false))),
+			`{"$and":[{"lang":{"$eq":"go"}},{"$or":[{"page":{"$eq":1}},{"draft":{"$eq":false}}]}]}`,
+		},
+	}
+	for _, tt := range tests {
+		where, err := toWhere(tt.filter)
+		if err != nil {
+			t.Errorf("%s: %v", tt.filter, err)
+			continue
+		}
+		got, err := where.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+	if _, err := toWhere(vectorstores.Range("page", "one", nil)); err == nil {
+		t.Error("expected an error for a range bound that is not a number")
+	}
+}
+
+func TestWhereEqualMatchesStoredTypes(t *testing.T) {
+	metadata := toMetadata(map[string]any{"draft": true, "page": 3, "source": "a.md"})
+	if v, ok := metadata.GetBool("draft"); !ok || !v {
+		t.Errorf("expected draft stored as a bool, got %v %v", v, ok)
+	}
+	if v, ok := metadata.GetInt("page"); !ok || v != 3 {
+		t.Errorf("expected page stored as an int, got %v %v", v, ok)
+	}
+	got, err := whereEqual(map[string]any{"draft": true}).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"draft":{"$eq":true}}` {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,37 @@
+package chroma
+
+import (
+	"context"
+
+	"gogurt/internal/embeddings"
+	ggtypes "gogurt/internal/types"
+
+	chromaemb "github.com/amikos-tech/chroma-go/pkg/embeddings"
+)
+
+// embeddingFunction adapts a gogurt embedder to chroma-go, which otherwise falls back to
+// Chroma's default model whenever a collection is opened without one. The store sends
+// vectors itself, so this only covers texts chroma-go would embed on its own.
+type embeddingFunction struct {
+	embedder embeddings.Embedder
+}
+
+func (f embeddingFunction) EmbedDocuments(ctx context.Context, texts []string) ([]chromaemb.Embedding, error) {
+	docs := make([]ggtypes.Document, len(texts))
+	for i, text := range texts {
+		docs[i] = ggtypes.Document{PageContent: text}
+	}
+	vectors, err := f.embedder.EmbedDocuments(ctx, docs)
+	if err != nil {
+		return nil, err
+	}
+	return chromaemb.NewEmbeddingsFromFloat32(vectors)
+}
+
+func (f embeddingFunction) EmbedQuery(ctx context.Context, text string) (chromaemb.Embedding, error) {
+	vector, err := f.embedder.EmbedQuery(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return chromaemb.NewEmbeddingFromFloat32(vector), nil
+}
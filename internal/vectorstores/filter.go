@@ -0,0 +1,285 @@
+package vectorstores
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilterOp is the kind of a Filter.
+type FilterOp string
+
+const (
+	OpEq    FilterOp = "eq"
+	OpIn    FilterOp = "in"
+	OpRange FilterOp = "range"
+	OpAnd   FilterOp = "and"
+	OpOr    FilterOp = "or"
+)
+
+// Filter restricts a search to documents whose metadata matches it. Build one with Eq,
+// In, Range, And and Or, or parse one with ParseFilter. Stores that cannot evaluate a
+// filter natively apply Matches to each document.
+type Filter struct {
+	Op    FilterOp
+	Field string
+	// Values holds the value for OpEq and the candidates for OpIn.
+	Values []any
+	// Min and Max bound OpRange inclusively; nil leaves that side open. Bounds are numbers.
+	Min, Max any
+	// Filters are the operands of OpAnd and OpOr.
+	Filters []*Filter
+}
+
+// Eq matches documents whose field equals value.
+func Eq(field string, value any) *Filter {
+	return &Filter{Op: OpEq, Field: field, Values: []any{value}}
+}
+
+// In matches documents whose field equals one of values.
+func In(field string, values ...any) *Filter {
+	return &Filter{Op: OpIn, Field: field, Values: values}
+}
+
+// Range matches documents whose numeric field is between lo and hi, inclusive. A nil
+// bound is open.
+func Range(field string, lo, hi any) *Filter {
+	return &Filter{Op: OpRange, Field: field, Min: lo, Max: hi}
+}
+
+// And matches documents matching all filters.
+func And(filters ...*Filter) *Filter {
+	return &Filter{Op: OpAnd, Filters: filters}
+}
+
+// Or matches documents matching any of filters.
+func Or(filters ...*Filter) *Filter {
+	return &Filter{Op: OpOr, Filters: filters}
+}
+
+// Matches evaluates the filter against metadata. A nil filter matches everything.
+func (f *Filter) Matches(metadata map[string]any) bool {
+	if f == nil {
+		return true
+	}
+	switch f.Op {
+	case OpEq, OpIn:
+		got, ok := metadata[f.Field]
+		if !ok {
+			return false
+		}
+		for _, want := range f.Values {
+			if equal(got, want) {
+				return true
+			}
+		}
+		return false
+	case OpRange:
+		got, ok := toFloat(metadata[f.Field])
+		if !ok {
+			return false
+		}
+		if lo, ok := toFloat(f.Min); ok && got < lo {
+			return false
+		}
+		if hi, ok := toFloat(f.Max); ok && got > hi {
+			return false
+		}
+		return true
+	case OpAnd:
+		for _, sub := range f.Filters {
+			if !sub.Matches(metadata) {
+				return false
+			}
+		}
+		return true
+	case OpOr:
+		for _, sub := range f.Filters {
+			if sub.Matches(metadata) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// equal compares metadata values by number when both are numbers, since stores may read
+// back an int as a float, and by string form otherwise.
+func equal(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			return x == y
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	switch f.Op {
+	case OpEq:
+		return fmt.Sprintf("%s=%v", f.Field, f.Values[0])
+	case OpIn:
+		values := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			values[i] = fmt.Sprint(v)
+		}
+		return fmt.Sprintf("%s=%s", f.Field, strings.Join(values, "|"))
+	case OpRange:
+		var parts []string
+		if f.Min != nil {
+			parts = append(parts, fmt.Sprintf("%s>=%v", f.Field, f.Min))
+		}
+		if f.Max != nil {
+			parts = append(parts, fmt.Sprintf("%s<=%v", f.Field, f.Max))
+		}
+		return strings.Join(parts, " and ")
+	case OpAnd, OpOr:
+		parts := make([]string, len(f.Filters))
+		for i, sub := range f.Filters {
+			parts[i] = sub.String()
+		}
+		return strings.Join(parts, " "+string(f.Op)+" ")
+	}
+	return ""
+}
+
+// ParseFilter parses a filter such as
+//
+//	language=go
+//	ext=md|txt and dir=docs/design
+//	modified>=2025-01-01 or source=README.md
+//
+// Clauses are field=value, field=a|b (any of), field>=value and field<=value, joined by
+// "and", which binds tighter than "or". Integers and floats become numbers, and dates
+// (2006-01-02) become Unix seconds to compare with the "modified" metadata.
+func ParseFilter(s string) (*Filter, error) {
+	var alternatives []*Filter
+	for _, alternative := range splitWord(s, "or") {
+		var all []*Filter
+		for _, clause := range splitWord(alternative, "and") {
+			f, err := parseClause(clause)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, f)
+		}
+		alternatives = append(alternatives, and(all))
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return Or(alternatives...), nil
+}
+
+func and(filters []*Filter) *Filter {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return And(filters...)
+}
+
+// splitWord splits s around the standalone word sep.
+func splitWord(s, sep string) []string {
+	var parts []string
+	var current []string
+	for _, field := range strings.Fields(s) {
+		if strings.EqualFold(field, sep) {
+			parts = append(parts, strings.Join(current, " "))
+			current = nil
+			continue
+		}
+		current = append(current, field)
+	}
+	return append(parts, strings.Join(current, " "))
+}
+
+func parseClause(clause string) (*Filter, error) {
+	for _, op := range []string{">=", "<=", "="} {
+		field, value, ok := strings.Cut(clause, op)
+		if !ok {
+			continue
+		}
+		field, value = strings.TrimSpace(field), strings.TrimSpace(value)
+		if field == "" || value == "" {
+			break
+		}
+		switch op {
+		case ">=", "<=":
+			bound := parseValue(value)
+			if _, ok := toFloat(bound); !ok {
+				return nil, fmt.Errorf("filter %q: %s needs a number or a date", clause, op)
+			}
+			if op == ">=" {
+				return Range(field, bound, nil), nil
+			}
+			return Range(field, nil, bound), nil
+		default:
+			var values []any
+			for _, v := range strings.Split(value, "|") {
+				values = append(values, parseValue(strings.TrimSpace(v)))
+			}
+			if len(values) == 1 {
+				return Eq(field, values[0]), nil
+			}
+			return In(field, values...), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid filter clause %q, expected field=value, field>=value or field<=value", clause)
+}
+
+func parseValue(s string) any {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return int(t.Unix())
+	}
+	return s
+}
+
+// SearchOptions holds per-call similarity search settings.
+type SearchOptions struct {
+	// Filter restricts results to documents whose metadata matches it.
+	Filter *Filter
+}
+
+// SearchOption mutates SearchOptions for a single search.
+type SearchOption func(*SearchOptions)
+
+// WithFilter restricts a search to documents matching f; nil means no restriction.
+func WithFilter(f *Filter) SearchOption {
+	return func(o *SearchOptions) { o.Filter = f }
+}
+
+// NewSearchOptions applies opts to the zero SearchOptions.
+func NewSearchOptions(opts ...SearchOption) SearchOptions {
+	var o SearchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,49 @@
+package vectorstores
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	jan := int(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Unix())
+	goFile := map[string]any{"language": "go", "ext": "go", "dir": "internal/llm", "modified": jan + 60}
+	oldDoc := map[string]any{"language": "markdown", "ext": "md", "dir": "docs/design", "modified": float64(jan - 60)}
+
+	cases := []struct {
+		expr        string
+		goOK, oldOK bool
+	}{
+		{"language=go", true, false},
+		{"ext=md|txt", false, true},
+		{"ext=md|txt and dir=docs/design", false, true},
+		{"modified>=2025-01-01", true, false},
+		{"modified<=2025-01-01 or language=go", true, true},
+		{"language=go and modified<=2025-01-01", false, false},
+	}
+	for _, tc := range cases {
+		f, err := ParseFilter(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.expr, err)
+		}
+		if got := f.Matches(goFile); got != tc.goOK {
+			t.Errorf("%q on the Go file: got %v", tc.expr, got)
+		}
+		if got := f.Matches(oldDoc); got != tc.oldOK {
+			t.Errorf("%q on the design doc: got %v", tc.expr, got)
+		}
+	}
+
+	for _, bad := range []string{"language", "=go", "modified>=soon", "language=go and"} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestNilFilterMatchesEverything(t *testing.T) {
+	var f *Filter
+	if !f.Matches(nil) || NewSearchOptions().Filter != nil {
+		t.Error("expected a nil filter to match")
+	}
+}
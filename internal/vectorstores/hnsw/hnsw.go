@@ -0,0 +1,405 @@
+// Package hnsw is an in-process approximate nearest-neighbor index over cosine
+// similarity, after Malkov and Yashunin, "Efficient and robust approximate nearest
+// neighbor search using Hierarchical Navigable Small World graphs".
+package hnsw
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+)
+
+// Defaults mirror Chroma's HNSW settings.
+const (
+	DefaultM              = 16
+	DefaultEfConstruction = 100
+	DefaultEfSearch       = 100
+)
+
+// Config tunes the graph. M is the number of neighbors kept per node (twice that on the
+// bottom layer); EfConstruction and EfSearch are the candidate list sizes when inserting
+// and searching, trading speed for recall. Zero values take the defaults.
+type Config struct {
+	M              int
+	EfConstruction int
+	EfSearch       int
+}
+
+func (c Config) withDefaults() Config {
+	if c.M <= 1 {
+		c.M = DefaultM
+	}
+	if c.EfConstruction <= 0 {
+		c.EfConstruction = DefaultEfConstruction
+	}
+	if c.EfSearch <= 0 {
+		c.EfSearch = DefaultEfSearch
+	}
+	return c
+}
+
+type node struct {
+	key    string
+	vector []float32
+	// friends holds the neighbors of the node on each of its layers, bottom first
+	friends [][]int32
+	deleted bool
+}
+
+// Index maps string keys to vectors. Deleted nodes stay in the graph as tombstones that
+// searches pass through but never return, until Compact rebuilds the graph. It is safe
+// for concurrent use.
+type Index struct {
+	cfg       Config
+	levelMult float64
+	rng       *rand.Rand
+
+	mu       sync.RWMutex
+	nodes    []node
+	keys     map[string]int32
+	entry    int32
+	maxLevel int
+	deleted  int
+}
+
+// Result is a key found by Search with its cosine similarity to the query.
+type Result struct {
+	Key        string
+	Similarity float64
+}
+
+// New returns an empty index.
+func New(cfg Config) *Index {
+	cfg = cfg.withDefaults()
+	return &Index{
+		cfg:       cfg,
+		levelMult: 1 / math.Log(float64(cfg.M)),
+		// a fixed seed keeps graphs, and so results, reproducible
+		rng:   rand.New(rand.NewPCG(1, 2)),
+		keys:  make(map[string]int32),
+		entry: -1,
+	}
+}
+
+// Config returns the index settings.
+func (x *Index) Config() Config {
+	return x.cfg
+}
+
+// Len returns the number of live keys.
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.keys)
+}
+
+// Add inserts vector under key, replacing any vector already stored under it. All vectors
+// must have the same number of dimensions.
+func (x *Index) Add(key string, vector []float32) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(vector) == 0 {
+		return fmt.Errorf("hnsw: empty vector for %s", key)
+	}
+	if x.entry >= 0 && len(vector) != len(x.nodes[x.entry].vector) {
+		return fmt.Errorf("hnsw: vector for %s has %d dimensions, the index holds %d", key, len(vector), len(x.nodes[x.entry].vector))
+	}
+	x.remove(key)
+	x.insert(key, normalize(vector))
+	x.maybeCompact()
+	return nil
+}
+
+// Delete removes key; unknown keys are ignored.
+func (x *Index) Delete(key string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(key)
+	x.maybeCompact()
+}
+
+func (x *Index) remove(key string) {
+	id, ok := x.keys[key]
+	if !ok {
+		return
+	}
+	delete(x.keys, key)
+	x.nodes[id].deleted = true
+	x.deleted++
+}
+
+// maybeCompact rebuilds the graph once tombstones make up half of it, which keeps
+// searches from wading through dead nodes. x.mu must be held.
+func (x *Index) maybeCompact() {
+	if x.deleted > 64 && x.deleted*2 > len(x.nodes) {
+		x.compact()
+	}
+}
+
+// Compact rebuilds the graph from the live nodes, dropping tombstones.
+func (x *Index) Compact() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.compact()
+}
+
+func (x *Index) compact() {
+	old := x.nodes
+	x.nodes = nil
+	x.keys = make(map[string]int32, len(x.keys))
+	x.entry = -1
+	x.maxLevel = 0
+	x.deleted = 0
+	for _, n := range old {
+		if !n.deleted {
+			x.insert(n.key, n.vector)
+		}
+	}
+}
+
+func (x *Index) randomLevel() int {
+	return int(math.Floor(-math.Log(1-x.rng.Float64()) * x.levelMult))
+}
+
+func (x *Index) maxFriends(level int) int {
+	if level == 0 {
+		return 2 * x.cfg.M
+	}
+	return x.cfg.M
+}
+
+// insert adds a normalized vector. x.mu must be held.
+func (x *Index) insert(key string, vector []float32) {
+	level := x.randomLevel()
+	id := int32(len(x.nodes))
+	x.nodes = append(x.nodes, node{key: key, vector: vector, friends: make([][]int32, level+1)})
+	x.keys[key] = id
+	if x.entry < 0 {
+		x.entry = id
+		x.maxLevel = level
+		return
+	}
+
+	ep := x.entry
+	for l := x.maxLevel; l > level; l-- {
+		ep = x.greedy(vector, ep, l)
+	}
+	eps := []int32{ep}
+	for l := min(level, x.maxLevel); l >= 0; l-- {
+		candidates := x.searchLayer(vector, eps, x.cfg.EfConstruction, l)
+		friends := x.selectNeighbors(vector, candidates, x.cfg.M)
+		x.nodes[id].friends[l] = friends
+		for _, f := range friends {
+			x.link(f, id, l)
+		}
+		eps = eps[:0]
+		for _, c := range candidates {
+			eps = append(eps, c.id)
+		}
+	}
+	if level > x.maxLevel {
+		x.maxLevel = level
+		x.entry = id
+	}
+}
+
+// link adds to as a neighbor of from on level, pruning from's neighbors if it has too many.
+func (x *Index) link(from, to int32, level int) {
+	friends := append(x.nodes[from].friends[level], to)
+	if len(friends) > x.maxFriends(level) {
+		v := x.nodes[from].vector
+		candidates := make([]candidate, len(friends))
+		for i, f := range friends {
+			candidates[i] = candidate{id: f, dist: x.distance(v, f)}
+		}
+		sortCandidates(candidates)
+		friends = x.selectNeighbors(v, candidates, x.maxFriends(level))
+	}
+	x.nodes[from].friends[level] = friends
+}
+
+// selectNeighbors picks up to m of candidates, sorted nearest first, preferring ones
+// closer to the query than to any already picked so that links spread out in different
+// directions; the rest fill up the remaining slots.
+func (x *Index) selectNeighbors(query []float32, candidates []candidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		good := true
+		for _, s := range selected {
+			if x.distance(x.nodes[c.id].vector, s) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c.id)
+		} else {
+			skipped = append(skipped, c.id)
+		}
+	}
+	for _, id := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, id)
+	}
+	return selected
+}
+
+// greedy walks level from ep towards the node nearest to query.
+func (x *Index) greedy(query []float32, ep int32, level int) int32 {
+	best := x.distance(query, ep)
+	for changed := true; changed; {
+		changed = false
+		for _, f := range x.nodes[ep].friends[level] {
+			if d := x.distance(query, f); d < best {
+				best, ep, changed = d, f, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns the ef nodes nearest to query on level, nearest first, including
+// tombstones.
+func (x *Index) searchLayer(query []float32, eps []int32, ef, level int) []candidate {
+	visited := make(map[int32]bool, ef*4)
+	var frontier minHeap
+	var found maxHeap
+	for _, ep := range eps {
+		visited[ep] = true
+		c := candidate{id: ep, dist: x.distance(query, ep)}
+		heap.Push(&frontier, c)
+		heap.Push(&found, c)
+		if found.Len() > ef {
+			heap.Pop(&found)
+		}
+	}
+	for frontier.Len() > 0 {
+		c := heap.Pop(&frontier).(candidate)
+		if found.Len() >= ef && c.dist > found[0].dist {
+			break
+		}
+		for _, f := range x.nodes[c.id].friends[level] {
+			if visited[f] {
+				continue
+			}
+			visited[f] = true
+			d := x.distance(query, f)
+			if found.Len() < ef || d < found[0].dist {
+				heap.Push(&frontier, candidate{id: f, dist: d})
+				heap.Push(&found, candidate{id: f, dist: d})
+				if found.Len() > ef {
+					heap.Pop(&found)
+				}
+			}
+		}
+	}
+	out := make([]candidate, found.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(&found).(candidate)
+	}
+	return out
+}
+
+// Search returns up to k live keys nearest to query, most similar first. ef overrides the
+// configured EfSearch when positive, and accept, when not nil, restricts the results to
+// the keys it returns true for.
+func (x *Index) Search(query []float32, k, ef int, accept func(key string) bool) []Result {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if x.entry < 0 || k <= 0 || len(query) != len(x.nodes[x.entry].vector) {
+		return nil
+	}
+	if ef <= 0 {
+		ef = x.cfg.EfSearch
+	}
+	ef = max(ef, k)
+	q := normalize(query)
+	ep := x.entry
+	for l := x.maxLevel; l > 0; l-- {
+		ep = x.greedy(q, ep, l)
+	}
+	var results []Result
+	for _, c := range x.searchLayer(q, []int32{ep}, ef, 0) {
+		n := x.nodes[c.id]
+		if n.deleted || (accept != nil && !accept(n.key)) {
+			continue
+		}
+		results = append(results, Result{Key: n.key, Similarity: 1 - c.dist})
+		if len(results) == k {
+			break
+		}
+	}
+	return results
+}
+
+// distance is the cosine distance between query and node id.
+func (x *Index) distance(query []float32, id int32) float64 {
+	var dot float32
+	for i, v := range x.nodes[id].vector {
+		dot += query[i] * v
+	}
+	return 1 - float64(dot)
+}
+
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	out := make([]float32, len(vector))
+	if norm == 0 {
+		return out
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i, v := range vector {
+		out[i] = v * scale
+	}
+	return out
+}
+
+type candidate struct {
+	id   int32
+	dist float64
+}
+
+func sortCandidates(c []candidate) {
+	slices.SortFunc(c, func(a, b candidate) int { return cmp.Compare(a.dist, b.dist) })
+}
+
+// minHeap pops the nearest candidate first.
+type minHeap []candidate
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].dist < h[j].dist }
+func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(v any)        { *h = append(*h, v.(candidate)) }
+func (h *minHeap) Pop() any {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
+
+// maxHeap pops the farthest candidate first.
+type maxHeap []candidate
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i].dist > h[j].dist }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(v any)        { *h = append(*h, v.(candidate)) }
+func (h *maxHeap) Pop() any {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
@@ -0,0 +1,180 @@
+package hnsw
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func randomVectors(n, dims int, seed uint64) [][]float32 {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dims)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	return vectors
+}
+
+// bruteForce returns the keys of the k vectors most similar to query.
+func bruteForce(vectors [][]float32, query []float32, k int) []string {
+	type scored struct {
+		key string
+		sim float64
+	}
+	q := normalize(query)
+	all := make([]scored, len(vectors))
+	for i, v := range vectors {
+		var dot float32
+		for j, x := range normalize(v) {
+			dot += q[j] * x
+		}
+		all[i] = scored{fmt.Sprint(i), float64(dot)}
+	}
+	slices.SortFunc(all, func(a, b scored) int { return cmp.Compare(b.sim, a.sim) })
+	keys := make([]string, k)
+	for i := range keys {
+		keys[i] = all[i].key
+	}
+	return keys
+}
+
+// recall is the fraction of the true top k that the index finds.
+func recall(x *Index, vectors, queries [][]float32, k int) float64 {
+	var hits int
+	for _, q := range queries {
+		want := bruteForce(vectors, q, k)
+		for _, r := range x.Search(q, k, 0, nil) {
+			if slices.Contains(want, r.Key) {
+				hits++
+			}
+		}
+	}
+	return float64(hits) / float64(k*len(queries))
+}
+
+func build(vectors [][]float32, cfg Config) *Index {
+	x := New(cfg)
+	for i, v := range vectors {
+		if err := x.Add(fmt.Sprint(i), v); err != nil {
+			panic(err)
+		}
+	}
+	return x
+}
+
+func TestRecallAgainstBruteForce(t *testing.T) {
+	vectors := randomVectors(2000, 16, 1)
+	queries := randomVectors(50, 16, 2)
+	x := build(vectors, Config{M: 16, EfConstruction: 100, EfSearch: 64})
+	if r := recall(x, vectors, queries, 10); r < 0.9 {
+		t.Errorf("recall@10 is %.2f, expected at least 0.9", r)
+	}
+	results := x.Search(queries[0], 10, 0, nil)
+	if len(results) != 10 || results[0].Similarity < results[9].Similarity {
+		t.Errorf("expected 10 results, most similar first, got %v", results)
+	}
+}
+
+func TestDeleteAndReplace(t *testing.T) {
+	vectors := randomVectors(300, 8, 3)
+	x := build(vectors, Config{})
+	for i := 0; i < 200; i++ {
+		x.Delete(fmt.Sprint(i))
+	}
+	if x.Len() != 100 {
+		t.Fatalf("expected 100 live keys, got %d", x.Len())
+	}
+	for _, r := range x.Search(vectors[5], 20, 0, nil) {
+		if r.Key == "5" {
+			t.Fatal("deleted key returned")
+		}
+	}
+	// replacing a key moves it to the new vector
+	if err := x.Add("250", vectors[5]); err != nil {
+		t.Fatal(err)
+	}
+	if got := x.Search(vectors[5], 1, 0, nil); len(got) != 1 || got[0].Key != "250" {
+		t.Errorf("expected the replaced key, got %v", got)
+	}
+	if x.Len() != 100 {
+		t.Errorf("expected replacing to keep 100 keys, got %d", x.Len())
+	}
+	// compaction ran once tombstones passed half of the graph
+	if len(x.nodes) >= 300 {
+		t.Errorf("expected tombstones to be compacted away, %d nodes remain", len(x.nodes))
+	}
+}
+
+func TestAddRejectsMismatchedDimensions(t *testing.T) {
+	x := build(randomVectors(20, 8, 6), Config{})
+	if err := x.Add("short", randomVectors(1, 4, 7)[0]); err == nil {
+		t.Fatal("expected an error for a 4-dimensional vector in an 8-dimensional index")
+	}
+	if err := x.Add("empty", nil); err == nil {
+		t.Fatal("expected an error for an empty vector")
+	}
+	if x.Len() != 20 {
+		t.Errorf("expected rejected vectors to leave 20 keys, got %d", x.Len())
+	}
+	if got := x.Search(randomVectors(1, 8, 8)[0], 5, 0, nil); len(got) != 5 {
+		t.Errorf("expected the index to keep working, got %v", got)
+	}
+}
+
+func TestAcceptFiltersResults(t *testing.T) {
+	vectors := randomVectors(200, 8, 4)
+	x := build(vectors, Config{})
+	even := func(key string) bool { return key[len(key)-1]%2 == 0 }
+	for _, r := range x.Search(vectors[1], 5, 0, even) {
+		if !even(r.Key) {
+			t.Fatalf("unexpected key %s", r.Key)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	vectors := randomVectors(500, 8, 5)
+	x := build(vectors, Config{M: 8, EfConstruction: 50, EfSearch: 40})
+	x.Delete("7")
+	var buf bytes.Buffer
+	if err := x.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	y, err := Load(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y.Config() != x.Config() || y.Len() != 499 {
+		t.Fatalf("unexpected loaded index %+v with %d keys", y.Config(), y.Len())
+	}
+	q := vectors[42]
+	if a, b := x.Search(q, 5, 0, nil), y.Search(q, 5, 0, nil); !slices.Equal(a, b) {
+		t.Errorf("expected the same results after loading: %v vs %v", a, b)
+	}
+	if _, err := Load(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Error("expected a truncated index to be rejected")
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	vectors := randomVectors(10000, 64, 6)
+	queries := randomVectors(100, 64, 7)
+	x := build(vectors, Config{})
+	b.Run("hnsw", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			x.Search(queries[i%len(queries)], 10, 0, nil)
+		}
+		b.ReportMetric(recall(x, vectors, queries[:20], 10), "recall@10")
+	})
+	b.Run("brute-force", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			bruteForce(vectors, queries[i%len(queries)], 10)
+		}
+	})
+}
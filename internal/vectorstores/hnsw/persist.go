@@ -0,0 +1,169 @@
+package hnsw
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// magic starts every saved index, followed by a format version byte.
+var magic = [4]byte{'G', 'G', 'H', 'N'}
+
+const version = 1
+
+var errCorrupt = errors.New("corrupt HNSW index")
+
+// Save writes the graph, vectors and settings to w, tombstones included.
+func (x *Index) Save(w io.Writer) error {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	bw := bufio.NewWriter(w)
+	put := func(v uint32) { binary.Write(bw, binary.LittleEndian, v) }
+
+	bw.Write(magic[:])
+	bw.WriteByte(version)
+	put(uint32(x.cfg.M))
+	put(uint32(x.cfg.EfConstruction))
+	put(uint32(x.cfg.EfSearch))
+	put(uint32(len(x.nodes)))
+	put(uint32(x.entry))
+	put(uint32(x.maxLevel))
+	for _, n := range x.nodes {
+		put(uint32(len(n.key)))
+		bw.WriteString(n.key)
+		deleted := byte(0)
+		if n.deleted {
+			deleted = 1
+		}
+		bw.WriteByte(deleted)
+		put(uint32(len(n.vector)))
+		for _, v := range n.vector {
+			put(math.Float32bits(v))
+		}
+		put(uint32(len(n.friends)))
+		for _, friends := range n.friends {
+			put(uint32(len(friends)))
+			for _, f := range friends {
+				put(uint32(f))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// Load reads an index written by Save.
+func Load(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	var err error
+	get := func() uint32 {
+		var v uint32
+		if err == nil {
+			err = binary.Read(br, binary.LittleEndian, &v)
+		}
+		return v
+	}
+	// bounded reads a length, refusing sizes no valid index has so that corrupt input
+	// cannot trigger huge allocations
+	bounded := func(limit uint32) uint32 {
+		v := get()
+		if err == nil && v > limit {
+			err = fmt.Errorf("length %d over %d", v, limit)
+		}
+		if err != nil {
+			return 0
+		}
+		return v
+	}
+
+	var prefix [5]byte
+	if _, err := io.ReadFull(br, prefix[:]); err != nil || [4]byte(prefix[:4]) != magic {
+		return nil, errCorrupt
+	}
+	if prefix[4] != version {
+		return nil, fmt.Errorf("%w: unknown version %d", errCorrupt, prefix[4])
+	}
+	x := New(Config{M: int(get()), EfConstruction: int(get()), EfSearch: int(get())})
+	count := get()
+	x.entry = int32(get())
+	x.maxLevel = int(get())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorrupt, err)
+	}
+	x.nodes = make([]node, 0, min(count, 1<<20))
+	for i := uint32(0); i < count && err == nil; i++ {
+		key := make([]byte, bounded(1<<16))
+		if err == nil {
+			_, err = io.ReadFull(br, key)
+		}
+		var deleted byte
+		if err == nil {
+			deleted, err = br.ReadByte()
+		}
+		n := node{key: string(key), deleted: deleted == 1}
+		n.vector = make([]float32, bounded(1<<16))
+		for j := range n.vector {
+			n.vector[j] = math.Float32frombits(get())
+		}
+		n.friends = make([][]int32, bounded(64))
+		for l := range n.friends {
+			n.friends[l] = make([]int32, bounded(uint32(2*x.cfg.M)))
+			for j := range n.friends[l] {
+				n.friends[l][j] = int32(get())
+			}
+		}
+		x.nodes = append(x.nodes, n)
+		if n.deleted {
+			x.deleted++
+		} else {
+			x.keys[n.key] = int32(i)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorrupt, err)
+	}
+	if err := x.validate(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// validate checks that every link points at a node that has the linked level.
+func (x *Index) validate() error {
+	if len(x.nodes) == 0 {
+		if x.entry != -1 {
+			return fmt.Errorf("%w: entry point in an empty index", errCorrupt)
+		}
+		return nil
+	}
+	if x.entry < 0 || int(x.entry) >= len(x.nodes) || len(x.nodes[x.entry].friends) != x.maxLevel+1 {
+		return fmt.Errorf("%w: bad entry point", errCorrupt)
+	}
+	dims := len(x.nodes[0].vector)
+	for _, n := range x.nodes {
+		if len(n.vector) != dims {
+			return fmt.Errorf("%w: mixed dimensions", errCorrupt)
+		}
+		for l, friends := range n.friends {
+			for _, f := range friends {
+				if f < 0 || int(f) >= len(x.nodes) || len(x.nodes[f].friends) <= l {
+					return fmt.Errorf("%w: dangling link", errCorrupt)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Keys returns the live keys.
+func (x *Index) Keys() []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	keys := make([]string, 0, len(x.keys))
+	for k := range x.keys {
+		keys = append(keys, k)
+	}
+	return keys
+}
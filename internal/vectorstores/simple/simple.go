@@ -2,17 +2,42 @@ package simple
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"gogurt/internal/embeddings"
 	"gogurt/internal/types"
 	"gogurt/internal/vectorstores"
+	"gogurt/internal/vectorstores/hnsw"
 	"math"
+	"slices"
 	"sort"
+	"sync"
 )
 
 type Store struct {
-	embedder  embeddings.Embedder
+	embedder embeddings.Embedder
+
+	mu        sync.RWMutex
 	documents []types.Document
 	vectors   [][]float32
+	// index maps a document ID to its position in documents and vectors
+	index map[string]int
+
+	// path, when set, receives a snapshot after every change; see Open
+	path  string
+	model string
+
+	// ann, when set, answers searches instead of a scan over every vector; see WithHNSW
+	ann *hnsw.Index
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithHNSW indexes the vectors in an HNSW graph, so searches visit a small part of the
+// store instead of all of it, at the cost of occasionally missing a close match.
+func WithHNSW(cfg hnsw.Config) Option {
+	return func(s *Store) { s.ann = hnsw.New(cfg) }
 }
 
 type searchResult struct {
@@ -21,78 +46,292 @@ type searchResult struct {
 }
 
 // New creates a simple in-memory vector store.
-func New(embedder embeddings.Embedder) vectorstores.VectorStore {
-	return &Store{embedder: embedder}
+func New(embedder embeddings.Embedder, opts ...Option) vectorstores.VectorStore {
+	s := &Store{embedder: embedder, index: make(map[string]int)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// AddDocuments adds documents to the vector store asynchronously.
+// AddDocuments adds documents to the vector store asynchronously. Documents are embedded
+// in concurrent batches; if some fail, the others are still added and the returned
+// error is an *embeddings.BatchError naming the failed ones. Documents whose ID is
+// already stored are skipped without being embedded.
 func (s *Store) AddDocuments(ctx context.Context, docs []types.Document) <-chan error {
+	return s.add(ctx, docs, false)
+}
+
+// Upsert adds documents asynchronously, replacing stored documents with the same ID.
+func (s *Store) Upsert(ctx context.Context, docs []types.Document) <-chan error {
+	return s.add(ctx, docs, true)
+}
+
+func (s *Store) add(ctx context.Context, docs []types.Document, replace bool) <-chan error {
 	errCh := make(chan error, 1)
 	go func() {
 		defer close(errCh)
+		docs = s.withIDs(docs, replace)
 		if len(docs) == 0 {
 			errCh <- nil
 			return
 		}
-		s.documents = append(s.documents, docs...)
-		// Assuming embedder methods are async and return channels
-		docEmbeddingsCh, embedErrCh := s.embedder.AEmbedDocuments(ctx, docs)
-
-		select {
-		case docEmbeddings := <-docEmbeddingsCh:
-			s.vectors = append(s.vectors, docEmbeddings...)
-			errCh <- nil
-		case err := <-embedErrCh:
+		vectors, err := s.embedder.EmbedAll(ctx, docs, embeddings.DefaultWorkers)
+		var batchErr *embeddings.BatchError
+		if err != nil && !errors.As(err, &batchErr) {
 			errCh <- err
-		case <-ctx.Done():
-			errCh <- ctx.Err()
+			return
+		}
+		s.mu.Lock()
+		var annErr error
+		for i, vector := range vectors {
+			if vector == nil {
+				continue
+			}
+			at, ok := s.index[docs[i].ID]
+			if ok && !replace {
+				continue
+			}
+			if s.ann != nil {
+				if err := s.ann.Add(docs[i].ID, vector); err != nil {
+					annErr = errors.Join(annErr, err)
+					continue
+				}
+			}
+			if ok {
+				s.documents[at] = docs[i]
+				s.vectors[at] = vector
+			} else {
+				s.index[docs[i].ID] = len(s.documents)
+				s.documents = append(s.documents, docs[i])
+				s.vectors = append(s.vectors, vector)
+			}
+		}
+		saveErr := s.persist()
+		s.mu.Unlock()
+		if saveErr != nil {
+			errCh <- saveErr
+			return
+		}
+		errCh <- errors.Join(err, annErr)
+	}()
+	return errCh
+}
+
+// withIDs returns docs with their IDs set, dropping duplicates within docs (the last one
+// wins) and, unless replace is set, documents that are already stored.
+func (s *Store) withIDs(docs []types.Document, replace bool) []types.Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]int, len(docs))
+	var out []types.Document
+	for _, doc := range docs {
+		doc.ID = vectorstores.DocumentID(doc)
+		if _, ok := s.index[doc.ID]; ok && !replace {
+			continue
+		}
+		if at, ok := seen[doc.ID]; ok {
+			out[at] = doc
+			continue
 		}
+		seen[doc.ID] = len(out)
+		out = append(out, doc)
+	}
+	return out
+}
+
+// Delete removes the documents with the given IDs asynchronously.
+func (s *Store) Delete(ctx context.Context, ids []string) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		remove := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			remove[id] = true
+		}
+		errCh <- s.removeWhere(func(doc types.Document) bool { return remove[doc.ID] })
 	}()
 	return errCh
 }
 
+// DeleteWhere removes the documents whose metadata has all the given values asynchronously.
+func (s *Store) DeleteWhere(ctx context.Context, metadata map[string]any) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		if len(metadata) == 0 {
+			errCh <- vectorstores.ErrNoFilter
+			return
+		}
+		errCh <- s.removeWhere(func(doc types.Document) bool {
+			return vectorstores.MatchesMetadata(doc.Metadata, metadata)
+		})
+	}()
+	return errCh
+}
+
+// removeWhere drops the documents matching remove, keeping the others in order.
+func (s *Store) removeWhere(remove func(types.Document) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	documents := s.documents[:0]
+	vectors := s.vectors[:0]
+	for i, doc := range s.documents {
+		if remove(doc) {
+			delete(s.index, doc.ID)
+			if s.ann != nil {
+				s.ann.Delete(doc.ID)
+			}
+			continue
+		}
+		s.index[doc.ID] = len(documents)
+		documents = append(documents, doc)
+		vectors = append(vectors, s.vectors[i])
+	}
+	clear(s.documents[len(documents):])
+	clear(s.vectors[len(vectors):])
+	removed := len(s.documents) - len(documents)
+	s.documents = documents
+	s.vectors = vectors
+	if removed == 0 {
+		return nil
+	}
+	return s.persist()
+}
+
+// persist saves a snapshot when the store was opened with a path. s.mu must be held.
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := s.save(s.path); err != nil {
+		return fmt.Errorf("failed to save vector store snapshot: %w", err)
+	}
+	return nil
+}
+
+// Documents returns a copy of the stored documents.
+func (s *Store) Documents() []types.Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.documents)
+}
+
+// Count returns the number of stored documents asynchronously.
+func (s *Store) Count(ctx context.Context) (<-chan int, <-chan error) {
+	out := make(chan int, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		s.mu.RLock()
+		out <- len(s.documents)
+		s.mu.RUnlock()
+	}()
+	return out, errCh
+}
+
 // SimilaritySearch performs a similarity search asynchronously.
-func (s *Store) SimilaritySearch(ctx context.Context, query string, k int) (<-chan []types.Document, <-chan error) {
+func (s *Store) SimilaritySearch(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []types.Document, <-chan error) {
 	out := make(chan []types.Document, 1)
 	errCh := make(chan error, 1)
 	go func() {
 		defer close(out)
 		defer close(errCh)
-
-		queryVectorCh, embedErrCh := s.embedder.AEmbedQuery(ctx, query)
-		var queryVector []float32
-		select {
-		case queryVector = <-queryVectorCh:
-		case err := <-embedErrCh:
+		results, err := s.search(ctx, query, k, vectorstores.NewSearchOptions(opts...))
+		if err != nil {
 			errCh <- err
 			return
-		case <-ctx.Done():
-			errCh <- ctx.Err()
-			return
 		}
-
-		var results []searchResult
-		for i, vector := range s.vectors {
-			similarity := cosineSimilarity(queryVector, vector)
-			results = append(results, searchResult{
-				document:   s.documents[i],
-				similarity: similarity,
-			})
+		var documents []types.Document
+		for _, result := range results {
+			documents = append(documents, result.document)
 		}
-		sort.Slice(results, func(i, j int) bool {
-			return results[i].similarity > results[j].similarity
-		})
+		out <- documents
+	}()
+	return out, errCh
+}
 
-		topK := min(len(results), k)
-		var documents []types.Document
-		for i := 0; i < topK; i++ {
-			documents = append(documents, results[i].document)
+// SimilaritySearchWithScore performs a similarity search asynchronously, scoring each
+// document by its cosine similarity to the query.
+func (s *Store) SimilaritySearchWithScore(ctx context.Context, query string, k int, opts ...vectorstores.SearchOption) (<-chan []vectorstores.ScoredDocument, <-chan error) {
+	out := make(chan []vectorstores.ScoredDocument, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errCh)
+		results, err := s.search(ctx, query, k, vectorstores.NewSearchOptions(opts...))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		var documents []vectorstores.ScoredDocument
+		for _, result := range results {
+			documents = append(documents, vectorstores.ScoredDocument{Document: result.document, Score: result.similarity})
 		}
 		out <- documents
 	}()
 	return out, errCh
 }
 
+// search returns the k documents matching the filter that are most similar to query.
+func (s *Store) search(ctx context.Context, query string, k int, o vectorstores.SearchOptions) ([]searchResult, error) {
+	queryVectorCh, embedErrCh := s.embedder.AEmbedQuery(ctx, query)
+	var queryVector []float32
+	select {
+	case queryVector = <-queryVectorCh:
+	case err := <-embedErrCh:
+		if err != nil {
+			return nil, err
+		}
+		queryVector = <-queryVectorCh
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ann != nil {
+		results := s.searchIndex(queryVector, k, o.Filter)
+		// deleted nodes or a selective filter can leave the explored part of the graph
+		// short of live matches
+		if len(results) >= min(k, len(s.documents)) {
+			return results, nil
+		}
+	}
+	var results []searchResult
+	for i, vector := range s.vectors {
+		if !o.Filter.Matches(s.documents[i].Metadata) {
+			continue
+		}
+		similarity := cosineSimilarity(queryVector, vector)
+		results = append(results, searchResult{
+			document:   s.documents[i],
+			similarity: similarity,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].similarity > results[j].similarity
+	})
+	return results[:min(len(results), k)], nil
+}
+
+// searchIndex searches the HNSW index for the k nearest documents matching filter.
+// s.mu must be held.
+func (s *Store) searchIndex(queryVector []float32, k int, filter *vectorstores.Filter) []searchResult {
+	var accept func(string) bool
+	if filter != nil {
+		accept = func(id string) bool { return filter.Matches(s.documents[s.index[id]].Metadata) }
+	}
+	var results []searchResult
+	for _, r := range s.ann.Search(queryVector, k, 0, accept) {
+		if at, ok := s.index[r.Key]; ok {
+			results = append(results, searchResult{document: s.documents[at], similarity: r.Similarity})
+		}
+	}
+	return results
+}
+
 // cosineSimilarity is a synchronous helper function.
 func cosineSimilarity(a, b []float32) float64 {
 	var dotProduct float64
@@ -113,4 +352,4 @@ func min(a, b int) int {
 		return a
 	}
 	return b
-}
\ No newline at end of file
+}
@@ -1,8 +1,15 @@
 package simple
 
 import (
+	"context"
+	"errors"
 	"math"
 	"testing"
+
+	"gogurt/internal/embeddings/local"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores"
+	"gogurt/internal/vectorstores/hnsw"
 )
 
 func TestCosineSimilarity(t *testing.T) {
@@ -40,3 +47,115 @@ func TestCosineSimilarity(t *testing.T) {
 		})
 	}
 }
+
+func count(t *testing.T, store vectorstores.VectorStore) int {
+	t.Helper()
+	out, errCh := store.Count(context.Background())
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	return <-out
+}
+
+func TestIDsUpsertAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store := New(local.New(64))
+	docs := []types.Document{
+		{PageContent: "alpha", Metadata: map[string]any{"source": "a.md"}},
+		{PageContent: "beta", Metadata: map[string]any{"source": "a.md"}},
+		{ID: "g", PageContent: "gamma", Metadata: map[string]any{"source": "b.md"}},
+	}
+	if err := <-store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	// adding the same chunks again does not duplicate them
+	if err := <-store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, store); n != 3 {
+		t.Fatalf("expected 3 documents, got %d", n)
+	}
+
+	if err := <-store.Upsert(ctx, []types.Document{{ID: "g", PageContent: "delta"}}); err != nil {
+		t.Fatal(err)
+	}
+	out, errCh := store.SimilaritySearchWithScore(ctx, "delta", 1)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	got := <-out
+	if len(got) != 1 || got[0].Document.ID != "g" || got[0].Document.PageContent != "delta" || math.Abs(got[0].Score-1) > 1e-5 {
+		t.Errorf("expected the upserted document with score 1, got %+v", got)
+	}
+
+	if err := <-store.DeleteWhere(ctx, nil); !errors.Is(err, vectorstores.ErrNoFilter) {
+		t.Fatalf("expected an empty filter to be refused, got %v", err)
+	}
+	if err := <-store.DeleteWhere(ctx, map[string]any{"source": "a.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, store); n != 1 {
+		t.Fatalf("expected 1 document after deleting a.md, got %d", n)
+	}
+	if err := <-store.Delete(ctx, []string{"g", "unknown"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, store); n != 0 {
+		t.Errorf("expected an empty store, got %d", n)
+	}
+}
+
+func TestDocumentIDIsStable(t *testing.T) {
+	a := types.Document{PageContent: "x", Metadata: map[string]any{"source": "a.md"}}
+	b := types.Document{PageContent: "x", Metadata: map[string]any{"source": "b.md"}}
+	if vectorstores.DocumentID(a) != vectorstores.DocumentID(a) || vectorstores.DocumentID(a) == vectorstores.DocumentID(b) {
+		t.Error("expected IDs to depend only on source and content")
+	}
+}
+
+func TestSearchWithFilter(t *testing.T) {
+	ctx := context.Background()
+	store := New(local.New(64))
+	docs := []types.Document{
+		{PageContent: "func main() starts the server", Metadata: map[string]any{"language": "go"}},
+		{PageContent: "the server starts on boot", Metadata: map[string]any{"language": "markdown"}},
+	}
+	if err := <-store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	out, errCh := store.SimilaritySearch(ctx, "the server starts on boot", 2, vectorstores.WithFilter(vectorstores.Eq("language", "go")))
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	got := <-out
+	if len(got) != 1 || got[0].Metadata["language"] != "go" {
+		t.Errorf("expected only the Go document, got %v", got)
+	}
+}
+
+func TestHNSWSearchFillsInPastDeletedNodes(t *testing.T) {
+	ctx := context.Background()
+	// with ef no larger than k, the nearest nodes are all the search explores
+	store := New(local.New(64), WithHNSW(hnsw.Config{EfSearch: 1}))
+	docs := []types.Document{
+		{ID: "a1", PageContent: "apple pie recipe"},
+		{ID: "a2", PageContent: "apple pie recipes"},
+		{ID: "a3", PageContent: "apple tart recipe"},
+		{ID: "b", PageContent: "chroma stores vectors"},
+		{ID: "c", PageContent: "ollama serves models"},
+		{ID: "d", PageContent: "the tokenizer counts tokens"},
+	}
+	if err := <-store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-store.Delete(ctx, []string{"a1", "a2"}); err != nil {
+		t.Fatal(err)
+	}
+	out, errCh := store.SimilaritySearch(ctx, "apple pie recipe", 2)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	if got := <-out; len(got) != 2 || got[0].ID != "a3" {
+		t.Errorf("expected two live results led by a3, got %v", got)
+	}
+}
@@ -0,0 +1,242 @@
+package simple
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"gogurt/internal/embeddings"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores/hnsw"
+)
+
+// magic starts every snapshot file, followed by a format version byte.
+var magic = [4]byte{'G', 'G', 'V', 'S'}
+
+const snapshotVersion = 1
+
+// snapshotHeader is the JSON part of a snapshot. The vectors follow it as little-endian
+// float32s, Dimensions per document, in document order.
+type snapshotHeader struct {
+	Model      string             `json:"model"`
+	Dimensions int                `json:"dimensions"`
+	Documents  []snapshotDocument `json:"documents"`
+}
+
+type snapshotDocument struct {
+	ID          string         `json:"id"`
+	PageContent string         `json:"page_content"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+}
+
+// Open returns a store persisted to path: the snapshot there, if any, is loaded, and
+// every change is saved back to it. model identifies the embedding model, as in
+// factories.EmbeddingsModel; a snapshot embedded with another model is refused rather
+// than mixed with new vectors. With WithHNSW, the graph is kept next to the snapshot in
+// path + ".hnsw" and rebuilt if it is missing or stale.
+func Open(embedder embeddings.Embedder, path, model string, opts ...Option) (*Store, error) {
+	s := &Store{embedder: embedder, index: make(map[string]int), path: path, model: model}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.Load(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save writes a snapshot of the store to path atomically.
+func (s *Store) Save(path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.save(path)
+}
+
+// save writes the snapshot, and the HNSW graph if any, atomically. s.mu must be held.
+func (s *Store) save(path string) error {
+	if err := writeAtomic(path, s.writeSnapshot); err != nil {
+		return err
+	}
+	if s.ann == nil {
+		return nil
+	}
+	return writeAtomic(path+".hnsw", s.ann.Save)
+}
+
+// writeSnapshot writes the snapshot format to out. s.mu must be held.
+func (s *Store) writeSnapshot(out io.Writer) error {
+	header := snapshotHeader{Model: s.model, Documents: make([]snapshotDocument, len(s.documents))}
+	if len(s.vectors) > 0 {
+		header.Dimensions = len(s.vectors[0])
+	}
+	for i, doc := range s.documents {
+		if len(s.vectors[i]) != header.Dimensions {
+			return fmt.Errorf("document %s has %d dimensions, expected %d", doc.ID, len(s.vectors[i]), header.Dimensions)
+		}
+		header.Documents[i] = snapshotDocument{ID: doc.ID, PageContent: doc.PageContent, Metadata: doc.Metadata}
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(out)
+	w.Write(magic[:])
+	w.WriteByte(snapshotVersion)
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+	buf := make([]byte, 4)
+	for _, vector := range s.vectors {
+		for _, v := range vector {
+			binary.LittleEndian.PutUint32(buf, math.Float32bits(v))
+			w.Write(buf)
+		}
+	}
+	return w.Flush()
+}
+
+// writeAtomic writes a temporary file with write and renames it over path, so a crash
+// never leaves a partial file.
+func writeAtomic(path string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load replaces the store's contents with the snapshot at path.
+func (s *Store) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil || [4]byte(prefix[:4]) != magic {
+		return fmt.Errorf("%s is not a vector store snapshot", path)
+	}
+	if prefix[4] != snapshotVersion {
+		return fmt.Errorf("%s: unsupported snapshot version %d", path, prefix[4])
+	}
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return fmt.Errorf("%s: truncated snapshot: %w", path, err)
+	}
+	var header snapshotHeader
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if s.model != "" && header.Model != "" && header.Model != s.model {
+		return fmt.Errorf("%s was embedded with %s, not %s; delete it or use another path to re-ingest", path, header.Model, s.model)
+	}
+
+	documents := make([]types.Document, len(header.Documents))
+	vectors := make([][]float32, len(header.Documents))
+	index := make(map[string]int, len(header.Documents))
+	buf := make([]byte, 4*header.Dimensions)
+	for i, doc := range header.Documents {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return fmt.Errorf("%s: truncated snapshot: %w", path, err)
+		}
+		vector := make([]float32, header.Dimensions)
+		for j := range vector {
+			vector[j] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*j:]))
+		}
+		documents[i] = types.Document{ID: doc.ID, PageContent: doc.PageContent, Metadata: numbers(doc.Metadata)}
+		vectors[i] = vector
+		index[doc.ID] = i
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.documents, s.vectors, s.index = documents, vectors, index
+	if s.ann != nil {
+		if err := s.loadIndex(path + ".hnsw"); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// loadIndex loads the HNSW graph saved at path, or rebuilds it from the vectors when the
+// file is missing, unreadable, built with other settings or out of step with the
+// snapshot. s.mu must be held.
+func (s *Store) loadIndex(path string) error {
+	if f, err := os.Open(path); err == nil {
+		ann, err := hnsw.Load(f)
+		f.Close()
+		if err == nil && ann.Config() == s.ann.Config() && s.indexes(ann) {
+			s.ann = ann
+			return nil
+		}
+	}
+	s.ann = hnsw.New(s.ann.Config())
+	for i, doc := range s.documents {
+		if err := s.ann.Add(doc.ID, s.vectors[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexes reports whether ann holds exactly the stored documents.
+func (s *Store) indexes(ann *hnsw.Index) bool {
+	keys := ann.Keys()
+	if len(keys) != len(s.documents) {
+		return false
+	}
+	for _, key := range keys {
+		if _, ok := s.index[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// numbers turns the json.Numbers of decoded metadata back into ints where they are
+// integral and float64s otherwise.
+func numbers(metadata map[string]any) map[string]any {
+	for k, v := range metadata {
+		n, ok := v.(json.Number)
+		if !ok {
+			continue
+		}
+		if i, err := n.Int64(); err == nil {
+			metadata[k] = int(i)
+		} else if f, err := n.Float64(); err == nil {
+			metadata[k] = f
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,142 @@
+package simple
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gogurt/internal/embeddings/local"
+	"gogurt/internal/types"
+	"gogurt/internal/vectorstores"
+	"gogurt/internal/vectorstores/hnsw"
+)
+
+func TestSnapshotSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.gvs")
+	embedder := local.New(32)
+
+	store, err := Open(embedder, path, "local:hashing:32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []types.Document{
+		{PageContent: "ollama runs models locally", Metadata: map[string]any{"source": "a.md", "modified": 1700000000}},
+		{PageContent: "chroma stores vectors", Metadata: map[string]any{"source": "b.md", "page": 2.5}},
+	}
+	if err := <-store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	before := store.Documents()
+
+	reopened, err := Open(embedder, path, "local:hashing:32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := reopened.Documents()
+	if len(after) != 2 || after[0].ID != before[0].ID || after[1].PageContent != docs[1].PageContent {
+		t.Fatalf("unexpected documents after reload: %+v", after)
+	}
+	if after[0].Metadata["modified"] != 1700000000 || after[1].Metadata["page"] != 2.5 {
+		t.Errorf("expected metadata numbers to round trip, got %v and %v", after[0].Metadata, after[1].Metadata)
+	}
+	if reopened.vectors[1][3] != store.vectors[1][3] {
+		t.Error("expected vectors to round trip")
+	}
+
+	// deletions are persisted too
+	if err := <-reopened.DeleteWhere(ctx, map[string]any{"source": "a.md"}); err != nil {
+		t.Fatal(err)
+	}
+	reopened, _ = Open(embedder, path, "local:hashing:32")
+	out, errCh := reopened.SimilaritySearch(ctx, "vectors", 5, vectorstores.WithFilter(vectorstores.Range("modified", 1, nil)))
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	if got := <-out; len(got) != 0 || len(reopened.Documents()) != 1 {
+		t.Errorf("expected only b.md to remain, got %v", reopened.Documents())
+	}
+}
+
+func TestSnapshotRefusesOtherModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.gvs")
+	store, _ := Open(local.New(32), path, "local:hashing:32")
+	if err := <-store.AddDocuments(context.Background(), []types.Document{{PageContent: "x"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Open(local.New(32), path, "ollama:nomic-embed-text")
+	if err == nil || !strings.Contains(err.Error(), "local:hashing:32") {
+		t.Errorf("expected a model mismatch error, got %v", err)
+	}
+}
+
+func TestCorruptSnapshotIsAnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.gvs")
+	os.WriteFile(path, []byte("GGVS\x01junk"), 0o644)
+	if _, err := Open(local.New(32), path, ""); err == nil {
+		t.Error("expected a truncated snapshot to be rejected")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary files, got %d entries", len(entries))
+	}
+}
+
+func TestHNSWIndexIsPersisted(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.gvs")
+	embedder := local.New(64)
+	opt := WithHNSW(hnsw.Config{M: 8})
+
+	store, err := Open(embedder, path, "local", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []types.Document
+	for _, topic := range []string{"ollama models", "chroma vectors", "go channels", "rate limits", "pdf loaders"} {
+		for i := range 10 {
+			docs = append(docs, types.Document{PageContent: fmt.Sprintf("%s note %d", topic, i), Metadata: map[string]any{"n": i}})
+		}
+	}
+	if err := <-store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".hnsw"); err != nil {
+		t.Fatalf("expected the graph to be saved: %v", err)
+	}
+
+	search := func(s *Store, filter *vectorstores.Filter) []types.Document {
+		out, errCh := s.SimilaritySearch(ctx, "go channels note 3", 3, vectorstores.WithFilter(filter))
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+		return <-out
+	}
+	if got := search(store, nil); len(got) != 3 || got[0].PageContent != "go channels note 3" {
+		t.Errorf("unexpected results %v", got)
+	}
+	// a selective filter still fills k, falling back to a scan if the graph search falls short
+	got := search(store, vectorstores.Eq("n", 7))
+	if len(got) != 3 {
+		t.Fatalf("expected 3 documents with n=7, got %d", len(got))
+	}
+	for _, doc := range got {
+		if doc.Metadata["n"] != 7 {
+			t.Errorf("unexpected document %v", doc)
+		}
+	}
+
+	// the saved graph is reused, and rebuilt when it is gone
+	reopened, err := Open(embedder, path, "local", opt)
+	if err != nil || reopened.ann.Len() != 50 {
+		t.Fatalf("expected the graph to load with 50 keys: %v", err)
+	}
+	os.Remove(path + ".hnsw")
+	reopened, _ = Open(embedder, path, "local", opt)
+	if got := search(reopened, nil); reopened.ann.Len() != 50 || len(got) != 3 || got[0].PageContent != "go channels note 3" {
+		t.Errorf("expected a rebuilt graph to answer the same, got %v", got)
+	}
+}
@@ -2,14 +2,92 @@ package vectorstores
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gogurt/internal/embeddings"
 	"gogurt/internal/types"
+	"slices"
 )
 
+// ErrNoFilter is returned by DeleteWhere for an empty filter, which would match every document.
+var ErrNoFilter = errors.New("refusing to delete without a metadata filter")
+
 // VectorStore is the interface for a vector database.
 // All methods are non-blocking and return results via channels.
 type VectorStore interface {
-	// AddDocuments adds documents to the vector store asynchronously.
+	// AddDocuments adds documents to the vector store asynchronously. Documents whose ID
+	// is already stored are left unchanged.
 	AddDocuments(ctx context.Context, docs []types.Document) <-chan error
-	// SimilaritySearch performs a similarity search asynchronously.
-	SimilaritySearch(ctx context.Context, query string, k int) (<-chan []types.Document, <-chan error)
-}
\ No newline at end of file
+	// Upsert adds documents, replacing stored documents with the same ID.
+	Upsert(ctx context.Context, docs []types.Document) <-chan error
+	// Delete removes the documents with the given IDs; unknown IDs are ignored.
+	Delete(ctx context.Context, ids []string) <-chan error
+	// DeleteWhere removes the documents whose metadata has all the given values, e.g.
+	// {"source": path}. An empty filter fails with ErrNoFilter.
+	DeleteWhere(ctx context.Context, metadata map[string]any) <-chan error
+	// Count returns the number of stored documents.
+	Count(ctx context.Context) (<-chan int, <-chan error)
+	// SimilaritySearch performs a similarity search asynchronously. WithFilter restricts
+	// the results by metadata.
+	SimilaritySearch(ctx context.Context, query string, k int, opts ...SearchOption) (<-chan []types.Document, <-chan error)
+	// SimilaritySearchWithScore is SimilaritySearch with the similarity of each document,
+	// most similar first.
+	SimilaritySearchWithScore(ctx context.Context, query string, k int, opts ...SearchOption) (<-chan []ScoredDocument, <-chan error)
+}
+
+// ScoredDocument is a search result. Score is a similarity, higher is closer; for cosine
+// stores it is the cosine similarity.
+type ScoredDocument struct {
+	Document types.Document
+	Score    float64
+}
+
+// DocumentID returns doc.ID, or when it is empty an ID derived from the document's
+// source and content, so re-ingesting an unchanged chunk yields the same ID.
+func DocumentID(doc types.Document) string {
+	if doc.ID != "" {
+		return doc.ID
+	}
+	source := ""
+	if doc.Metadata != nil {
+		if v, ok := doc.Metadata["source"]; ok {
+			source = fmt.Sprint(v)
+		}
+	}
+	sum := sha256.Sum256([]byte(source + "\x00" + doc.PageContent))
+	return hex.EncodeToString(sum[:16])
+}
+
+// MatchesMetadata reports whether metadata has every value in match. Values are compared
+// by their string form, so an int 3 matches a float 3 read back from a store.
+func MatchesMetadata(metadata, match map[string]any) bool {
+	for k, want := range match {
+		got, ok := metadata[k]
+		if !ok || fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+// Stored returns the docs that AddDocuments or Upsert stored, given the error it returned:
+// all of them on success, the ones an *embeddings.BatchError does not name after a partial
+// failure, and none otherwise.
+func Stored(docs []types.Document, err error) []types.Document {
+	var batchErr *embeddings.BatchError
+	switch {
+	case err == nil:
+		return docs
+	case !errors.As(err, &batchErr):
+		return nil
+	}
+	failed := make(map[string]bool, len(batchErr.IDs))
+	for _, id := range batchErr.IDs {
+		failed[id] = true
+	}
+	return slices.DeleteFunc(slices.Clone(docs), func(d types.Document) bool {
+		return failed[DocumentID(d)]
+	})
+}